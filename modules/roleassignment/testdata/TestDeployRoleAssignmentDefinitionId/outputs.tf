@@ -0,0 +1,7 @@
+output "principal_id" {
+  value = data.azurerm_client_config.current.object_id
+}
+
+output "scope" {
+  value = azurerm_resource_group.test.id
+}
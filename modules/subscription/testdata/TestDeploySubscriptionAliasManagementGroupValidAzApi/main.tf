@@ -30,6 +30,11 @@ variable "subscription_use_azapi" {
   type = bool
 }
 
+variable "subscription_tags" {
+  type    = map(string)
+  default = {}
+}
+
 resource "random_id" "id" {
   byte_length = 4
 }
@@ -50,6 +55,7 @@ module "subscription_test" {
   subscription_management_group_association_enabled = var.subscription_management_group_association_enabled
   subscription_alias_enabled                        = var.subscription_alias_enabled
   subscription_use_azapi                            = var.subscription_use_azapi
+  subscription_tags                                 = var.subscription_tags
 }
 
 output "subscription_id" {
@@ -0,0 +1,7 @@
+output "hub_virtual_network_resource_id" {
+  value = azapi_resource.hub.id
+}
+
+output "virtual_network_resource_ids" {
+  value = module.virtualnetwork_test.virtual_network_resource_ids
+}
@@ -0,0 +1,7 @@
+output "virtual_hub_resource_id" {
+  value = azapi_resource.vhub.id
+}
+
+output "virtual_network_resource_ids" {
+  value = module.virtualnetwork_test.virtual_network_resource_ids
+}
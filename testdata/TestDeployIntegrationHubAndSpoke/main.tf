@@ -35,6 +35,7 @@ module "lz_vending" {
   subscription_alias_name                          = var.subscription_alias_name
   subscription_workload                            = var.subscription_workload
   subscription_register_resource_providers_enabled = var.subscription_register_resource_providers_enabled
+  subscription_tags                                = var.subscription_tags
 
   # virtual network variables
   virtual_network_enabled = var.virtual_network_enabled
@@ -36,3 +36,8 @@ variable "role_assignment_enabled" {
 variable "subscription_register_resource_providers_enabled" {
   type = bool
 }
+
+variable "subscription_tags" {
+  type    = map(string)
+  default = {}
+}
@@ -11,6 +11,8 @@ module "lz_vending" {
   umi_enabled                                      = true
   umi_name                                         = "umi-${var.random_hex}"
   umi_resource_group_name                          = "rg-umi-${var.random_hex}"
+  umi_tags                                         = var.umi_tags
+  umi_resource_group_tags                          = var.umi_resource_group_tags
   disable_telemetry                                = true
   resource_group_creation_enabled                  = true
   subscription_register_resource_providers_enabled = true
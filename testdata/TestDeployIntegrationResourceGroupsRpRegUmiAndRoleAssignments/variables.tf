@@ -5,3 +5,13 @@ variable "random_hex" {
 variable "subscription_id" {
   type = string
 }
+
+variable "umi_tags" {
+  type    = map(string)
+  default = {}
+}
+
+variable "umi_resource_group_tags" {
+  type    = map(string)
+  default = {}
+}
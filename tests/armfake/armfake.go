@@ -0,0 +1,333 @@
+// Package armfake provides an in-memory fake of the Azure Resource Manager API.
+//
+// The fake implements the subset of ARM used by the azureutils package and by the
+// azapi and azurerm providers when deploying this module: subscription aliases,
+// subscriptions, management groups and their membership, resource groups, virtual networks,
+// subnets, peerings, role assignments, resource provider and feature registration
+// and a minimal token endpoint.
+//
+// It is intended to allow the deployment tests and azureutils helpers to be run
+// without an Azure billing account. It does not validate request bodies beyond what
+// is required to keep its state consistent.
+package armfake
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// EnvEndpoint is the environment variable that holds the URL of a running fake.
+	// When set, azureutils and the generated provider files will target the fake
+	// instead of the public Azure cloud.
+	EnvEndpoint = "ARMFAKE_ENDPOINT"
+
+	// EnvCACert is the environment variable that holds the path of the PEM encoded
+	// certificate that the fake uses for TLS.
+	EnvCACert = "ARMFAKE_CA_CERT"
+
+	// DefaultTenantID is the tenant id used by the fake, it is also the name of the
+	// tenant root management group.
+	DefaultTenantID = "00000000-0000-0000-0000-000000000001"
+
+	// DefaultPrincipalID is the object id of the principal the fake issues tokens for.
+	DefaultPrincipalID = "00000000-0000-0000-0000-000000000002"
+
+	// DefaultBillingScope is a billing scope that can be supplied to the subscription alias API.
+	DefaultBillingScope = "/providers/Microsoft.Billing/billingAccounts/0000000/enrollmentAccounts/000000"
+)
+
+// Server is a fake Azure Resource Manager.
+// It embeds an httptest.Server using TLS, as the Azure SDK refuses to send bearer tokens
+// over plain HTTP.
+type Server struct {
+	*httptest.Server
+
+	// TenantID is the tenant id reported by the fake.
+	TenantID string
+
+	// PrincipalID is the object id of the principal in the issued access tokens.
+	PrincipalID string
+
+	// PageSize is the maximum number of items returned by a list operation before a nextLink is returned.
+	// Zero means that all items are returned in a single page.
+	PageSize int
+
+	mu              sync.Mutex
+	certFile        string
+	resources       map[string]map[string]any
+	subscriptions   map[string]*Subscription
+	aliases         map[string]map[string]any
+	memberships     map[string]string
+	providers       map[string]map[string]string
+	operations      map[string]int
+	roleDefinitions []roleDefinition
+}
+
+// Subscription represents a subscription held by the fake.
+type Subscription struct {
+	ID          string
+	DisplayName string
+	State       string
+	Tags        map[string]string
+	CreatedTime time.Time
+}
+
+// NewServer starts a new fake and registers its shutdown with t.Cleanup.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatalf("cannot start fake ARM server: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// New starts a new fake, writing the TLS certificate to the supplied directory.
+// The caller is responsible for calling Close.
+func New(dir string) (*Server, error) {
+	s := &Server{
+		TenantID:      DefaultTenantID,
+		PrincipalID:   DefaultPrincipalID,
+		resources:     make(map[string]map[string]any),
+		subscriptions: make(map[string]*Subscription),
+		aliases:       make(map[string]map[string]any),
+		memberships:   make(map[string]string),
+		providers:     make(map[string]map[string]string),
+		operations:    make(map[string]int),
+	}
+	s.roleDefinitions = builtInRoleDefinitions()
+	s.putManagementGroup(s.TenantID, "")
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+
+	s.certFile = filepath.Join(dir, "armfake.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	if err := os.WriteFile(s.certFile, cert, 0600); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("cannot write certificate file: %v", err)
+	}
+	return s, nil
+}
+
+// CertFile returns the path to the PEM encoded TLS certificate used by the fake.
+func (s *Server) CertFile() string {
+	return s.certFile
+}
+
+// Env returns the environment variables that point azureutils and the generated
+// provider files at the fake.
+func (s *Server) Env() map[string]string {
+	return map[string]string{
+		EnvEndpoint: s.URL,
+		EnvCACert:   s.certFile,
+	}
+}
+
+// Setenv sets the environment variables returned by Env for the duration of the test.
+func (s *Server) Setenv(t testing.TB) {
+	t.Helper()
+	for k, v := range s.Env() {
+		t.Setenv(k, v)
+	}
+}
+
+// AddSubscription adds an enabled subscription to the fake and returns it.
+// The subscription is placed in the tenant root management group.
+func (s *Server) AddSubscription(displayName string) *Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addSubscription(uuid.NewString(), displayName)
+}
+
+// AddSubscriptionAlias adds an enabled subscription to the fake, together with an alias
+// of the supplied name, as if it had been created via the subscription alias API.
+// The alias name is also used as the display name.
+func (s *Server) AddSubscriptionAlias(name string) *Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := s.addSubscription(uuid.NewString(), name)
+	s.aliases[strings.ToLower(name)] = s.aliasBody(name, sub)
+	return sub
+}
+
+// Subscription returns the subscription with the supplied id.
+func (s *Server) Subscription(id string) (Subscription, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[strings.ToLower(id)]
+	if !ok {
+		return Subscription{}, false
+	}
+	return *sub, true
+}
+
+// SetSubscriptionCreatedTime overrides the creation time of the subscription and its alias.
+func (s *Server) SetSubscriptionCreatedTime(id string, created time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[strings.ToLower(id)]
+	if !ok {
+		return
+	}
+	sub.CreatedTime = created
+	for _, a := range s.aliases {
+		props := a["properties"].(map[string]any)
+		if strings.EqualFold(props["subscriptionId"].(string), id) {
+			props["createdTime"] = created.UTC().Format(time.RFC3339)
+		}
+	}
+}
+
+// SetSubscriptionTags adds the tags to the subscription, as if created with them.
+func (s *Server) SetSubscriptionTags(id string, tags map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subscriptions[strings.ToLower(id)]
+	if !ok {
+		return
+	}
+	for k, v := range tags {
+		sub.Tags[k] = v
+	}
+}
+
+// PutResource creates or replaces the resource with the supplied id.
+// It does not check that the parent resource exists.
+func (s *Server) PutResource(id string, body map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putResource(id, body)
+}
+
+// Resource returns the resource with the supplied id, as it would be returned by a GET request.
+func (s *Server) Resource(id string) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getResource(id)
+}
+
+// ResourceIDs returns the ids of all resources whose id starts with the supplied prefix.
+func (s *Server) ResourceIDs(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix = strings.ToLower(prefix)
+	ids := make([]string, 0)
+	for k, v := range s.resources {
+		if strings.HasPrefix(k, prefix) {
+			ids = append(ids, v["id"].(string))
+		}
+	}
+	return ids
+}
+
+// SetProviderState sets the registration state of the resource provider in the subscription,
+// e.g. Registering, as reported until it is registered again.
+func (s *Server) SetProviderState(subID, namespace, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subID = strings.ToLower(subID)
+	if s.providers[subID] == nil {
+		s.providers[subID] = make(map[string]string)
+	}
+	s.providers[subID][namespace] = state
+}
+
+// ManagementGroupOf returns the name of the management group that the subscription is a direct child of.
+func (s *Server) ManagementGroupOf(subID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.memberships[strings.ToLower(subID)]
+}
+
+func (s *Server) addSubscription(id, displayName string) *Subscription {
+	sub := &Subscription{
+		ID:          id,
+		DisplayName: displayName,
+		State:       "Enabled",
+		Tags:        make(map[string]string),
+		CreatedTime: time.Now().UTC(),
+	}
+	s.subscriptions[strings.ToLower(id)] = sub
+	s.memberships[strings.ToLower(id)] = s.TenantID
+	return sub
+}
+
+// serveHTTP routes the request to the relevant handler.
+// Handlers that are specific to a resource provider are tried first,
+// then the request is handled by the generic resource store.
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	for _, h := range []func(http.ResponseWriter, *http.Request, string) bool{
+		s.handleAuth,
+		s.handleOperation,
+		s.handleSubscription,
+		s.handleAlias,
+		s.handleManagementGroupSubscription,
+		s.handleManagementGroups,
+		s.handleProviders,
+		s.handleRoleDefinitions,
+		s.handleRoleAssignments,
+		s.handleLocks,
+	} {
+		if h(w, r, path) {
+			return
+		}
+	}
+	s.handleResource(w, r, path)
+}
+
+// writeJSON writes the supplied value as JSON with the supplied status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("x-ms-request-id", uuid.NewString())
+	w.WriteHeader(status)
+	if v == nil {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an ARM error response.
+func writeError(w http.ResponseWriter, status int, code, format string, args ...any) {
+	w.Header().Set("x-ms-error-code", code)
+	writeJSON(w, status, map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": fmt.Sprintf(format, args...),
+		},
+	})
+}
+
+// readBody decodes the JSON request body into a map.
+// An empty body results in an empty map.
+func readBody(r *http.Request) (map[string]any, error) {
+	body := make(map[string]any)
+	if r.Body == nil || r.ContentLength == 0 {
+		return body, nil
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// segments splits the path into its non-empty segments.
+func segments(path string) []string {
+	return strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+}
@@ -0,0 +1,262 @@
+package armfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// do sends a request to the fake and decodes the JSON response body, if any.
+func do(t *testing.T, s *Server, method, path string, body any) (int, map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req, err := http.NewRequest(method, s.URL+path, &buf)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	out := make(map[string]any)
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestAliasCreatesSubscription(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	s.putManagementGroup("testmg", s.TenantID)
+
+	status, body := do(t, s, http.MethodPut, "/providers/Microsoft.Subscription/aliases/testdeploy-abc", map[string]any{
+		"properties": map[string]any{
+			"displayName":  "testdeploy-abc",
+			"billingScope": DefaultBillingScope,
+			"workload":     "Production",
+			"additionalProperties": map[string]any{
+				"managementGroupId": "/providers/Microsoft.Management/managementGroups/testmg",
+			},
+		},
+	})
+	require.Equal(t, http.StatusCreated, status)
+	subID := body["properties"].(map[string]any)["subscriptionId"].(string)
+
+	sub, ok := s.Subscription(subID)
+	require.True(t, ok)
+	assert.Equal(t, "testdeploy-abc", sub.DisplayName)
+	assert.Equal(t, "Enabled", sub.State)
+	assert.Equal(t, "testmg", s.ManagementGroupOf(subID))
+}
+
+func TestCancelSubscription(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	sub := s.AddSubscription("test")
+
+	status, _ := do(t, s, http.MethodPost, "/subscriptions/"+sub.ID+"/providers/Microsoft.Subscription/cancel", nil)
+	assert.Equal(t, http.StatusOK, status)
+	got, _ := s.Subscription(sub.ID)
+	assert.Equal(t, "Warned", got.State)
+
+	status, body := do(t, s, http.MethodPost, "/subscriptions/"+sub.ID+"/providers/Microsoft.Subscription/cancel", nil)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "SubscriptionNotActive", body["error"].(map[string]any)["code"])
+}
+
+func TestResourceGroupDeleteRemovesChildren(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	sub := s.AddSubscription("test")
+	rg := "/subscriptions/" + sub.ID + "/resourceGroups/rg"
+	vnet := rg + "/providers/Microsoft.Network/virtualNetworks/vnet"
+
+	status, _ := do(t, s, http.MethodPut, vnet, map[string]any{"location": "westeurope"})
+	assert.Equal(t, http.StatusNotFound, status, "resource group does not exist yet")
+
+	status, _ = do(t, s, http.MethodPut, rg, map[string]any{"location": "westeurope"})
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = do(t, s, http.MethodPut, vnet, map[string]any{"location": "westeurope"})
+	require.Equal(t, http.StatusCreated, status)
+
+	status, _ = do(t, s, http.MethodDelete, rg, nil)
+	assert.Equal(t, http.StatusAccepted, status)
+	assert.Empty(t, s.ResourceIDs(rg))
+}
+
+func TestVirtualNetworkSubnetsAndPaging(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	s.PageSize = 2
+	sub := s.AddSubscription("test")
+	rg := "/subscriptions/" + sub.ID + "/resourceGroups/rg"
+	vnet := rg + "/providers/Microsoft.Network/virtualNetworks/vnet"
+	s.PutResource(rg, map[string]any{"location": "westeurope"})
+
+	subnets := make([]any, 0)
+	for _, n := range []string{"a", "b", "c"} {
+		subnets = append(subnets, map[string]any{"name": n, "properties": map[string]any{}})
+	}
+	status, _ := do(t, s, http.MethodPut, vnet, map[string]any{"properties": map[string]any{"subnets": subnets}})
+	require.Equal(t, http.StatusCreated, status)
+
+	_, page := do(t, s, http.MethodGet, vnet+"/subnets", nil)
+	assert.Len(t, page["value"], 2)
+	require.Contains(t, page, "nextLink")
+
+	status, _ = do(t, s, http.MethodPut, vnet, map[string]any{"properties": map[string]any{}})
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, s.ResourceIDs(vnet+"/subnets/"), "omitting subnets removes them")
+}
+
+func TestPeeringState(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	sub := s.AddSubscription("test")
+	rg := "/subscriptions/" + sub.ID + "/resourceGroups/rg"
+	vnet1 := rg + "/providers/Microsoft.Network/virtualNetworks/vnet1"
+	vnet2 := rg + "/providers/Microsoft.Network/virtualNetworks/vnet2"
+	s.PutResource(rg, map[string]any{})
+	s.PutResource(vnet1, map[string]any{})
+	s.PutResource(vnet2, map[string]any{})
+
+	peering := func(remote string) map[string]any {
+		return map[string]any{"properties": map[string]any{"remoteVirtualNetwork": map[string]any{"id": remote}}}
+	}
+	state := func(id string) string {
+		res, ok := s.Resource(id)
+		require.True(t, ok)
+		return res["properties"].(map[string]any)["peeringState"].(string)
+	}
+
+	s.PutResource(vnet1+"/virtualNetworkPeerings/to2", peering(vnet2))
+	assert.Equal(t, "Initiated", state(vnet1+"/virtualNetworkPeerings/to2"))
+
+	s.PutResource(vnet2+"/virtualNetworkPeerings/to1", peering(vnet1))
+	assert.Equal(t, "Connected", state(vnet1+"/virtualNetworkPeerings/to2"))
+	assert.Equal(t, "Connected", state(vnet2+"/virtualNetworkPeerings/to1"))
+
+	status, _ := do(t, s, http.MethodDelete, vnet2+"/virtualNetworkPeerings/to1", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Disconnected", state(vnet1+"/virtualNetworkPeerings/to2"))
+}
+
+func TestRoleDefinitionFilter(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	sub := s.AddSubscription("test")
+
+	status, body := do(t, s, http.MethodGet, "/subscriptions/"+sub.ID+"/providers/Microsoft.Authorization/roleDefinitions?$filter=roleName+eq+'Owner'", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, body["value"], 1)
+	assert.Equal(t, "8e3af657-a8ff-443c-a75c-2fe8c4bcb635", body["value"].([]any)[0].(map[string]any)["name"])
+}
+
+func TestRoleAssignmentsListAtScope(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	sub := s.AddSubscription("test")
+	subScope := "/subscriptions/" + sub.ID
+	rg := subScope + "/resourceGroups/rg"
+	s.PutResource(rg, map[string]any{"location": "westeurope"})
+	assignment := func(principal string) map[string]any {
+		return map[string]any{"properties": map[string]any{"principalId": principal, "roleDefinitionId": "/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7"}}
+	}
+	s.PutResource(subScope+"/providers/Microsoft.Authorization/roleAssignments/sub", assignment("p1"))
+	s.PutResource(rg+"/providers/Microsoft.Authorization/roleAssignments/rg", assignment("p2"))
+
+	names := func(path string) []string {
+		status, body := do(t, s, http.MethodGet, path, nil)
+		require.Equal(t, http.StatusOK, status)
+		n := make([]string, 0)
+		for _, v := range body["value"].([]any) {
+			n = append(n, v.(map[string]any)["name"].(string))
+		}
+		return n
+	}
+	assert.Equal(t, []string{"sub", "rg"}, names(rg+"/providers/Microsoft.Authorization/roleAssignments"))
+	assert.Equal(t, []string{"sub", "rg"}, names(subScope+"/providers/Microsoft.Authorization/roleAssignments"))
+	assert.Equal(t, []string{"sub"}, names(subScope+"/providers/Microsoft.Authorization/roleAssignments?$filter=atScope()"))
+	assert.Equal(t, []string{"rg"}, names(subScope+"/providers/Microsoft.Authorization/roleAssignments?$filter=assignedTo('p2')"))
+}
+
+func TestFeatureRegistration(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	sub := s.AddSubscription("test")
+	feature := "/subscriptions/" + sub.ID + "/providers/Microsoft.Features/providers/My.Rp/features/feature1"
+	state := func() string {
+		status, body := do(t, s, http.MethodGet, feature, nil)
+		require.Equal(t, http.StatusOK, status)
+		return body["properties"].(map[string]any)["state"].(string)
+	}
+
+	assert.Equal(t, "NotRegistered", state())
+	status, _ := do(t, s, http.MethodPost, feature+"/register", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Registered", state())
+}
+
+func TestLocksPreventDelete(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	sub := s.AddSubscription("test")
+	rg := "/subscriptions/" + sub.ID + "/resourceGroups/rg"
+	s.PutResource(rg, map[string]any{"location": "westeurope"})
+	s.PutResource(rg+"/providers/Microsoft.Network/virtualNetworks/vnet", map[string]any{})
+	s.PutResource(rg+"/providers/Microsoft.Authorization/locks/lock-rg", map[string]any{"properties": map[string]any{"level": "CanNotDelete"}})
+	s.PutResource("/subscriptions/"+sub.ID+"/providers/Microsoft.Authorization/locks/sub", map[string]any{"properties": map[string]any{"level": "CanNotDelete"}})
+
+	status, body := do(t, s, http.MethodGet, "/subscriptions/"+sub.ID+"/providers/Microsoft.Authorization/locks?api-version=2016-09-01", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, body["value"], 2)
+	status, body = do(t, s, http.MethodGet, rg+"/providers/Microsoft.Authorization/locks?api-version=2016-09-01", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, body["value"], 1)
+
+	status, body = do(t, s, http.MethodDelete, rg+"/providers/Microsoft.Network/virtualNetworks/vnet", nil)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Equal(t, "ScopeLocked", body["error"].(map[string]any)["code"])
+
+	status, _ = do(t, s, http.MethodDelete, "/subscriptions/"+sub.ID+"/providers/Microsoft.Authorization/locks/sub", nil)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = do(t, s, http.MethodDelete, rg, nil)
+	assert.Equal(t, http.StatusConflict, status, "a lock beneath the resource group prevents its deletion")
+	status, _ = do(t, s, http.MethodDelete, rg+"/providers/Microsoft.Authorization/locks/lock-rg", nil)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = do(t, s, http.MethodDelete, rg, nil)
+	assert.Equal(t, http.StatusAccepted, status)
+}
+
+func TestHubConnectionPreventsVirtualNetworkDelete(t *testing.T) {
+	t.Parallel()
+	s := NewServer(t)
+	hubSub := s.AddSubscription("hub")
+	spokeSub := s.AddSubscription("spoke")
+	hubRg := "/subscriptions/" + hubSub.ID + "/resourceGroups/hub"
+	spokeRg := "/subscriptions/" + spokeSub.ID + "/resourceGroups/spoke"
+	spokeVnet := spokeRg + "/providers/Microsoft.Network/virtualNetworks/vnet"
+	s.PutResource(hubRg, map[string]any{"location": "westeurope"})
+	s.PutResource(hubRg+"/providers/Microsoft.Network/virtualHubs/vhub", map[string]any{})
+	s.PutResource(spokeRg, map[string]any{"location": "westeurope"})
+	s.PutResource(spokeVnet, map[string]any{})
+	conn := hubRg + "/providers/Microsoft.Network/virtualHubs/vhub/hubVirtualNetworkConnections/spoke"
+	s.PutResource(conn, map[string]any{"properties": map[string]any{"remoteVirtualNetwork": map[string]any{"id": spokeVnet}}})
+
+	status, body := do(t, s, http.MethodGet, "/subscriptions/"+spokeSub.ID+"/providers/Microsoft.Network/virtualNetworks", nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, body["value"], 1)
+
+	status, body = do(t, s, http.MethodDelete, spokeRg, nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "InUseVirtualNetworkCannotBeDeleted", body["error"].(map[string]any)["code"])
+
+	status, _ = do(t, s, http.MethodDelete, conn, nil)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = do(t, s, http.MethodDelete, spokeRg, nil)
+	assert.Equal(t, http.StatusAccepted, status)
+}
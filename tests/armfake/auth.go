@@ -0,0 +1,122 @@
+package armfake
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// Credential is an azcore.TokenCredential that returns tokens accepted by the fake.
+// The fake does not validate tokens, so no request is made to obtain one.
+type Credential struct{}
+
+var _ azcore.TokenCredential = Credential{}
+
+// GetToken returns a static access token valid for one hour.
+func (Credential) GetToken(_ context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	exp := time.Now().Add(time.Hour)
+	return azcore.AccessToken{
+		Token:     accessToken(DefaultTenantID, DefaultPrincipalID, exp),
+		ExpiresOn: exp,
+	}, nil
+}
+
+// accessToken returns an unsigned JWT carrying the claims that the providers read
+// from the token, e.g. the object id used by azurerm_client_config.
+func accessToken(tenantID, principalID string, exp time.Time) string {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]any{"alg": "none", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"aud":   "armfake",
+		"iss":   "armfake",
+		"appid": principalID,
+		"oid":   principalID,
+		"sub":   principalID,
+		"tid":   tenantID,
+		"iat":   time.Now().Unix(),
+		"nbf":   time.Now().Unix(),
+		"exp":   exp.Unix(),
+	})
+	return enc.EncodeToString(header) + "." + enc.EncodeToString(claims) + "."
+}
+
+// handleAuth serves the token, OpenID discovery and cloud metadata endpoints
+// so that the fake can act as both the authority host and resource manager.
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request, path string) bool {
+	segs := segments(path)
+	switch {
+	case path == "/metadata/endpoints":
+		writeJSON(w, http.StatusOK, []any{s.metadata()})
+		return true
+
+	case strings.HasSuffix(path, "/discovery/instance"):
+		host := strings.TrimPrefix(s.URL, "https://")
+		writeJSON(w, http.StatusOK, map[string]any{
+			"tenant_discovery_endpoint": s.URL + "/" + s.TenantID + "/v2.0/.well-known/openid-configuration",
+			"api-version":               "1.1",
+			"metadata": []any{
+				map[string]any{
+					"preferred_network": host,
+					"preferred_cache":   host,
+					"aliases":           []string{host},
+				},
+			},
+		})
+		return true
+
+	case strings.HasSuffix(path, "/.well-known/openid-configuration") && len(segs) > 0:
+		tenant := segs[0]
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                        s.URL + "/" + tenant + "/v2.0",
+			"authorization_endpoint":        s.URL + "/" + tenant + "/oauth2/v2.0/authorize",
+			"token_endpoint":                s.URL + "/" + tenant + "/oauth2/v2.0/token",
+			"device_authorization_endpoint": s.URL + "/" + tenant + "/oauth2/v2.0/devicecode",
+			"tenant_region_scope":           "WW",
+		})
+		return true
+
+	case len(segs) >= 3 && segs[1] == "oauth2" && segs[len(segs)-1] == "token":
+		exp := time.Now().Add(time.Hour)
+		writeJSON(w, http.StatusOK, map[string]any{
+			"token_type":     "Bearer",
+			"expires_in":     3600,
+			"ext_expires_in": 3600,
+			"expires_on":     exp.Unix(),
+			"access_token":   accessToken(s.TenantID, s.PrincipalID, exp),
+		})
+		return true
+	}
+	return false
+}
+
+// metadata returns the cloud metadata document used by the azurerm provider
+// when metadata_host is set.
+func (s *Server) metadata() map[string]any {
+	host := strings.TrimPrefix(s.URL, "https://")
+	return map[string]any{
+		"name":                     "ARMFake",
+		"portal":                   s.URL,
+		"resourceManager":          s.URL + "/",
+		"microsoftGraphResourceId": s.URL + "/",
+		"graph":                    s.URL + "/",
+		"graphAudience":            s.URL,
+		"authentication": map[string]any{
+			"loginEndpoint":    s.URL + "/",
+			"audiences":        []string{s.URL, s.URL + "/"},
+			"tenant":           "common",
+			"identityProvider": "AAD",
+		},
+		"suffixes": map[string]any{
+			"storage":           host,
+			"keyVaultDns":       host,
+			"acrLoginServer":    host,
+			"sqlServerHostname": host,
+		},
+	}
+}
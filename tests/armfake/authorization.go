@@ -0,0 +1,140 @@
+package armfake
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// roleDefinition is a built-in role definition served by the fake.
+type roleDefinition struct {
+	id   string
+	name string
+}
+
+// builtInRoleDefinitions returns the built-in roles used by the module tests.
+func builtInRoleDefinitions() []roleDefinition {
+	return []roleDefinition{
+		{id: "8e3af657-a8ff-443c-a75c-2fe8c4bcb635", name: "Owner"},
+		{id: "b24988ac-6180-42a0-ab88-20f7382dd24c", name: "Contributor"},
+		{id: "acdd72a7-3385-48ef-bd42-f606fba81ae7", name: "Reader"},
+		{id: "18d7d88d-d35e-4fb5-a5c3-7773c20a72d9", name: "User Access Administrator"},
+		{id: "ba92f5b4-2d11-453d-a403-e96b0029c9fe", name: "Storage Blob Data Contributor"},
+	}
+}
+
+// roleAssignmentType is the resource type of role assignments.
+const roleAssignmentType = "Microsoft.Authorization/roleAssignments"
+
+var (
+	roleNameFilterRegex    = regexp.MustCompile(`roleName eq '([^']+)'`)
+	principalIdFilterRegex = regexp.MustCompile(`(?:principalId eq '([^']+)'|assignedTo\('([^']+)'\))`)
+)
+
+// handleRoleDefinitions serves the role definitions API for the built-in roles.
+// Role definitions can be listed, filtered by role name, or retrieved by id at any scope.
+func (s *Server) handleRoleDefinitions(w http.ResponseWriter, r *http.Request, path string) bool {
+	const marker = "/providers/microsoft.authorization/roledefinitions"
+	idx := strings.Index(strings.ToLower(path), marker)
+	if idx < 0 || r.Method != http.MethodGet {
+		return false
+	}
+	scope := path[:idx]
+	rest := strings.Trim(path[idx+len(marker):], "/")
+
+	if rest != "" {
+		for _, rd := range s.roleDefinitions {
+			if strings.EqualFold(rd.id, rest) {
+				writeJSON(w, http.StatusOK, rd.body(scope))
+				return true
+			}
+		}
+		writeError(w, http.StatusNotFound, "RoleDefinitionDoesNotExist", "The specified role definition with ID '%s' does not exist.", rest)
+		return true
+	}
+
+	var name string
+	if m := roleNameFilterRegex.FindStringSubmatch(r.URL.Query().Get("$filter")); m != nil {
+		name = m[1]
+	}
+	values := make([]any, 0)
+	for _, rd := range s.roleDefinitions {
+		if name != "" && !strings.EqualFold(rd.name, name) {
+			continue
+		}
+		values = append(values, rd.body(scope))
+	}
+	s.writePage(w, r, values)
+	return true
+}
+
+// body returns the ARM representation of the role definition at the supplied scope.
+func (rd roleDefinition) body(scope string) map[string]any {
+	return map[string]any{
+		"id":   scope + "/providers/Microsoft.Authorization/roleDefinitions/" + rd.id,
+		"name": rd.id,
+		"type": "Microsoft.Authorization/roleDefinitions",
+		"properties": map[string]any{
+			"roleName":         rd.name,
+			"type":             "BuiltInRole",
+			"assignableScopes": []string{"/"},
+			"permissions": []any{
+				map[string]any{
+					"actions":        []string{"*"},
+					"notActions":     []string{},
+					"dataActions":    []string{},
+					"notDataActions": []string{},
+				},
+			},
+		},
+	}
+}
+
+// handleRoleAssignments serves the list of role assignments at a scope, which, as in ARM, includes
+// the assignments at the scope, at the scopes above it and, unless filtered by atScope(), at the scopes beneath it.
+// The principalId eq and assignedTo filters are supported. Management group hierarchies are not modelled,
+// so only the scopes within the resource id are above it.
+// Role assignments are otherwise stored, retrieved and deleted as generic resources.
+func (s *Server) handleRoleAssignments(w http.ResponseWriter, r *http.Request, path string) bool {
+	const marker = "/providers/microsoft.authorization/roleassignments"
+	if r.Method != http.MethodGet || !strings.HasSuffix(strings.ToLower(path), marker) {
+		return false
+	}
+	scope := strings.ToLower(strings.TrimSuffix(canonicalID(path[:len(path)-len(marker)]), "/"))
+	if status, code, msg := s.checkScope(scope + "/providers/Microsoft.Authorization/roleAssignments/x"); status != 0 {
+		writeError(w, status, code, "%s", msg)
+		return true
+	}
+	filter := r.URL.Query().Get("$filter")
+	atScope := strings.Contains(filter, "atScope()")
+	var principal string
+	if m := principalIdFilterRegex.FindStringSubmatch(filter); m != nil {
+		principal = m[1] + m[2]
+	}
+
+	ids := make([]string, 0)
+	for k, v := range s.resources {
+		if !strings.EqualFold(resourceType(k), roleAssignmentType) {
+			continue
+		}
+		props := v["properties"].(map[string]any)
+		if p, _ := props["principalId"].(string); principal != "" && !strings.EqualFold(p, principal) {
+			continue
+		}
+		at := strings.ToLower(strings.TrimSuffix(parentID(k), "/"))
+		above := at == "" || at == scope || strings.HasPrefix(scope, at+"/")
+		beneath := strings.HasPrefix(at, scope+"/")
+		if above || (beneath && !atScope) {
+			ids = append(ids, v["id"].(string))
+		}
+	}
+	sort.Strings(ids)
+	values := make([]any, 0, len(ids))
+	for _, id := range ids {
+		res, _ := s.getResource(id)
+		values = append(values, res)
+	}
+	s.writePage(w, r, values)
+	return true
+}
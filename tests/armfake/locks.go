@@ -0,0 +1,67 @@
+package armfake
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// lockType is the resource type of management locks.
+const lockType = "Microsoft.Authorization/locks"
+
+// handleLocks serves the list of management locks at a scope, which, as in ARM,
+// includes the locks at the scope and at all scopes beneath it.
+// Locks are otherwise stored, retrieved and deleted as generic resources.
+func (s *Server) handleLocks(w http.ResponseWriter, r *http.Request, path string) bool {
+	const marker = "/providers/microsoft.authorization/locks"
+	if r.Method != http.MethodGet || !strings.HasSuffix(strings.ToLower(path), marker) {
+		return false
+	}
+	scope := path[:len(path)-len(marker)]
+	if status, code, msg := s.checkScope(scope + "/providers/Microsoft.Authorization/locks/x"); status != 0 {
+		writeError(w, status, code, "%s", msg)
+		return true
+	}
+	values := make([]any, 0)
+	for _, id := range s.lockIDs(scope) {
+		res, _ := s.getResource(id)
+		values = append(values, res)
+	}
+	s.writePage(w, r, values)
+	return true
+}
+
+// lockIDs returns the ids of the locks at, or beneath, the scope, sorted.
+// An empty scope returns all locks.
+func (s *Server) lockIDs(scope string) []string {
+	prefix := strings.TrimSuffix(strings.ToLower(canonicalID(scope)), "/")
+	ids := make([]string, 0)
+	for k, v := range s.resources {
+		if !strings.EqualFold(resourceType(k), lockType) {
+			continue
+		}
+		if prefix == "" || strings.HasPrefix(k, prefix+"/") {
+			ids = append(ids, v["id"].(string))
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// deleteLockedBy returns the scopes of the locks that prevent the resource from being deleted:
+// those at the resource or any scope above it, and those beneath it, as these resources are deleted with it.
+// Locks are not themselves prevented from being deleted.
+func (s *Server) deleteLockedBy(id string) []string {
+	if strings.EqualFold(resourceType(id), lockType) {
+		return nil
+	}
+	key := strings.ToLower(canonicalID(id))
+	scopes := make([]string, 0)
+	for _, lockID := range s.lockIDs("") {
+		scope := strings.ToLower(parentID(lockID))
+		if key == scope || strings.HasPrefix(key, scope+"/") || strings.HasPrefix(scope, key+"/") {
+			scopes = append(scopes, parentID(lockID))
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,170 @@
+package armfake
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// entityNameFilterRe matches the name equality filter of the entities API, e.g. name eq 'mg1'.
+var entityNameFilterRe = regexp.MustCompile(`(?i)^name eq '([^']+)'$`)
+
+// handleManagementGroups serves the creation and deletion of management groups, and the entities API.
+// Management groups are otherwise retrieved as generic resources.
+// As in ARM, a management group with children cannot be deleted.
+func (s *Server) handleManagementGroups(w http.ResponseWriter, r *http.Request, path string) bool {
+	segs := segments(path)
+	if len(segs) < 3 || !strings.EqualFold(segs[0], "providers") || !strings.EqualFold(segs[1], "Microsoft.Management") {
+		return false
+	}
+
+	// POST /providers/Microsoft.Management/getEntities
+	if len(segs) == 3 && strings.EqualFold(segs[2], "getEntities") && r.Method == http.MethodPost {
+		s.writePage(w, r, s.entities(r.URL.Query().Get("$filter")))
+		return true
+	}
+	if len(segs) != 4 || !strings.EqualFold(segs[2], "managementGroups") {
+		return false
+	}
+
+	name := segs[3]
+	switch r.Method {
+	case http.MethodPut:
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", "The request content was invalid: %v", err)
+			return true
+		}
+		props, _ := body["properties"].(map[string]any)
+		details, _ := props["details"].(map[string]any)
+		parentInfo, _ := details["parent"].(map[string]any)
+		parentID, _ := parentInfo["id"].(string)
+		parent := parentID[strings.LastIndex(parentID, "/")+1:]
+		if parent != "" {
+			if _, ok := s.resources[strings.ToLower(managementGroupPrefix+parent)]; !ok {
+				writeError(w, http.StatusNotFound, "NotFound", "The management group '%s' could not be found.", parent)
+				return true
+			}
+		}
+		s.putManagementGroup(name, parent)
+		if displayName, _ := props["displayName"].(string); displayName != "" {
+			res := s.resources[strings.ToLower(managementGroupPrefix+name)]
+			res["properties"].(map[string]any)["displayName"] = displayName
+		}
+		res, _ := s.getResource(managementGroupPrefix + name)
+		writeJSON(w, http.StatusOK, res)
+
+	case http.MethodDelete:
+		if _, ok := s.resources[strings.ToLower(managementGroupPrefix+name)]; !ok {
+			writeJSON(w, http.StatusNoContent, nil)
+			return true
+		}
+		if strings.EqualFold(name, s.TenantID) {
+			writeError(w, http.StatusBadRequest, "BadRequest", "The tenant root group cannot be deleted.")
+			return true
+		}
+		if children := s.managementGroupChildren(name); len(children) > 0 {
+			writeError(w, http.StatusBadRequest, "BadRequest",
+				"The management group '%s' cannot be deleted as it has children: %s.", name, strings.Join(children, ","))
+			return true
+		}
+		s.deleteResource(managementGroupPrefix + name)
+		writeJSON(w, http.StatusNoContent, nil)
+
+	default:
+		return false
+	}
+	return true
+}
+
+// managementGroupParent returns the name of the parent of the management group, or an empty string for the tenant root group.
+func (s *Server) managementGroupParent(name string) string {
+	res, ok := s.resources[strings.ToLower(managementGroupPrefix+name)]
+	if !ok {
+		return ""
+	}
+	props, _ := res["properties"].(map[string]any)
+	details, _ := props["details"].(map[string]any)
+	parent, _ := details["parent"].(map[string]any)
+	p, _ := parent["name"].(string)
+	return p
+}
+
+// managementGroupChildren returns the names of the management groups and ids of the subscriptions
+// that are direct children of the management group, sorted.
+func (s *Server) managementGroupChildren(name string) []string {
+	children := make([]string, 0)
+	for _, mg := range s.managementGroupNames() {
+		if strings.EqualFold(s.managementGroupParent(mg), name) {
+			children = append(children, mg)
+		}
+	}
+	for sub, mg := range s.memberships {
+		if strings.EqualFold(mg, name) {
+			children = append(children, sub)
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// managementGroupNames returns the names of all management groups, sorted.
+func (s *Server) managementGroupNames() []string {
+	prefix := strings.ToLower(managementGroupPrefix)
+	names := make([]string, 0)
+	for k, v := range s.resources {
+		if strings.HasPrefix(k, prefix) && !strings.Contains(k[len(prefix):], "/") {
+			names = append(names, v["name"].(string))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// entities returns the management groups and subscriptions as returned by the entities API,
+// limited to the entity with the name of an optional name equality filter.
+func (s *Server) entities(filter string) []any {
+	only := ""
+	if m := entityNameFilterRe.FindStringSubmatch(strings.TrimSpace(filter)); m != nil {
+		only = m[1]
+	}
+	entity := func(id, name, typ, displayName, parent string) map[string]any {
+		chain := make([]any, 0)
+		for p := parent; p != ""; p = s.managementGroupParent(p) {
+			chain = append([]any{p}, chain...)
+		}
+		props := map[string]any{
+			"tenantId":        s.TenantID,
+			"displayName":     displayName,
+			"parentNameChain": chain,
+		}
+		if parent != "" {
+			props["parent"] = map[string]any{"id": managementGroupPrefix + parent}
+		}
+		return map[string]any{"id": id, "name": name, "type": typ, "properties": props}
+	}
+
+	values := make([]any, 0)
+	for _, mg := range s.managementGroupNames() {
+		if only != "" && !strings.EqualFold(mg, only) {
+			continue
+		}
+		res := s.resources[strings.ToLower(managementGroupPrefix+mg)]
+		displayName, _ := res["properties"].(map[string]any)["displayName"].(string)
+		values = append(values, entity(managementGroupPrefix+mg, mg, "Microsoft.Management/managementGroups", displayName, s.managementGroupParent(mg)))
+	}
+	subs := make([]string, 0, len(s.subscriptions))
+	for k := range s.subscriptions {
+		subs = append(subs, k)
+	}
+	sort.Strings(subs)
+	for _, k := range subs {
+		sub := s.subscriptions[k]
+		if only != "" && !strings.EqualFold(sub.ID, only) {
+			continue
+		}
+		values = append(values, entity("/subscriptions/"+sub.ID, sub.ID, "/subscriptions", sub.DisplayName, s.memberships[k]))
+	}
+	return values
+}
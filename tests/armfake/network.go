@@ -0,0 +1,106 @@
+package armfake
+
+import (
+	"sort"
+	"strings"
+)
+
+// putVirtualNetwork applies the virtual network behaviour of ARM to the supplied properties.
+// Subnets supplied in the request body replace the existing subnets.
+// As with ARM, omitting the subnets property removes all existing subnets.
+// Peerings are child resources and are never returned inline from the stored body.
+func (s *Server) putVirtualNetwork(id string, props map[string]any) {
+	subnets, _ := props["subnets"].([]any)
+	delete(props, "subnets")
+	delete(props, "virtualNetworkPeerings")
+
+	keep := make(map[string]bool)
+	for _, sn := range subnets {
+		snm, ok := sn.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := snm["name"].(string)
+		if name == "" {
+			continue
+		}
+		snID := id + "/subnets/" + name
+		keep[strings.ToLower(snID)] = true
+		s.putResource(snID, snm)
+	}
+
+	prefix := strings.ToLower(id + "/subnets/")
+	for k := range s.resources {
+		if strings.HasPrefix(k, prefix) && !keep[k] {
+			delete(s.resources, k)
+		}
+	}
+}
+
+// refreshPeerings recalculates the peering state of every stored peering.
+// A peering is Connected when the remote virtual network has a peering back,
+// Initiated when it has not yet been created and Disconnected when it has been removed.
+func (s *Server) refreshPeerings() {
+	peerings := make(map[string]map[string]any)
+	for k, v := range s.resources {
+		if strings.EqualFold(v["type"].(string), "Microsoft.Network/virtualNetworks/virtualNetworkPeerings") {
+			peerings[k] = v
+		}
+	}
+
+	for k, p := range peerings {
+		props := p["properties"].(map[string]any)
+		local := strings.ToLower(parentID(k))
+		remote := strings.ToLower(remoteVirtualNetworkID(props))
+
+		connected := false
+		for rk, rp := range peerings {
+			if strings.ToLower(parentID(rk)) != remote {
+				continue
+			}
+			if strings.ToLower(remoteVirtualNetworkID(rp["properties"].(map[string]any))) == local {
+				connected = true
+				break
+			}
+		}
+
+		switch {
+		case connected:
+			props["peeringState"] = "Connected"
+			props["peeringSyncLevel"] = "FullyInSync"
+		case props["peeringState"] == "Connected" || props["peeringState"] == "Disconnected":
+			props["peeringState"] = "Disconnected"
+			props["peeringSyncLevel"] = "RemoteNotInSync"
+		default:
+			props["peeringState"] = "Initiated"
+			props["peeringSyncLevel"] = "RemoteNotInSync"
+		}
+	}
+}
+
+// remoteVirtualNetworkID returns the remote virtual network id from peering properties.
+func remoteVirtualNetworkID(props map[string]any) string {
+	rvn, _ := props["remoteVirtualNetwork"].(map[string]any)
+	id, _ := rvn["id"].(string)
+	return canonicalID(id)
+}
+
+// hubConnectionsTo returns the ids of the virtual hub connections to the virtual networks at or beneath the id,
+// which, as in ARM, prevent the virtual networks from being deleted.
+// Connections within the id, e.g. when deleting the resource group of the hub, do not prevent deletion.
+func (s *Server) hubConnectionsTo(id string) []string {
+	key := strings.ToLower(canonicalID(id))
+	within := func(k string) bool { return k == key || strings.HasPrefix(k, key+"/") }
+	conns := make([]string, 0)
+	for k, v := range s.resources {
+		if !strings.EqualFold(v["type"].(string), "Microsoft.Network/virtualHubs/hubVirtualNetworkConnections") || within(k) {
+			continue
+		}
+		props, _ := v["properties"].(map[string]any)
+		if remote := strings.ToLower(remoteVirtualNetworkID(props)); remote != "/" && within(remote) {
+			conns = append(conns, v["id"].(string))
+		}
+	}
+	sort.Strings(conns)
+	return conns
+}
@@ -0,0 +1,377 @@
+package armfake
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// handleResource serves the generic resource CRUD and list operations
+// for any resource id not handled by a more specific handler.
+func (s *Server) handleResource(w http.ResponseWriter, r *http.Request, path string) {
+	if status, code, msg := s.checkScope(path); status != 0 {
+		writeError(w, status, code, "%s", msg)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if res, ok := s.getResource(path); ok {
+			writeJSON(w, http.StatusOK, res)
+			return
+		}
+		if isSubscriptionTypeCollection(path) {
+			s.writePage(w, r, s.listResourcesByType(path))
+			return
+		}
+		if isCollection(path) {
+			s.writePage(w, r, s.listResources(path))
+			return
+		}
+		writeError(w, http.StatusNotFound, notFoundCode(path), "The resource '%s' could not be found.", path)
+
+	case http.MethodHead:
+		if _, ok := s.getResource(path); ok {
+			writeJSON(w, http.StatusNoContent, nil)
+			return
+		}
+		writeJSON(w, http.StatusNotFound, nil)
+
+	case http.MethodPut:
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", "The request content was invalid: %v", err)
+			return
+		}
+		_, exists := s.resources[strings.ToLower(canonicalID(path))]
+		s.putResource(path, body)
+		res, _ := s.getResource(path)
+		if exists {
+			writeJSON(w, http.StatusOK, res)
+			return
+		}
+		writeJSON(w, http.StatusCreated, res)
+
+	case http.MethodPatch:
+		res, ok := s.resources[strings.ToLower(canonicalID(path))]
+		if !ok {
+			writeError(w, http.StatusNotFound, notFoundCode(path), "The resource '%s' could not be found.", path)
+			return
+		}
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", "The request content was invalid: %v", err)
+			return
+		}
+		merged := deepCopy(res)
+		for k, v := range body {
+			if k == "properties" {
+				props, _ := merged["properties"].(map[string]any)
+				patch, _ := v.(map[string]any)
+				for pk, pv := range patch {
+					props[pk] = pv
+				}
+				continue
+			}
+			merged[k] = v
+		}
+		s.putResource(path, merged)
+		res, _ = s.getResource(path)
+		writeJSON(w, http.StatusOK, res)
+
+	case http.MethodDelete:
+		if _, ok := s.resources[strings.ToLower(canonicalID(path))]; !ok {
+			writeJSON(w, http.StatusNoContent, nil)
+			return
+		}
+		if conns := s.hubConnectionsTo(path); len(conns) > 0 {
+			writeError(w, http.StatusBadRequest, "InUseVirtualNetworkCannotBeDeleted",
+				"Virtual network %s cannot be deleted because it is in use by virtual hub connection(s): %s.", path, strings.Join(conns, ","))
+			return
+		}
+		if locked := s.deleteLockedBy(path); len(locked) > 0 {
+			writeError(w, http.StatusConflict, "ScopeLocked",
+				"The scope '%s' cannot perform delete operation because following scope(s) are locked: '%s'. Please remove the lock and try again.",
+				path, strings.Join(locked, ","))
+			return
+		}
+		s.deleteResource(path)
+		if strings.EqualFold(resourceType(path), "Microsoft.Resources/resourceGroups") {
+			s.startOperation(w)
+			return
+		}
+		writeJSON(w, http.StatusOK, nil)
+
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "The operation '%s %s' is not supported by the fake.", r.Method, path)
+	}
+}
+
+// checkScope checks that the subscription, resource group and parent resource of the supplied path exist.
+// It returns a zero status if they do.
+func (s *Server) checkScope(path string) (int, string, string) {
+	segs := segments(path)
+	if len(segs) >= 2 && strings.EqualFold(segs[0], "subscriptions") {
+		if _, ok := s.subscriptions[strings.ToLower(segs[1])]; !ok {
+			return http.StatusNotFound, "SubscriptionNotFound", "The subscription '" + segs[1] + "' could not be found."
+		}
+	}
+	if len(segs) > 4 && strings.EqualFold(segs[2], "resourceGroups") {
+		rg := "/" + strings.Join(segs[:4], "/")
+		if _, ok := s.resources[strings.ToLower(canonicalID(rg))]; !ok {
+			return http.StatusNotFound, "ResourceGroupNotFound", "Resource group '" + segs[3] + "' could not be found."
+		}
+	}
+	if isCollection(path) {
+		path = path[:strings.LastIndex(path, "/")]
+		if strings.HasSuffix(strings.ToLower(parentCollection(path)), "/providers") {
+			return 0, "", ""
+		}
+	} else {
+		path = parentID(path)
+	}
+	if path == "" || isScopeRoot(path) {
+		return 0, "", ""
+	}
+	if _, ok := s.resources[strings.ToLower(canonicalID(path))]; !ok {
+		return http.StatusNotFound, "ParentResourceNotFound", "The parent resource '" + path + "' could not be found."
+	}
+	return 0, "", ""
+}
+
+// putResource stores the resource, adding the common ARM properties
+// and applying any resource type specific behaviour.
+func (s *Server) putResource(id string, body map[string]any) {
+	id = canonicalID(id)
+	res := deepCopy(body)
+	res["id"] = id
+	res["name"] = id[strings.LastIndex(id, "/")+1:]
+	res["type"] = resourceType(id)
+	props, _ := res["properties"].(map[string]any)
+	if props == nil {
+		props = make(map[string]any)
+		res["properties"] = props
+	}
+	props["provisioningState"] = "Succeeded"
+
+	switch strings.ToLower(resourceType(id)) {
+	case "microsoft.network/virtualnetworks":
+		s.putVirtualNetwork(id, props)
+	case "microsoft.authorization/roleassignments":
+		props["scope"] = parentID(id)
+	}
+
+	s.resources[strings.ToLower(id)] = res
+	s.refreshPeerings()
+}
+
+// getResource returns a copy of the stored resource, with any child resources
+// that ARM returns inline added to its properties.
+func (s *Server) getResource(id string) (map[string]any, bool) {
+	id = canonicalID(id)
+	res, ok := s.resources[strings.ToLower(id)]
+	if !ok {
+		return nil, false
+	}
+	res = deepCopy(res)
+	if strings.EqualFold(resourceType(id), "Microsoft.Network/virtualNetworks") {
+		props := res["properties"].(map[string]any)
+		props["subnets"] = s.listResources(id + "/subnets")
+		props["virtualNetworkPeerings"] = s.listResources(id + "/virtualNetworkPeerings")
+	}
+	return res, true
+}
+
+// deleteResource removes the resource and all of its children.
+func (s *Server) deleteResource(id string) {
+	key := strings.ToLower(canonicalID(id))
+	for k := range s.resources {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			delete(s.resources, k)
+		}
+	}
+	s.refreshPeerings()
+}
+
+// listResources returns the resources that are direct members of the supplied collection, sorted by id.
+func (s *Server) listResources(collection string) []any {
+	collection = strings.ToLower(canonicalID(collection))
+	keys := make([]string, 0)
+	for k := range s.resources {
+		if parentCollection(k) == collection {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	values := make([]any, 0, len(keys))
+	for _, k := range keys {
+		res, _ := s.getResource(k)
+		values = append(values, res)
+	}
+	return values
+}
+
+// listResourcesByType returns the resources of the top level type in the subscription, in any resource group, sorted by id.
+// The path is the collection of the type at subscription scope, e.g. /subscriptions/{id}/providers/Microsoft.Network/virtualNetworks.
+func (s *Server) listResourcesByType(path string) []any {
+	segs := segments(path)
+	prefix := strings.ToLower("/subscriptions/" + segs[1] + "/")
+	typ := segs[3] + "/" + segs[4]
+	keys := make([]string, 0)
+	for k := range s.resources {
+		if strings.HasPrefix(k, prefix) && strings.EqualFold(resourceType(k), typ) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	values := make([]any, 0, len(keys))
+	for _, k := range keys {
+		res, _ := s.getResource(k)
+		values = append(values, res)
+	}
+	return values
+}
+
+// writePage writes the list response, splitting the values into pages of PageSize
+// and returning a nextLink for the following page.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, values []any) {
+	if values == nil {
+		values = []any{}
+	}
+	if s.PageSize <= 0 {
+		writeJSON(w, http.StatusOK, map[string]any{"value": values})
+		return
+	}
+	q := r.URL.Query()
+	skip, _ := strconv.Atoi(q.Get("$skiptoken"))
+	if skip > len(values) {
+		skip = len(values)
+	}
+	end := skip + s.PageSize
+	if end > len(values) {
+		end = len(values)
+	}
+	resp := map[string]any{"value": values[skip:end]}
+	if end < len(values) {
+		q.Set("$skiptoken", strconv.Itoa(end))
+		next := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		resp["nextLink"] = s.URL + next.String()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// canonicalID normalises the casing of well known segments of a resource id.
+func canonicalID(id string) string {
+	segs := segments(id)
+	for i, seg := range segs {
+		switch strings.ToLower(seg) {
+		case "subscriptions":
+			segs[i] = "subscriptions"
+		case "resourcegroups":
+			segs[i] = "resourceGroups"
+		case "providers":
+			segs[i] = "providers"
+		}
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+// resourceType returns the fully qualified resource type of the supplied id,
+// e.g. Microsoft.Network/virtualNetworks/subnets.
+func resourceType(id string) string {
+	segs := segments(id)
+	ns := ""
+	types := make([]string, 0)
+	for i := 0; i < len(segs); i += 2 {
+		if strings.EqualFold(segs[i], "providers") && i+1 < len(segs) {
+			ns = segs[i+1]
+			types = types[:0]
+			continue
+		}
+		if ns != "" {
+			types = append(types, segs[i])
+		}
+	}
+	if ns == "" {
+		switch len(segs) {
+		case 2:
+			return "Microsoft.Resources/subscriptions"
+		case 4:
+			return "Microsoft.Resources/resourceGroups"
+		}
+		return ""
+	}
+	return ns + "/" + strings.Join(types, "/")
+}
+
+// parentID returns the id of the resource or scope that contains the supplied resource id.
+func parentID(id string) string {
+	segs := segments(id)
+	if len(segs) < 2 {
+		return ""
+	}
+	segs = segs[:len(segs)-2]
+	if len(segs) >= 2 && strings.EqualFold(segs[len(segs)-2], "providers") {
+		segs = segs[:len(segs)-2]
+	}
+	if len(segs) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+// parentCollection returns the collection that the supplied id is a member of.
+func parentCollection(id string) string {
+	return id[:strings.LastIndex(id, "/")]
+}
+
+// isCollection returns true if the path refers to a collection of resources rather than a single resource.
+func isCollection(path string) bool {
+	segs := segments(path)
+	i := 0
+	for i < len(segs) {
+		if strings.EqualFold(segs[i], "providers") {
+			i += 2
+			continue
+		}
+		if i+1 >= len(segs) {
+			return true
+		}
+		i += 2
+	}
+	return false
+}
+
+// isSubscriptionTypeCollection returns true if the path lists a top level resource type across the subscription,
+// e.g. /subscriptions/{id}/providers/Microsoft.Network/virtualNetworks.
+func isSubscriptionTypeCollection(path string) bool {
+	segs := segments(path)
+	return len(segs) == 5 && strings.EqualFold(segs[0], "subscriptions") && strings.EqualFold(segs[2], "providers")
+}
+
+// isScopeRoot returns true if the path is a subscription or resource group,
+// which are checked separately to other parent resources.
+func isScopeRoot(path string) bool {
+	segs := segments(path)
+	return (len(segs) == 2 && strings.EqualFold(segs[0], "subscriptions")) ||
+		(len(segs) == 4 && strings.EqualFold(segs[2], "resourceGroups"))
+}
+
+// notFoundCode returns the ARM error code for a missing resource.
+func notFoundCode(path string) string {
+	if strings.EqualFold(resourceType(path), "Microsoft.Resources/resourceGroups") {
+		return "ResourceGroupNotFound"
+	}
+	return "ResourceNotFound"
+}
+
+// deepCopy returns a deep copy of the supplied JSON object.
+func deepCopy(in map[string]any) map[string]any {
+	b, _ := json.Marshal(in)
+	out := make(map[string]any)
+	_ = json.Unmarshal(b, &out)
+	return out
+}
@@ -0,0 +1,425 @@
+package armfake
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	managementGroupPrefix = "/providers/Microsoft.Management/managementGroups/"
+	aliasPrefix           = "/providers/Microsoft.Subscription/aliases"
+)
+
+// handleSubscription serves the subscriptions API, including cancellation and the tags of the subscriptions.
+func (s *Server) handleSubscription(w http.ResponseWriter, r *http.Request, path string) bool {
+	segs := segments(path)
+	if len(segs) == 0 || !strings.EqualFold(segs[0], "subscriptions") {
+		return false
+	}
+
+	switch {
+	case len(segs) == 1 && r.Method == http.MethodGet:
+		ids := make([]string, 0, len(s.subscriptions))
+		for k := range s.subscriptions {
+			ids = append(ids, k)
+		}
+		sort.Strings(ids)
+		values := make([]any, 0, len(ids))
+		for _, id := range ids {
+			values = append(values, s.subscriptionBody(s.subscriptions[id]))
+		}
+		s.writePage(w, r, values)
+		return true
+
+	case len(segs) == 2 && r.Method == http.MethodGet:
+		sub, ok := s.subscriptions[strings.ToLower(segs[1])]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SubscriptionNotFound", "The subscription '%s' could not be found.", segs[1])
+			return true
+		}
+		writeJSON(w, http.StatusOK, s.subscriptionBody(sub))
+		return true
+
+	case len(segs) == 6 && r.Method == http.MethodGet &&
+		strings.EqualFold(segs[2], "providers") &&
+		strings.EqualFold(segs[3], "Microsoft.Resources") &&
+		strings.EqualFold(segs[4], "tags") &&
+		strings.EqualFold(segs[5], "default"):
+		sub, ok := s.subscriptions[strings.ToLower(segs[1])]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SubscriptionNotFound", "The subscription '%s' could not be found.", segs[1])
+			return true
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":         "/subscriptions/" + sub.ID + "/providers/Microsoft.Resources/tags/default",
+			"name":       "default",
+			"type":       "Microsoft.Resources/tags",
+			"properties": map[string]any{"tags": sub.Tags},
+		})
+		return true
+
+	case len(segs) == 5 && r.Method == http.MethodPost &&
+		strings.EqualFold(segs[2], "providers") &&
+		strings.EqualFold(segs[3], "Microsoft.Subscription") &&
+		strings.EqualFold(segs[4], "cancel"):
+		sub, ok := s.subscriptions[strings.ToLower(segs[1])]
+		if !ok {
+			writeError(w, http.StatusNotFound, "SubscriptionNotFound", "The subscription '%s' could not be found.", segs[1])
+			return true
+		}
+		if sub.State != "Enabled" {
+			writeError(w, http.StatusConflict, "SubscriptionNotActive", "Subscription is not in active state.")
+			return true
+		}
+		sub.State = "Warned"
+		writeJSON(w, http.StatusOK, map[string]any{"subscriptionId": sub.ID})
+		return true
+	}
+	return false
+}
+
+// subscriptionBody returns the ARM representation of the subscription.
+func (s *Server) subscriptionBody(sub *Subscription) map[string]any {
+	return map[string]any{
+		"id":                  "/subscriptions/" + sub.ID,
+		"subscriptionId":      sub.ID,
+		"displayName":         sub.DisplayName,
+		"state":               sub.State,
+		"tenantId":            s.TenantID,
+		"tags":                sub.Tags,
+		"authorizationSource": "RoleBased",
+		"subscriptionPolicies": map[string]any{
+			"locationPlacementId": "Public_2014-09-01",
+			"quotaId":             "EnterpriseAgreement_2014-09-01",
+			"spendingLimit":       "Off",
+		},
+	}
+}
+
+// handleAlias serves the subscription alias API.
+// Creating an alias creates a new subscription, unless an existing subscription id is supplied.
+func (s *Server) handleAlias(w http.ResponseWriter, r *http.Request, path string) bool {
+	if !strings.HasPrefix(strings.ToLower(path), strings.ToLower(aliasPrefix)) {
+		return false
+	}
+	segs := segments(path)
+	if len(segs) == 3 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The method '%s' is not allowed.", r.Method)
+			return true
+		}
+		names := make([]string, 0, len(s.aliases))
+		for k := range s.aliases {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		values := make([]any, 0, len(names))
+		for _, n := range names {
+			values = append(values, s.aliases[n])
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"value": values})
+		return true
+	}
+	if len(segs) != 4 {
+		return false
+	}
+
+	name := segs[3]
+	key := strings.ToLower(name)
+	switch r.Method {
+	case http.MethodGet:
+		alias, ok := s.aliases[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "ResourceNotFound", "The subscription alias '%s' could not be found.", name)
+			return true
+		}
+		writeJSON(w, http.StatusOK, alias)
+
+	case http.MethodDelete:
+		if _, ok := s.aliases[key]; !ok {
+			writeJSON(w, http.StatusNoContent, nil)
+			return true
+		}
+		delete(s.aliases, key)
+		writeJSON(w, http.StatusOK, nil)
+
+	case http.MethodPut:
+		if alias, ok := s.aliases[key]; ok {
+			writeJSON(w, http.StatusOK, alias)
+			return true
+		}
+		body, err := readBody(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "InvalidRequestContent", "The request content was invalid: %v", err)
+			return true
+		}
+		props, _ := body["properties"].(map[string]any)
+		if props == nil {
+			props = make(map[string]any)
+		}
+		additional, _ := props["additionalProperties"].(map[string]any)
+
+		subID, _ := props["subscriptionId"].(string)
+		displayName, _ := props["displayName"].(string)
+		if displayName == "" {
+			displayName = name
+		}
+		sub, ok := s.subscriptions[strings.ToLower(subID)]
+		if !ok {
+			if subID == "" {
+				subID = uuid.NewString()
+			}
+			sub = s.addSubscription(subID, displayName)
+		}
+		if mg, _ := additional["managementGroupId"].(string); mg != "" {
+			mgName := mg[strings.LastIndex(mg, "/")+1:]
+			if _, ok := s.resources[strings.ToLower(managementGroupPrefix+mgName)]; !ok {
+				writeError(w, http.StatusNotFound, "ManagementGroupNotFound", "The management group '%s' could not be found.", mgName)
+				return true
+			}
+			s.memberships[strings.ToLower(sub.ID)] = mgName
+		}
+		if tags, ok := additional["tags"].(map[string]any); ok {
+			for k, v := range tags {
+				if vs, ok := v.(string); ok {
+					sub.Tags[k] = vs
+				}
+			}
+		}
+
+		alias := s.aliasBody(name, sub)
+		aliasProps := alias["properties"].(map[string]any)
+		aliasProps["displayName"] = displayName
+		aliasProps["billingScope"] = props["billingScope"]
+		aliasProps["workload"] = props["workload"]
+		s.aliases[key] = alias
+		writeJSON(w, http.StatusCreated, alias)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The method '%s' is not allowed.", r.Method)
+	}
+	return true
+}
+
+// aliasBody returns the ARM representation of an alias for the subscription.
+func (s *Server) aliasBody(name string, sub *Subscription) map[string]any {
+	return map[string]any{
+		"id":   aliasPrefix + "/" + name,
+		"name": name,
+		"type": "Microsoft.Subscription/aliases",
+		"properties": map[string]any{
+			"subscriptionId":    sub.ID,
+			"displayName":       sub.DisplayName,
+			"provisioningState": "Succeeded",
+			"createdTime":       sub.CreatedTime.Format(time.RFC3339),
+			"managementGroupId": managementGroupPrefix + s.memberships[strings.ToLower(sub.ID)],
+		},
+	}
+}
+
+// putManagementGroup stores a management group in the resource store.
+// An empty parent places the group beneath the tenant root group.
+func (s *Server) putManagementGroup(name, parent string) {
+	if parent == "" && name != s.TenantID {
+		parent = s.TenantID
+	}
+	details := map[string]any{}
+	if parent != "" {
+		details["parent"] = map[string]any{
+			"id":   managementGroupPrefix + parent,
+			"name": parent,
+		}
+	}
+	s.putResource(managementGroupPrefix+name, map[string]any{
+		"properties": map[string]any{
+			"displayName": name,
+			"tenantId":    s.TenantID,
+			"details":     details,
+		},
+	})
+}
+
+// handleManagementGroupSubscription serves the management group subscriptions API.
+// A subscription is a direct child of exactly one management group,
+// so creating a membership moves the subscription.
+func (s *Server) handleManagementGroupSubscription(w http.ResponseWriter, r *http.Request, path string) bool {
+	segs := segments(path)
+	if len(segs) != 6 ||
+		!strings.EqualFold(segs[0], "providers") ||
+		!strings.EqualFold(segs[1], "Microsoft.Management") ||
+		!strings.EqualFold(segs[2], "managementGroups") ||
+		!strings.EqualFold(segs[4], "subscriptions") {
+		return false
+	}
+
+	mg, subID := segs[3], segs[5]
+	if _, ok := s.resources[strings.ToLower(managementGroupPrefix+mg)]; !ok {
+		writeError(w, http.StatusNotFound, "NotFound", "The management group '%s' could not be found.", mg)
+		return true
+	}
+	sub, ok := s.subscriptions[strings.ToLower(subID)]
+	if !ok {
+		writeError(w, http.StatusNotFound, "SubscriptionNotFound", "The subscription '%s' could not be found.", subID)
+		return true
+	}
+
+	body := map[string]any{
+		"id":   managementGroupPrefix + mg + "/subscriptions/" + sub.ID,
+		"type": "Microsoft.Management/managementGroups/subscriptions",
+		"name": sub.ID,
+		"properties": map[string]any{
+			"displayName": sub.DisplayName,
+			"state":       "Active",
+			"tenant":      s.TenantID,
+			"parent": map[string]any{
+				"id": managementGroupPrefix + mg,
+			},
+		},
+	}
+
+	key := strings.ToLower(sub.ID)
+	switch r.Method {
+	case http.MethodGet:
+		if !strings.EqualFold(s.memberships[key], mg) {
+			writeError(w, http.StatusNotFound, "NotFound", "The subscription '%s' is not a child of management group '%s'.", subID, mg)
+			return true
+		}
+		writeJSON(w, http.StatusOK, body)
+	case http.MethodPut:
+		s.memberships[key] = mg
+		writeJSON(w, http.StatusOK, body)
+	case http.MethodDelete:
+		if strings.EqualFold(s.memberships[key], mg) {
+			s.memberships[key] = s.TenantID
+		}
+		writeJSON(w, http.StatusOK, nil)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The method '%s' is not allowed.", r.Method)
+	}
+	return true
+}
+
+// handleProviders serves the resource provider and feature registration APIs.
+// All providers are reported as registered once a registration has been requested.
+func (s *Server) handleProviders(w http.ResponseWriter, r *http.Request, path string) bool {
+	segs := segments(path)
+	if len(segs) < 3 || !strings.EqualFold(segs[0], "subscriptions") || !strings.EqualFold(segs[2], "providers") {
+		return false
+	}
+	subID := strings.ToLower(segs[1])
+	if _, ok := s.subscriptions[subID]; !ok {
+		writeError(w, http.StatusNotFound, "SubscriptionNotFound", "The subscription '%s' could not be found.", segs[1])
+		return true
+	}
+	if s.providers[subID] == nil {
+		s.providers[subID] = make(map[string]string)
+	}
+	registered := s.providers[subID]
+
+	switch {
+	// GET /subscriptions/{id}/providers
+	case len(segs) == 3 && r.Method == http.MethodGet:
+		names := make([]string, 0, len(registered))
+		for k := range registered {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		values := make([]any, 0, len(names))
+		for _, n := range names {
+			values = append(values, providerBody(segs[1], n, registered[n]))
+		}
+		s.writePage(w, r, values)
+		return true
+
+	// GET /subscriptions/{id}/providers/{namespace}
+	case len(segs) == 4 && r.Method == http.MethodGet:
+		state, ok := registered[segs[3]]
+		if !ok {
+			state = "NotRegistered"
+		}
+		writeJSON(w, http.StatusOK, providerBody(segs[1], segs[3], state))
+		return true
+
+	// POST /subscriptions/{id}/providers/{namespace}/register
+	case len(segs) == 5 && r.Method == http.MethodPost && strings.EqualFold(segs[4], "register"):
+		registered[segs[3]] = "Registered"
+		writeJSON(w, http.StatusOK, providerBody(segs[1], segs[3], "Registered"))
+		return true
+
+	// GET /subscriptions/{id}/providers/Microsoft.Features/providers/{namespace}/features/{feature}
+	case len(segs) == 8 && r.Method == http.MethodGet &&
+		strings.EqualFold(segs[3], "Microsoft.Features"):
+		if res, ok := s.getResource(path); ok {
+			writeJSON(w, http.StatusOK, res)
+			return true
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"id":         path,
+			"name":       segs[5] + "/" + segs[7],
+			"type":       "Microsoft.Features/providers/features",
+			"properties": map[string]any{"state": "NotRegistered"},
+		})
+		return true
+
+	// POST /subscriptions/{id}/providers/Microsoft.Features/providers/{namespace}/features/{feature}/register
+	case len(segs) == 9 && r.Method == http.MethodPost &&
+		strings.EqualFold(segs[3], "Microsoft.Features") &&
+		strings.EqualFold(segs[8], "register"):
+		id := "/" + strings.Join(segs[:8], "/")
+		s.putResource(id, map[string]any{
+			"properties": map[string]any{"state": "Registered"},
+		})
+		res, _ := s.getResource(id)
+		writeJSON(w, http.StatusOK, res)
+		return true
+	}
+	return false
+}
+
+// providerBody returns the ARM representation of a resource provider.
+func providerBody(subID, namespace, state string) map[string]any {
+	return map[string]any{
+		"id":                "/subscriptions/" + subID + "/providers/" + namespace,
+		"namespace":         namespace,
+		"registrationState": state,
+		"resourceTypes":     []any{},
+	}
+}
+
+// handleOperation serves the status of long running operations started by the fake.
+// Each operation reports InProgress once before reporting success.
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request, path string) bool {
+	segs := segments(path)
+	if len(segs) != 2 || segs[0] != "operationresults" || r.Method != http.MethodGet {
+		return false
+	}
+	polls, ok := s.operations[segs[1]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NotFound", "The operation '%s' could not be found.", segs[1])
+		return true
+	}
+	if polls == 0 {
+		s.operations[segs[1]]++
+		w.Header().Set("Location", s.URL+path)
+		w.Header().Set("Retry-After-Ms", "10")
+		writeJSON(w, http.StatusAccepted, nil)
+		return true
+	}
+	delete(s.operations, segs[1])
+	writeJSON(w, http.StatusOK, nil)
+	return true
+}
+
+// startOperation registers a long running operation and writes the 202 response.
+// The retry-after-ms header keeps SDK pollers from waiting for their default 30 second frequency.
+func (s *Server) startOperation(w http.ResponseWriter) {
+	id := uuid.NewString()
+	s.operations[id] = 0
+	w.Header().Set("Location", s.URL+"/operationresults/"+id)
+	w.Header().Set("Retry-After-Ms", "10")
+	writeJSON(w, http.StatusAccepted, nil)
+}
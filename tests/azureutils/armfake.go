@@ -0,0 +1,58 @@
+package azureutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+)
+
+// armFakeEndpoint returns the URL of the fake Azure Resource Manager,
+// or an empty string if the fake is not in use.
+func armFakeEndpoint() string {
+	return strings.TrimSuffix(os.Getenv(armfake.EnvEndpoint), "/")
+}
+
+// armFakeCloudConfiguration returns a cloud configuration that uses the fake
+// as both the authority host and the resource manager.
+func armFakeCloudConfiguration() cloud.Configuration {
+	ep := armFakeEndpoint()
+	return cloud.Configuration{
+		ActiveDirectoryAuthorityHost: ep + "/",
+		Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+			cloud.ResourceManager: {
+				Audience: ep,
+				Endpoint: ep,
+			},
+		},
+	}
+}
+
+// armFakeTransport returns an HTTP client that trusts the certificate of the fake.
+func armFakeTransport() (*http.Client, error) {
+	certFile := os.Getenv(armfake.EnvCACert)
+	if certFile == "" {
+		return nil, fmt.Errorf("%s must be set when %s is set", armfake.EnvCACert, armfake.EnvEndpoint)
+	}
+	pem, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read fake ARM certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("cannot parse fake ARM certificate %s", certFile)
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:    pool,
+				MinVersion: tls.VersionTLS12,
+			},
+		},
+	}, nil
+}
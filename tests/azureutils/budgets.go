@@ -0,0 +1,237 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/google/uuid"
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+// default values of the optional attributes of a budget notification, see the budgets input.
+const (
+	defaultBudgetThresholdType = "Actual"
+	defaultBudgetLocale        = "en-us"
+)
+
+// BudgetScope returns the scope of the budget in the subscription, as the root module calculates it from relative_scope.
+func BudgetScope(subId uuid.UUID, b *inputs.Budget) string {
+	scope := "/subscriptions/" + subId.String()
+	if b.RelativeScope != nil {
+		scope += *b.RelativeScope
+	}
+	return scope
+}
+
+// GetBudget calls Factory.GetBudget using the default factory, see DefaultFactory.
+func GetBudget(ctx context.Context, scope, name string) (*armconsumption.Budget, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetBudget(ctx, scope, name)
+}
+
+// GetBudget returns the budget with the name at the scope, a subscription or resource group.
+func (f *Factory) GetBudget(ctx context.Context, scope, name string) (*armconsumption.Budget, error) {
+	client, err := f.BudgetsClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(ctx, strings.TrimPrefix(scope, "/"), name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get budget %s at scope %s: %w", name, scope, ClassifyError(err))
+	}
+	return &resp.Budget, nil
+}
+
+// ListBudgets calls Factory.ListBudgets using the default factory, see DefaultFactory.
+func ListBudgets(ctx context.Context, scope string) ([]*armconsumption.Budget, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListBudgets(ctx, scope)
+}
+
+// ListBudgets returns the budgets at the scope, a subscription or resource group.
+func (f *Factory) ListBudgets(ctx context.Context, scope string) ([]*armconsumption.Budget, error) {
+	client, err := f.BudgetsClient()
+	if err != nil {
+		return nil, err
+	}
+	budgets := make([]*armconsumption.Budget, 0)
+	pager := client.NewListPager(strings.TrimPrefix(scope, "/"), nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list budgets at scope %s: %w", scope, ClassifyError(err))
+		}
+		budgets = append(budgets, page.Value...)
+	}
+	return budgets, nil
+}
+
+// CompareBudget returns an error listing the differences between the budget in Azure and the element of the budgets input,
+// or nil if there are none. The amount, time grain, time period and every notification are compared,
+// with the defaults of the input applied to the optional attributes of the notifications.
+func CompareBudget(got *armconsumption.Budget, want *inputs.Budget) error {
+	p := got.Properties
+	if p == nil {
+		return errors.New("budget has no properties")
+	}
+	var diffs []string
+	if amount := deref(p.Amount); amount != want.Amount {
+		diffs = append(diffs, fmt.Sprintf("amount is %v, expected %v", amount, want.Amount))
+	}
+	if grain := string(deref(p.TimeGrain)); grain != want.TimeGrain {
+		diffs = append(diffs, fmt.Sprintf("time grain is %s, expected %s", grain, want.TimeGrain))
+	}
+	period := valueOr(p.TimePeriod, armconsumption.BudgetTimePeriod{})
+	if !sameTime(period.StartDate, want.TimePeriodStart) {
+		diffs = append(diffs, fmt.Sprintf("time period start is %s, expected %s", formatTime(period.StartDate), want.TimePeriodStart))
+	}
+	if !sameTime(period.EndDate, want.TimePeriodEnd) {
+		diffs = append(diffs, fmt.Sprintf("time period end is %s, expected %s", formatTime(period.EndDate), want.TimePeriodEnd))
+	}
+
+	// the notification keys are not case sensitive
+	gotNotifications := make(map[string]*armconsumption.Notification, len(p.Notifications))
+	for k, n := range p.Notifications {
+		if n != nil {
+			gotNotifications[strings.ToLower(k)] = n
+		}
+	}
+	keys := make([]string, 0, len(want.Notifications))
+	for k := range want.Notifications {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		n, ok := gotNotifications[strings.ToLower(k)]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("notification %s is missing", k))
+			continue
+		}
+		delete(gotNotifications, strings.ToLower(k))
+		for _, d := range compareBudgetNotification(n, want.Notifications[k]) {
+			diffs = append(diffs, fmt.Sprintf("notification %s %s", k, d))
+		}
+	}
+	extra := make([]string, 0, len(gotNotifications))
+	for k := range gotNotifications {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		diffs = append(diffs, fmt.Sprintf("notification %s is not expected", k))
+	}
+
+	if len(diffs) > 0 {
+		return errors.New(strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// compareBudgetNotification returns the differences between the notification and the expected one.
+func compareBudgetNotification(got *armconsumption.Notification, want *inputs.BudgetNotification) []string {
+	var diffs []string
+	if enabled := deref(got.Enabled); enabled != want.Enabled {
+		diffs = append(diffs, fmt.Sprintf("enabled is %t, expected %t", enabled, want.Enabled))
+	}
+	if operator := string(deref(got.Operator)); operator != want.Operator {
+		diffs = append(diffs, fmt.Sprintf("operator is %s, expected %s", operator, want.Operator))
+	}
+	if threshold := deref(got.Threshold); threshold != want.Threshold {
+		diffs = append(diffs, fmt.Sprintf("threshold is %v, expected %v", threshold, want.Threshold))
+	}
+	thresholdType := valueOr(want.ThresholdType, defaultBudgetThresholdType)
+	if gotType := string(deref(got.ThresholdType)); gotType != thresholdType {
+		diffs = append(diffs, fmt.Sprintf("threshold_type is %s, expected %s", gotType, thresholdType))
+	}
+	locale := valueOr(want.Locale, defaultBudgetLocale)
+	if gotLocale := string(deref(got.Locale)); !strings.EqualFold(gotLocale, locale) {
+		diffs = append(diffs, fmt.Sprintf("locale is %s, expected %s", gotLocale, locale))
+	}
+	if emails := derefStrings(got.ContactEmails); !sameStrings(emails, want.ContactEmails) {
+		diffs = append(diffs, fmt.Sprintf("contact_emails is %v, expected %v", emails, want.ContactEmails))
+	}
+	if roles := derefStrings(got.ContactRoles); !sameStrings(roles, want.ContactRoles) {
+		diffs = append(diffs, fmt.Sprintf("contact_roles is %v, expected %v", roles, want.ContactRoles))
+	}
+	if groups := derefStrings(got.ContactGroups); !sameStrings(groups, want.ContactGroups) {
+		diffs = append(diffs, fmt.Sprintf("contact_groups is %v, expected %v", groups, want.ContactGroups))
+	}
+	return diffs
+}
+
+// sameTime returns true if the time is set and is the same instant as the RFC3339 time.
+func sameTime(got *time.Time, want string) bool {
+	w, err := time.Parse(time.RFC3339, want)
+	return err == nil && got != nil && got.Equal(w)
+}
+
+// formatTime returns the time in RFC3339 format, or <nil> if it is not set.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "<nil>"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// derefStrings returns the values of the strings in an SDK model, without the nil values.
+func derefStrings(l []*string) []string {
+	var s []string
+	for _, v := range l {
+		if v != nil {
+			s = append(s, *v)
+		}
+	}
+	return s
+}
+
+// VerifyBudgets calls Factory.VerifyBudgets using the default factory, see DefaultFactory.
+func VerifyBudgets(ctx context.Context, t TestingT, subId uuid.UUID, budgets map[string]*inputs.Budget, opts *VerifyOptions) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.VerifyBudgets(ctx, t, subId, budgets, opts)
+}
+
+// VerifyBudgets checks that each element of the budgets input exists in Azure, at its scope in the subscription,
+// and matches the input, see CompareBudget. A missing budget is polled until the retries are exhausted,
+// as the Consumption API can take a while to return a new budget.
+// The errors of all the budgets are returned together.
+func (f *Factory) VerifyBudgets(ctx context.Context, t TestingT, subId uuid.UUID, budgets map[string]*inputs.Budget, opts *VerifyOptions) error {
+	names := make([]string, 0, len(budgets))
+	for name := range budgets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		want := budgets[name]
+		scope := BudgetScope(subId, want)
+		err := poll(ctx, t, "verify budget "+name+" at "+scope, opts, func(ctx context.Context) error {
+			got, err := f.GetBudget(ctx, scope, name)
+			if err != nil {
+				return err
+			}
+			if err := CompareBudget(got, want); err != nil {
+				return retry.FatalError{Underlying: err}
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("budget %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
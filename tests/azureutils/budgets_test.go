@@ -0,0 +1,84 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyBudgets(t *testing.T) {
+	srv, id := newArmFake(t)
+	sub := "/subscriptions/" + id.String()
+	srv.PutResource(sub+"/resourceGroups/rg", map[string]any{"location": "westeurope"})
+
+	budgets := map[string]*inputs.Budget{
+		"sub": inputs.NewBudget(150, "Monthly", "2024-01-01T00:00:00Z", "2027-12-31T23:59:59Z").
+			WithNotification("eightypercent", inputs.NewBudgetNotification("GreaterThan", 80).
+				WithContactEmails("john@contoso.com").
+				WithContactRoles("Owner")),
+		"rg": inputs.NewBudget(50, "Quarterly", "2024-01-01T00:00:00Z", "2027-12-31T23:59:59Z").
+			WithRelativeScope("/resourceGroups/rg").
+			WithNotification("forecast", inputs.NewBudgetNotification("GreaterThanOrEqualTo", 100).
+				WithThresholdType("Forecasted").
+				WithContactGroups(sub+"/resourceGroups/rg/providers/microsoft.insights/actionGroups/ag")),
+	}
+
+	err := VerifyBudgets(context.Background(), t, id, budgets, fastVerify)
+	assert.ErrorContains(t, err, "budget rg: cannot get budget")
+	assert.ErrorContains(t, err, "budget sub: cannot get budget")
+
+	// the budgets as created by the budget submodule, with the times and keys as returned by the API
+	srv.PutResource(sub+"/providers/Microsoft.Consumption/budgets/sub", map[string]any{
+		"properties": map[string]any{
+			"amount":     150,
+			"category":   "Cost",
+			"timeGrain":  "Monthly",
+			"timePeriod": map[string]any{"startDate": "2024-01-01T00:00:00+00:00", "endDate": "2027-12-31T23:59:59Z"},
+			"notifications": map[string]any{
+				"EightyPercent": map[string]any{
+					"enabled": true, "operator": "GreaterThan", "threshold": 80, "thresholdType": "Actual", "locale": "en-us",
+					"contactEmails": []any{"john@contoso.com"}, "contactRoles": []any{"Owner"}, "contactGroups": []any{},
+				},
+			},
+		},
+	})
+	srv.PutResource(sub+"/resourceGroups/rg/providers/Microsoft.Consumption/budgets/rg", map[string]any{
+		"properties": map[string]any{
+			"amount":     50,
+			"category":   "Cost",
+			"timeGrain":  "Quarterly",
+			"timePeriod": map[string]any{"startDate": "2024-01-01T00:00:00Z", "endDate": "2027-12-31T23:59:59Z"},
+			"notifications": map[string]any{
+				"forecast": map[string]any{
+					"enabled": true, "operator": "GreaterThanOrEqualTo", "threshold": 100, "thresholdType": "Forecasted", "locale": "en-us",
+					"contactGroups": []any{sub + "/resourceGroups/rg/providers/Microsoft.Insights/actionGroups/ag"},
+				},
+				"extra": map[string]any{"enabled": true, "operator": "GreaterThan", "threshold": 10},
+			},
+		},
+	})
+
+	list, err := ListBudgets(context.Background(), sub+"/resourceGroups/rg")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "rg", *list[0].Name)
+
+	// the differences do not converge, so they are reported without retrying
+	err = VerifyBudgets(context.Background(), t, id, budgets, &VerifyOptions{Retry: setuptest.Retry{Max: 3, Wait: time.Hour}})
+	assert.EqualError(t, err, "budget rg: notification extra is not expected")
+
+	delete(budgets, "rg")
+	require.NoError(t, VerifyBudgets(context.Background(), t, id, budgets, fastVerify))
+
+	budgets["sub"].Amount = 200
+	budgets["sub"].Notifications["eightypercent"].WithThresholdType("Forecasted").WithContactEmails("jane@contoso.com")
+	err = VerifyBudgets(context.Background(), t, id, budgets, fastVerify)
+	assert.EqualError(t, err, "budget sub: amount is 150, expected 200, "+
+		"notification eightypercent threshold_type is Actual, expected Forecasted, "+
+		"notification eightypercent contact_emails is [john@contoso.com], expected [jane@contoso.com]")
+}
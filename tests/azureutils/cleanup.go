@@ -0,0 +1,356 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/google/uuid"
+)
+
+// CleanupOptions are the options for Cleanup.
+type CleanupOptions struct {
+	TeardownOptions
+
+	// SearchSubscriptions are searched for virtual network peerings and virtual hub connections to the
+	// virtual networks of the subscription being cleaned up, e.g. the subscription of the hub network.
+	// If empty, the subscription in AZURE_SUBSCRIPTION_ID is searched, as that is where the deployment tests
+	// create their hubs, or if that is not set, every subscription the credential can read.
+	SearchSubscriptions []uuid.UUID
+}
+
+// CleanupPlan is what must be removed to clean up a subscription, in the order it is removed.
+type CleanupPlan struct {
+	SubscriptionID uuid.UUID
+
+	// Peerings are the ids of the peerings in other subscriptions to virtual networks in the subscription,
+	// e.g. the hub side of a hub and spoke peering.
+	Peerings []string
+
+	// HubConnections are the ids of the virtual hub connections in other subscriptions to virtual networks in the subscription.
+	HubConnections []string
+
+	// ResourceGroups are the names of the resource groups in the subscription.
+	ResourceGroups []string
+
+	// Cancel is true if the subscription must be cancelled, and false if it is already cancelled.
+	Cancel bool
+
+	// Warnings are the subscriptions that could not be searched, which may still reference the subscription.
+	Warnings []string
+}
+
+// String returns a summary of the plan, for logging.
+func (p *CleanupPlan) String() string {
+	return fmt.Sprintf("subscription %s: remove %d peerings, %d virtual hub connections, %d resource groups, cancel %t",
+		p.SubscriptionID, len(p.Peerings), len(p.HubConnections), len(p.ResourceGroups), p.Cancel)
+}
+
+// Cleanup calls Factory.Cleanup using the default factory, see DefaultFactory.
+func Cleanup(ctx context.Context, t TestingT, id uuid.UUID, opts *CleanupOptions) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.Cleanup(ctx, t, id, opts)
+}
+
+// Cleanup removes everything in the subscription and cancels it, in an order that avoids deletes that fail or hang:
+//
+//  1. the peerings and virtual hub connections in other subscriptions to its virtual networks.
+//  2. its resource groups, after removing management locks, see RemoveResourceGroups.
+//  3. the subscription itself.
+//
+// Each phase is logged with its duration. The cleanup stops if ctx is done, see CleanupContext for a context
+// that outlives the test.
+func (f *Factory) Cleanup(ctx context.Context, t TestingT, id uuid.UUID, opts *CleanupOptions) error {
+	if opts == nil {
+		opts = &CleanupOptions{}
+	}
+	var plan *CleanupPlan
+	err := cleanupPhase(t, id, "plan", func() error {
+		var err error
+		if plan, err = f.PlanCleanup(ctx, id, opts); err != nil {
+			return err
+		}
+		t.Logf("cleanup plan for %s", plan)
+		for _, w := range plan.Warnings {
+			t.Logf("cleanup of subscription %s: warning: %s", id, w)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return f.ExecuteCleanup(ctx, t, plan, opts)
+}
+
+// PlanCleanup calls Factory.PlanCleanup using the default factory, see DefaultFactory.
+func PlanCleanup(ctx context.Context, id uuid.UUID, opts *CleanupOptions) (*CleanupPlan, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.PlanCleanup(ctx, id, opts)
+}
+
+// PlanCleanup discovers what must be removed to clean up the subscription, without removing anything.
+func (f *Factory) PlanCleanup(ctx context.Context, id uuid.UUID, opts *CleanupOptions) (*CleanupPlan, error) {
+	if opts == nil {
+		opts = &CleanupOptions{}
+	}
+	plan := &CleanupPlan{SubscriptionID: id}
+
+	sub, err := f.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("subscription %s does not exist or cannot successfully check, %w", id, err)
+	}
+	// If the sub is already in warned or disabled state then do not try and cancel again.
+	plan.Cancel = sub.State == nil || (*sub.State != "Disabled" && *sub.State != "Warned")
+
+	rgs, err := f.ListResourceGroup(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list resource groups for subscription %s, %w", id, err)
+	}
+	for _, rg := range rgs {
+		plan.ResourceGroups = append(plan.ResourceGroups, *rg.Name)
+	}
+	sort.Strings(plan.ResourceGroups)
+
+	search, explicit := opts.SearchSubscriptions, len(opts.SearchSubscriptions) > 0
+	if !explicit {
+		if search, err = f.defaultSearchSubscriptions(ctx); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range search {
+		if s == id {
+			continue
+		}
+		if err := f.planCrossSubscription(ctx, plan, s); err != nil {
+			if explicit {
+				return nil, err
+			}
+			plan.Warnings = append(plan.Warnings, err.Error())
+		}
+	}
+	sort.Strings(plan.Peerings)
+	sort.Strings(plan.HubConnections)
+	return plan, nil
+}
+
+// defaultSearchSubscriptions returns the subscriptions searched by PlanCleanup if none are supplied.
+func (f *Factory) defaultSearchSubscriptions(ctx context.Context) ([]uuid.UUID, error) {
+	if v := os.Getenv("AZURE_SUBSCRIPTION_ID"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse AZURE_SUBSCRIPTION_ID, %w", err)
+		}
+		return []uuid.UUID{id}, nil
+	}
+	subs, err := f.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(subs))
+	for _, s := range subs {
+		if s.SubscriptionID == nil {
+			continue
+		}
+		if id, err := uuid.Parse(*s.SubscriptionID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// planCrossSubscription adds the peerings and virtual hub connections in the search subscription
+// that reference virtual networks in the subscription being cleaned up.
+func (f *Factory) planCrossSubscription(ctx context.Context, plan *CleanupPlan, search uuid.UUID) error {
+	inTarget := func(id *string) bool {
+		return id != nil && strings.HasPrefix(strings.ToLower(*id), "/subscriptions/"+plan.SubscriptionID.String()+"/")
+	}
+
+	vnets, err := f.VirtualNetworksClient(search)
+	if err != nil {
+		return err
+	}
+	pager := vnets.NewListAllPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot list virtual networks in subscription %s, %w", search, ClassifyError(err))
+		}
+		for _, vnet := range page.Value {
+			if vnet.Properties == nil {
+				continue
+			}
+			for _, p := range vnet.Properties.VirtualNetworkPeerings {
+				if p.Properties != nil && p.Properties.RemoteVirtualNetwork != nil && inTarget(p.Properties.RemoteVirtualNetwork.ID) {
+					plan.Peerings = append(plan.Peerings, *p.ID)
+				}
+			}
+		}
+	}
+
+	hubs, err := f.VirtualHubsClient(search)
+	if err != nil {
+		return err
+	}
+	conns, err := f.HubVirtualNetworkConnectionsClient(search)
+	if err != nil {
+		return err
+	}
+	hubPager := hubs.NewListPager(nil)
+	for hubPager.More() {
+		page, err := hubPager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot list virtual hubs in subscription %s, %w", search, ClassifyError(err))
+		}
+		for _, hub := range page.Value {
+			hubID, err := arm.ParseResourceID(*hub.ID)
+			if err != nil {
+				return fmt.Errorf("cannot parse virtual hub id %s, %w", *hub.ID, err)
+			}
+			connPager := conns.NewListPager(hubID.ResourceGroupName, hubID.Name, nil)
+			for connPager.More() {
+				page, err := connPager.NextPage(ctx)
+				if err != nil {
+					return fmt.Errorf("cannot list connections of virtual hub %s, %w", *hub.ID, ClassifyError(err))
+				}
+				for _, c := range page.Value {
+					if c.Properties != nil && c.Properties.RemoteVirtualNetwork != nil && inTarget(c.Properties.RemoteVirtualNetwork.ID) {
+						plan.HubConnections = append(plan.HubConnections, *c.ID)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ExecuteCleanup calls Factory.ExecuteCleanup using the default factory, see DefaultFactory.
+func ExecuteCleanup(ctx context.Context, t TestingT, plan *CleanupPlan, opts *CleanupOptions) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.ExecuteCleanup(ctx, t, plan, opts)
+}
+
+// ExecuteCleanup removes what is in the plan, in order, logging each phase with its duration.
+// It stops at the first phase that fails.
+func (f *Factory) ExecuteCleanup(ctx context.Context, t TestingT, plan *CleanupPlan, opts *CleanupOptions) error {
+	if opts == nil {
+		opts = &CleanupOptions{}
+	}
+	id := plan.SubscriptionID
+
+	if err := cleanupPhase(t, id, "remove peerings", func() error {
+		for _, p := range plan.Peerings {
+			t.Logf("removing peering %s for subscription %s", p, id)
+			if err := f.deletePeering(ctx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := cleanupPhase(t, id, "remove virtual hub connections", func() error {
+		for _, c := range plan.HubConnections {
+			t.Logf("removing virtual hub connection %s for subscription %s", c, id)
+			if err := f.deleteHubConnection(ctx, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := cleanupPhase(t, id, "remove resource groups", func() error {
+		report, err := f.RemoveResourceGroups(ctx, t, id, &opts.TeardownOptions)
+		t.Logf("teardown of %s", report)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if !plan.Cancel {
+		t.Logf("subscription %s is already cancelled", id.String())
+		return nil
+	}
+	return cleanupPhase(t, id, "cancel subscription", func() error {
+		return f.cancelSubscription(ctx, t, id)
+	})
+}
+
+// cleanupPhase runs the phase of a cleanup, logging its duration.
+func cleanupPhase(t TestingT, id uuid.UUID, name string, fn func() error) error {
+	start := time.Now()
+	t.Logf("cleanup of subscription %s: %s", id, name)
+	if err := fn(); err != nil {
+		t.Logf("cleanup of subscription %s: %s failed after %s", id, name, time.Since(start).Round(time.Millisecond))
+		return err
+	}
+	t.Logf("cleanup of subscription %s: %s completed in %s", id, name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// deletePeering deletes the virtual network peering with the supplied resource id.
+func (f *Factory) deletePeering(ctx context.Context, id string) error {
+	rid, err := arm.ParseResourceID(id)
+	if err != nil {
+		return fmt.Errorf("cannot parse peering id %s, %w", id, err)
+	}
+	sub, err := uuid.Parse(rid.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("cannot parse subscription of peering %s, %w", id, err)
+	}
+	client, err := f.VirtualNetworkPeeringsClient(sub)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, longRunningTimeout)
+	defer cancel()
+	poller, err := client.BeginDelete(ctx, rid.ResourceGroupName, rid.Parent.Name, rid.Name, nil)
+	if err == nil {
+		_, err = poller.PollUntilDone(ctx, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot delete peering %s, %w", id, ClassifyError(err))
+	}
+	return nil
+}
+
+// deleteHubConnection deletes the virtual hub connection with the supplied resource id.
+func (f *Factory) deleteHubConnection(ctx context.Context, id string) error {
+	rid, err := arm.ParseResourceID(id)
+	if err != nil {
+		return fmt.Errorf("cannot parse virtual hub connection id %s, %w", id, err)
+	}
+	sub, err := uuid.Parse(rid.SubscriptionID)
+	if err != nil {
+		return fmt.Errorf("cannot parse subscription of virtual hub connection %s, %w", id, err)
+	}
+	client, err := f.HubVirtualNetworkConnectionsClient(sub)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, longRunningTimeout)
+	defer cancel()
+	poller, err := client.BeginDelete(ctx, rid.ResourceGroupName, rid.Parent.Name, rid.Name, nil)
+	if err == nil {
+		_, err = poller.PollUntilDone(ctx, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot delete virtual hub connection %s, %w", id, ClassifyError(err))
+	}
+	return nil
+}
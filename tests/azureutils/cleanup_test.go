@@ -0,0 +1,89 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records the log messages of a cleanup.
+type recordingT struct {
+	*testing.T
+	logs []string
+}
+
+func (r *recordingT) Logf(format string, args ...any) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+	r.T.Logf(format, args...)
+}
+
+func TestCleanupRemovesCrossSubscriptionReferencesFirst(t *testing.T) {
+	srv, id := newArmFake(t)
+	hub := uuid.MustParse(srv.AddSubscription("hub").ID)
+	t.Setenv("AZURE_SUBSCRIPTION_ID", hub.String())
+
+	hubRg := "/subscriptions/" + hub.String() + "/resourceGroups/hub"
+	hubVnet := hubRg + "/providers/Microsoft.Network/virtualNetworks/hub"
+	vhub := hubRg + "/providers/Microsoft.Network/virtualHubs/vhub"
+	spokeRg := "/subscriptions/" + id.String() + "/resourceGroups/spoke"
+	spokeVnet := spokeRg + "/providers/Microsoft.Network/virtualNetworks/spoke"
+	otherVnet := spokeRg + "/providers/Microsoft.Network/virtualNetworks/other"
+	srv.PutResource(hubRg, map[string]any{"location": "westeurope"})
+	srv.PutResource(hubVnet, map[string]any{})
+	srv.PutResource(vhub, map[string]any{})
+	srv.PutResource(spokeRg, map[string]any{"location": "westeurope"})
+	srv.PutResource(spokeRg+"/providers/Microsoft.Authorization/locks/lock-spoke", lockBody)
+	srv.PutResource(spokeVnet, map[string]any{})
+	srv.PutResource(otherVnet, map[string]any{})
+	remote := func(id string) map[string]any {
+		return map[string]any{"properties": map[string]any{"remoteVirtualNetwork": map[string]any{"id": id}}}
+	}
+	srv.PutResource(hubVnet+"/virtualNetworkPeerings/spoke", remote(spokeVnet))
+	srv.PutResource(spokeVnet+"/virtualNetworkPeerings/hub", remote(hubVnet))
+	srv.PutResource(vhub+"/hubVirtualNetworkConnections/other", remote(otherVnet))
+
+	plan, err := PlanCleanup(context.Background(), id, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{hubVnet + "/virtualNetworkPeerings/spoke"}, plan.Peerings)
+	assert.Equal(t, []string{vhub + "/hubVirtualNetworkConnections/other"}, plan.HubConnections)
+	assert.Equal(t, []string{"spoke"}, plan.ResourceGroups)
+	assert.True(t, plan.Cancel)
+	assert.Empty(t, plan.Warnings)
+
+	rt := &recordingT{T: t}
+	require.NoError(t, CancelSubscription(context.Background(), rt, &id))
+	assert.Empty(t, srv.ResourceIDs(spokeRg))
+	assert.Empty(t, srv.ResourceIDs(hubVnet+"/virtualNetworkPeerings"))
+	assert.Empty(t, srv.ResourceIDs(vhub+"/hubVirtualNetworkConnections"))
+	assert.NotEmpty(t, srv.ResourceIDs(hubVnet), "the hub itself is not removed")
+	sub, _ := srv.Subscription(id.String())
+	assert.Equal(t, "Warned", sub.State)
+
+	phases := make([]string, 0)
+	for _, l := range rt.logs {
+		if _, phase, ok := strings.Cut(l, "cleanup of subscription "+id.String()+": "); ok && strings.Contains(phase, " completed in ") {
+			phases = append(phases, phase[:strings.Index(phase, " completed in ")])
+		}
+	}
+	assert.Equal(t, []string{"plan", "remove peerings", "remove virtual hub connections", "remove resource groups", "cancel subscription"}, phases)
+}
+
+func TestPlanCleanupSearchSubscriptions(t *testing.T) {
+	srv, id := newArmFake(t)
+	t.Setenv("AZURE_SUBSCRIPTION_ID", "")
+
+	// A subscription that cannot be searched is an error only when it is requested explicitly.
+	_, err := PlanCleanup(context.Background(), id, &CleanupOptions{SearchSubscriptions: []uuid.UUID{uuid.New()}})
+	assert.ErrorContains(t, err, "SubscriptionNotFound")
+
+	srv.AddSubscription("other")
+	plan, err := PlanCleanup(context.Background(), id, nil)
+	require.NoError(t, err)
+	assert.Empty(t, plan.Warnings)
+	assert.Empty(t, plan.Peerings)
+}
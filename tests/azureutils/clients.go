@@ -1,14 +1,11 @@
 package azureutils
 
 import (
-	"fmt"
 	"os"
-	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
@@ -16,117 +13,100 @@ import (
 )
 
 // NewSubnetClient creates a new subnet client using
-// armnetwork.NewSubnetsClient
+// armnetwork.NewSubnetsClient, from the default factory.
 func NewSubnetClient(id uuid.UUID) (*armnetwork.SubnetsClient, error) {
-	cred, err := newDefaultAzureCredential()
+	f, err := DefaultFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return nil, err
 	}
-	clientOpts := arm.ClientOptions{
-		DisableRPRegistration: true,
-	}
-
-	client, err := armnetwork.NewSubnetsClient(id.String(), cred, &clientOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create subnet client: %v", err)
-	}
-	return client, nil
+	return f.SubnetsClient(id)
 }
 
-// NewSubscriptionsClient creates a new subscriptions client using
-// azidentity.NewDefaultAzureCredential.
+// NewSubscriptionsClient creates a new subscriptions client, from the default factory.
 func NewSubscriptionsClient() (*armsubscription.SubscriptionsClient, error) {
-	cred, err := newDefaultAzureCredential()
+	f, err := DefaultFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
-	}
-	clientOpts := &arm.ClientOptions{
-		DisableRPRegistration: true,
+		return nil, err
 	}
-
-	client, err := armsubscription.NewSubscriptionsClient(cred, clientOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create subscriptions client: %v", err)
-	}
-	return client, nil
+	return f.SubscriptionsClient()
 }
 
-// NewSubscriptionClient creates a new subscription client using
-// azidentity.NewDefaultAzureCredential.
+// NewSubscriptionClient creates a new subscription client, from the default factory.
 func NewSubscriptionClient() (*armsubscription.Client, error) {
-	cred, err := newDefaultAzureCredential()
+	f, err := DefaultFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
-	}
-	clientOpts := &arm.ClientOptions{
-		DisableRPRegistration: true,
+		return nil, err
 	}
+	return f.SubscriptionClient()
+}
 
-	client, err := armsubscription.NewClient(cred, clientOpts)
+// NewAliasClient creates a new subscription alias client, from the default factory.
+func NewAliasClient() (*armsubscription.AliasClient, error) {
+	f, err := DefaultFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create subscription client: %v", err)
+		return nil, err
 	}
-	return client, nil
+	return f.AliasClient()
 }
 
-// NewManagementGroupSubscriptionsClient creates a new management group subscriptions client using
-// azidentity.NewDefaultAzureCredential.
+// NewManagementGroupSubscriptionsClient creates a new management group subscriptions client, from the default factory.
 func NewManagementGroupSubscriptionsClient() (*armmanagementgroups.ManagementGroupSubscriptionsClient, error) {
-	cred, err := newDefaultAzureCredential()
+	f, err := DefaultFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return nil, err
 	}
-	clientOpts := &arm.ClientOptions{
+	return f.ManagementGroupSubscriptionsClient()
+}
+
+// newClientOptions returns the ARM client options for the selected Azure cloud.
+// If the fake Azure Resource Manager is in use, the options point at it.
+func newClientOptions() (*arm.ClientOptions, error) {
+	opts := &arm.ClientOptions{
 		DisableRPRegistration: true,
 	}
+	if armFakeEndpoint() == "" {
+		cloudConfig, err := cloudConfiguration()
+		if err != nil {
+			return nil, err
+		}
+		opts.Cloud = cloudConfig
+		return opts, nil
+	}
+	transport, err := armFakeTransport()
+	if err != nil {
+		return nil, err
+	}
+	opts.Cloud = armFakeCloudConfiguration()
+	opts.Transport = transport
+	return opts, nil
+}
 
-	client, err := armmanagementgroups.NewManagementGroupSubscriptionsClient(cred, clientOpts)
+// cloudConfiguration returns the Azure cloud selected by the AZURE_ENVIRONMENT, ARM_METADATA_HOSTNAME
+// and AZURE_ENVIRONMENT_FILEPATH env vars, see ResolveEnvironment.
+func cloudConfiguration() (cloud.Configuration, error) {
+	env, err := ResolveEnvironment()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create management group subscription client: %v", err)
+		return cloud.Configuration{}, err
 	}
-	return client, nil
+	return env.Cloud, nil
 }
 
-// newDefaultAzureCredential creates a new default AzureCredential using
-// OIDC or azidentity.NewDefaultAzureCredential.
-// OIDC is used if the environment variable USE_OIDC or ARM_USE_OIDC is set to non-empty.
+// newDefaultAzureCredential creates the credential selected by SelectCredentialSource.
+// This is a credential accepted by the fake Azure Resource Manager if it is in use, else the mode in ARM_AUTH_MODE if set,
+// else OIDC if the environment variable USE_OIDC or ARM_USE_OIDC is set to non-empty, and otherwise azidentity.NewDefaultAzureCredential.
 func newDefaultAzureCredential() (azcore.TokenCredential, error) {
-	// Select the Azure cloud from the AZURE_ENVIRONMENT env var
-	var cloudConfig cloud.Configuration
-	env := os.Getenv("AZURE_ENVIRONMENT")
-	switch strings.ToLower(env) {
-	case "public":
-		cloudConfig = cloud.AzurePublic
-	case "usgovernment":
-		cloudConfig = cloud.AzureGovernment
-	case "china":
-		cloudConfig = cloud.AzureChina
-	default:
-		cloudConfig = cloud.AzurePublic
+	cs, err := SelectCredentialSource()
+	if err != nil {
+		return nil, err
 	}
-
-	useoidc := multiEnvDefault("", "USE_OIDC", "ARM_USE_OIDC")
-	if useoidc != "" {
-		return NewOidcCredential(&OidcCredentialOptions{
-			ClientOptions: azcore.ClientOptions{
-				Cloud: cloudConfig,
-			},
-			TenantID:      multiEnvDefault("", "ARM_TENANT_ID", "AZURE_TENANT_ID"),
-			ClientID:      multiEnvDefault("", "ARM_CLIENT_ID", "AZURE_CLIENT_ID"),
-			RequestToken:  multiEnvDefault("", "ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"),
-			RequestUrl:    multiEnvDefault("", "ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL"),
-			Token:         multiEnvDefault("", "ARM_OIDC_TOKEN"),
-			TokenFilePath: multiEnvDefault("", "ARM_OIDC_TOKEN_FILE_PATH"),
-		})
+	if cs.Mode == AuthModeArmFake {
+		return cs.newCredential(armFakeCloudConfiguration())
 	}
-
-	// Get default credentials, this will look for the well-known environment variables,
-	// managed identity credentials, and az cli credentials
-	return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
-		ClientOptions: azcore.ClientOptions{
-			Cloud: cloudConfig,
-		},
-	})
+	cloudConfig, err := cloudConfiguration()
+	if err != nil {
+		return nil, err
+	}
+	return cs.newCredential(cloudConfig)
 }
 
 func multiEnvDefault(dv string, envs ...string) string {
@@ -0,0 +1,255 @@
+package azureutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+const (
+	// EnvEnvironment is the environment variable containing the name of the Azure cloud,
+	// one of public, usgovernment or china, or the name of a cloud in the custom cloud metadata.
+	EnvEnvironment = "AZURE_ENVIRONMENT"
+
+	// EnvMetadataHost is the environment variable containing the host name of the Azure Resource Manager
+	// of a custom cloud, the metadata of which is read from its /metadata/endpoints endpoint.
+	// It is the same variable used by the azurerm provider.
+	EnvMetadataHost = "ARM_METADATA_HOSTNAME"
+
+	// EnvEnvironmentFile is the environment variable containing the path to a JSON file with the metadata
+	// of a custom cloud, in the format returned by the /metadata/endpoints endpoint.
+	EnvEnvironmentFile = "AZURE_ENVIRONMENT_FILEPATH"
+
+	// metadataAPIVersion is the API version of the /metadata/endpoints endpoint.
+	metadataAPIVersion = "2022-09-01"
+)
+
+// builtinEnvironments are the clouds known to the Azure SDK, keyed by the name used by the providers.
+var builtinEnvironments = map[string]cloud.Configuration{
+	"public":       cloud.AzurePublic,
+	"usgovernment": cloud.AzureGovernment,
+	"china":        cloud.AzureChina,
+}
+
+// environmentCache holds the resolved custom clouds, keyed by their source,
+// so that the metadata endpoint is not requested for every client.
+var environmentCache sync.Map
+
+// Environment is a resolved Azure cloud.
+type Environment struct {
+	// Name is the name of the cloud, e.g. public for a built in cloud,
+	// or the name in the metadata for a custom cloud.
+	Name string
+
+	// Builtin is true for the clouds known to the Azure SDK and providers.
+	Builtin bool
+
+	// MetadataHost is the host name of the Azure Resource Manager serving the cloud metadata,
+	// used to configure the providers for a custom cloud.
+	MetadataHost string
+
+	// Cloud is the configuration used by the Azure SDK clients.
+	Cloud cloud.Configuration
+}
+
+// cloudMetadata is the part of the cloud metadata returned by the /metadata/endpoints endpoint
+// used to configure the Azure SDK.
+type cloudMetadata struct {
+	Name            string `json:"name"`
+	ResourceManager string `json:"resourceManager"`
+	Authentication  struct {
+		LoginEndpoint string   `json:"loginEndpoint"`
+		Audiences     []string `json:"audiences"`
+	} `json:"authentication"`
+}
+
+// ResolveEnvironment returns the Azure cloud selected by the environment variables, in order of precedence:
+//
+//   - AZURE_ENVIRONMENT_FILEPATH, a JSON file with the cloud metadata.
+//   - ARM_METADATA_HOSTNAME, the host of an Azure Resource Manager serving the cloud metadata.
+//   - AZURE_ENVIRONMENT, one of public, usgovernment or china. The default is public.
+//
+// If the metadata contains several clouds, the one matching AZURE_ENVIRONMENT, or the metadata host, is used.
+// An unknown AZURE_ENVIRONMENT is an error rather than falling back to the public cloud.
+func ResolveEnvironment() (*Environment, error) {
+	client := http.DefaultClient
+	if armFakeEndpoint() != "" {
+		c, err := armFakeTransport()
+		if err != nil {
+			return nil, err
+		}
+		client = c
+	}
+	return resolveEnvironment(client)
+}
+
+// resolveEnvironment resolves the Azure cloud, using the supplied client to request the cloud metadata.
+func resolveEnvironment(client *http.Client) (*Environment, error) {
+	name := strings.ToLower(os.Getenv(EnvEnvironment))
+
+	if fn := os.Getenv(EnvEnvironmentFile); fn != "" {
+		return cachedEnvironment("file:"+fn+":"+name, func() (*Environment, error) {
+			b, err := os.ReadFile(fn)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %w", EnvEnvironmentFile, err)
+			}
+			env, err := parseCloudMetadata(b, name, "")
+			if err != nil {
+				return nil, fmt.Errorf("cannot load cloud from %s: %w", fn, err)
+			}
+			return env, nil
+		})
+	}
+
+	if host := os.Getenv(EnvMetadataHost); host != "" {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "https://"), "/")
+		return cachedEnvironment("host:"+host+":"+name, func() (*Environment, error) {
+			b, err := getCloudMetadata(client, host)
+			if err != nil {
+				return nil, err
+			}
+			env, err := parseCloudMetadata(b, name, host)
+			if err != nil {
+				return nil, fmt.Errorf("cannot load cloud from metadata host %s: %w", host, err)
+			}
+			return env, nil
+		})
+	}
+
+	if name == "" {
+		name = "public"
+	}
+	c, ok := builtinEnvironments[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s %q, must be one of public, usgovernment or china, or set %s or %s for a custom cloud",
+			EnvEnvironment, os.Getenv(EnvEnvironment), EnvMetadataHost, EnvEnvironmentFile)
+	}
+	return &Environment{
+		Name:    name,
+		Builtin: true,
+		Cloud:   c,
+	}, nil
+}
+
+// cachedEnvironment returns the cached environment for the key, or resolves and caches it.
+// Errors are not cached.
+func cachedEnvironment(key string, resolve func() (*Environment, error)) (*Environment, error) {
+	if v, ok := environmentCache.Load(key); ok {
+		return v.(*Environment), nil
+	}
+	env, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+	environmentCache.Store(key, env)
+	return env, nil
+}
+
+// getCloudMetadata requests the cloud metadata from the metadata endpoint of the Azure Resource Manager host.
+func getCloudMetadata(client *http.Client, host string) ([]byte, error) {
+	u := url.URL{
+		Scheme:   "https",
+		Host:     host,
+		Path:     "/metadata/endpoints",
+		RawQuery: url.Values{"api-version": []string{metadataAPIVersion}}.Encode(),
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("cannot request cloud metadata: %w", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cloud metadata: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cannot request cloud metadata from %s: received HTTP status %d with response: %s", u.String(), resp.StatusCode, b)
+	}
+	return b, nil
+}
+
+// parseCloudMetadata returns the cloud in the metadata, which is either a single cloud or a list of clouds.
+// The cloud with the supplied name is used, otherwise the one whose resource manager is the metadata host,
+// otherwise the only cloud in the list if no name is supplied.
+func parseCloudMetadata(b []byte, name, host string) (*Environment, error) {
+	var clouds []cloudMetadata
+	if err := json.Unmarshal(b, &clouds); err != nil {
+		var single cloudMetadata
+		if err := json.Unmarshal(b, &single); err != nil {
+			return nil, fmt.Errorf("cannot parse cloud metadata: %w", err)
+		}
+		clouds = []cloudMetadata{single}
+	}
+
+	var selected *cloudMetadata
+	for i, c := range clouds {
+		if name != "" && strings.EqualFold(c.Name, name) {
+			selected = &clouds[i]
+			break
+		}
+	}
+	if selected == nil && host != "" {
+		for i, c := range clouds {
+			if resourceManagerHost(c.ResourceManager) == host {
+				selected = &clouds[i]
+				break
+			}
+		}
+	}
+	if selected == nil && len(clouds) == 1 && (name == "" || host != "") {
+		selected = &clouds[0]
+	}
+	if selected == nil {
+		names := make([]string, len(clouds))
+		for i, c := range clouds {
+			names[i] = c.Name
+		}
+		return nil, fmt.Errorf("cannot select cloud %q from the metadata, it contains: %s", name, strings.Join(names, ", "))
+	}
+
+	rm := selected.ResourceManager
+	if rm == "" && host != "" {
+		rm = "https://" + host + "/"
+	}
+	switch {
+	case rm == "":
+		return nil, fmt.Errorf("cloud %q has no resourceManager endpoint", selected.Name)
+	case selected.Authentication.LoginEndpoint == "":
+		return nil, fmt.Errorf("cloud %q has no authentication.loginEndpoint", selected.Name)
+	case len(selected.Authentication.Audiences) == 0:
+		return nil, fmt.Errorf("cloud %q has no authentication.audiences", selected.Name)
+	}
+	if host == "" {
+		host = resourceManagerHost(rm)
+	}
+
+	return &Environment{
+		Name:         selected.Name,
+		MetadataHost: host,
+		Cloud: cloud.Configuration{
+			ActiveDirectoryAuthorityHost: selected.Authentication.LoginEndpoint,
+			Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {
+					Audience: selected.Authentication.Audiences[0],
+					Endpoint: rm,
+				},
+			},
+		},
+	}, nil
+}
+
+// resourceManagerHost returns the host of the resource manager endpoint URL.
+func resourceManagerHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
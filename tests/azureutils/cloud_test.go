@@ -0,0 +1,136 @@
+package azureutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// customCloudMetadata is the metadata of two custom clouds, in the format returned by the /metadata/endpoints endpoint.
+const customCloudMetadata = `[
+  {
+    "name": "AzureStackUser",
+    "resourceManager": "https://management.local.azurestack.external/",
+    "authentication": {
+      "loginEndpoint": "https://login.local.azurestack.external/",
+      "audiences": ["https://management.adfs.azurestack.local/04b1a9ae-2a6b-4a07-8d13-0f1bba31fa18"]
+    }
+  },
+  {
+    "name": "Sovereign",
+    "resourceManager": "https://management.sovereign.example/",
+    "authentication": {
+      "loginEndpoint": "https://login.sovereign.example/",
+      "audiences": ["https://management.core.sovereign.example/"]
+    }
+  }
+]`
+
+// clearCloudEnv unsets the environment variables read by ResolveEnvironment.
+// Tests using it cannot run in parallel as it sets environment variables.
+func clearCloudEnv(t *testing.T) {
+	t.Setenv(armfake.EnvEndpoint, "")
+	t.Setenv(EnvEnvironment, "")
+	t.Setenv(EnvMetadataHost, "")
+	t.Setenv(EnvEnvironmentFile, "")
+}
+
+func TestResolveEnvironmentBuiltin(t *testing.T) {
+	clearCloudEnv(t)
+
+	env, err := ResolveEnvironment()
+	require.NoError(t, err)
+	assert.Equal(t, "public", env.Name)
+	assert.True(t, env.Builtin)
+	assert.Equal(t, cloud.AzurePublic, env.Cloud)
+
+	t.Setenv(EnvEnvironment, "USGovernment")
+	env, err = ResolveEnvironment()
+	require.NoError(t, err)
+	assert.Equal(t, "usgovernment", env.Name)
+	assert.Equal(t, cloud.AzureGovernment, env.Cloud)
+}
+
+func TestResolveEnvironmentUnknown(t *testing.T) {
+	clearCloudEnv(t)
+	t.Setenv(EnvEnvironment, "germany")
+
+	_, err := ResolveEnvironment()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown AZURE_ENVIRONMENT "germany"`)
+
+	_, err = newClientOptions()
+	assert.Error(t, err, "client options must not fall back to the public cloud")
+}
+
+func TestResolveEnvironmentFile(t *testing.T) {
+	clearCloudEnv(t)
+	fn := filepath.Join(t.TempDir(), "clouds.json")
+	require.NoError(t, os.WriteFile(fn, []byte(customCloudMetadata), 0600))
+	t.Setenv(EnvEnvironmentFile, fn)
+	t.Setenv(EnvEnvironment, "sovereign")
+
+	env, err := ResolveEnvironment()
+	require.NoError(t, err)
+	assert.Equal(t, "Sovereign", env.Name)
+	assert.False(t, env.Builtin)
+	assert.Equal(t, "management.sovereign.example", env.MetadataHost)
+	assert.Equal(t, "https://login.sovereign.example/", env.Cloud.ActiveDirectoryAuthorityHost)
+	assert.Equal(t, cloud.ServiceConfiguration{
+		Audience: "https://management.core.sovereign.example/",
+		Endpoint: "https://management.sovereign.example/",
+	}, env.Cloud.Services[cloud.ResourceManager])
+
+	opts, err := newClientOptions()
+	require.NoError(t, err)
+	assert.Equal(t, env.Cloud, opts.Cloud)
+
+	// The file contains several clouds, so the name is required.
+	t.Setenv(EnvEnvironment, "")
+	_, err = ResolveEnvironment()
+	assert.ErrorContains(t, err, "AzureStackUser, Sovereign")
+}
+
+func TestResolveEnvironmentMetadataHost(t *testing.T) {
+	clearCloudEnv(t)
+	srv := armfake.NewServer(t)
+	host := strings.TrimPrefix(srv.URL, "https://")
+	t.Setenv(EnvMetadataHost, srv.URL)
+
+	env, err := resolveEnvironment(srv.Client())
+	require.NoError(t, err)
+	assert.Equal(t, "ARMFake", env.Name)
+	assert.Equal(t, host, env.MetadataHost)
+	assert.Equal(t, srv.URL+"/", env.Cloud.ActiveDirectoryAuthorityHost)
+	assert.Equal(t, cloud.ServiceConfiguration{
+		Audience: srv.URL,
+		Endpoint: srv.URL + "/",
+	}, env.Cloud.Services[cloud.ResourceManager])
+
+	// The resolved cloud is cached, so the metadata is not requested again.
+	srv.Close()
+	cached, err := resolveEnvironment(srv.Client())
+	require.NoError(t, err)
+	assert.Same(t, env, cached)
+}
+
+func TestParseCloudMetadataSingle(t *testing.T) {
+	t.Parallel()
+
+	env, err := parseCloudMetadata([]byte(`{
+  "name": "Custom",
+  "authentication": {"loginEndpoint": "https://login.custom.example/", "audiences": ["https://management.custom.example/"]}
+}`), "", "management.custom.example")
+	require.NoError(t, err)
+	assert.Equal(t, "Custom", env.Name)
+	assert.Equal(t, "https://management.custom.example/", env.Cloud.Services[cloud.ResourceManager].Endpoint)
+
+	_, err = parseCloudMetadata([]byte(`{"name": "Custom", "resourceManager": "https://management.custom.example/"}`), "", "")
+	assert.ErrorContains(t, err, "no authentication.loginEndpoint")
+}
@@ -0,0 +1,91 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/cassette"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+const (
+	// requestTimeout bounds each try of a request made by the clients of a factory.
+	requestTimeout = 2 * time.Minute
+
+	// longRunningTimeout bounds a long running operation, from starting it to polling it until done,
+	// e.g. deleting a resource group.
+	longRunningTimeout = 30 * time.Minute
+
+	// cleanupTimeout bounds a cleanup that runs with a context from CleanupContext.
+	cleanupTimeout = time.Hour
+
+	// testDeadlineGrace is the most time before the deadline of a test at which the context from TestContext is cancelled,
+	// leaving the test time to clean up and report its failure before go test panics.
+	testDeadlineGrace = 5 * time.Minute
+)
+
+// deadliner is implemented by *testing.T, which has a deadline if go test is run with a timeout.
+type deadliner interface {
+	Deadline() (time.Time, bool)
+}
+
+// namer is implemented by *testing.T.
+type namer interface {
+	Name() string
+}
+
+// TestContext returns the context for the calls made by a test, which is cancelled shortly before the deadline of the test,
+// a tenth of the time remaining but no more than five minutes, so that a go test -timeout aborts the calls in flight
+// and leaves the test time to clean up. If t has no deadline, e.g. it is not a *testing.T, the context has no deadline.
+// If t has a name, the requests made with the context are recorded to the cassette named after the test,
+// when recording is enabled, see cassette.EnvDir. The cancel function should be deferred.
+func TestContext(t TestingT) (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if n, ok := t.(namer); ok {
+		ctx = cassette.WithName(ctx, n.Name())
+	}
+	if d, ok := t.(deadliner); ok {
+		if deadline, ok := d.Deadline(); ok {
+			grace := min(testDeadlineGrace, time.Until(deadline)/10)
+			return context.WithDeadline(ctx, deadline.Add(-grace))
+		}
+	}
+	return context.WithCancel(ctx)
+}
+
+// CleanupContext returns a context for cleaning up after the operations that used ctx, with the values of ctx
+// but not its cancellation, so that the cleanup still runs when ctx is done, e.g. the test timed out or was interrupted.
+// The cleanup is instead bounded by its own deadline of an hour. The cancel function should be deferred.
+func CleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// doWithRetry calls action until it succeeds, returns a retry.FatalError or the retries are exhausted, as retry.DoWithRetryE does,
+// but returns as soon as the context is done rather than waiting to try again.
+// Unless the action returns a retry.FatalError, the error wraps the last error of the action, so it can be tested with errors.Is.
+func doWithRetry(ctx context.Context, t TestingT, desc string, rty setuptest.Retry, action func() error) error {
+	var err error
+	for i := 0; i <= rty.Max; i++ {
+		logger.Log(t, desc)
+		err = action()
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(retry.FatalError); ok {
+			logger.Logf(t, "Returning due to fatal error: %v", err)
+			return err
+		}
+		logger.Logf(t, "%s returned an error: %s. Sleeping for %s and will try again.", desc, err.Error(), rty.Wait)
+		timer := time.NewTimer(rty.Wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s: %w, last error: %w", desc, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+	return fmt.Errorf("%w, last error: %w", retry.MaxRetriesExceeded{Description: desc, MaxRetries: rty.Max}, err)
+}
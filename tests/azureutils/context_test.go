@@ -0,0 +1,97 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/cassette"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadlineT is a test with the supplied deadline, or no deadline if it is zero.
+type deadlineT struct {
+	*testing.T
+	deadline time.Time
+}
+
+func (t deadlineT) Deadline() (time.Time, bool) {
+	return t.deadline, !t.deadline.IsZero()
+}
+
+func TestTestContext(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		remaining time.Duration
+		want      time.Duration
+	}{
+		{time.Hour, 55 * time.Minute},
+		{10 * time.Minute, 9 * time.Minute},
+	}
+	for _, c := range cases {
+		ctx, cancel := TestContext(deadlineT{T: t, deadline: time.Now().Add(c.remaining)})
+		d, ok := ctx.Deadline()
+		cancel()
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(c.want), d, time.Second, "remaining %s", c.remaining)
+	}
+
+	ctx, cancel := TestContext(deadlineT{T: t})
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+	assert.Equal(t, t.Name(), cassette.Name(ctx), "requests are recorded to the cassette of the test")
+	cleanupCtx, cleanupCancel := CleanupContext(ctx)
+	assert.Equal(t, t.Name(), cassette.Name(cleanupCtx))
+	cleanupCancel()
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+}
+
+func TestCleanupContext(t *testing.T) {
+	t.Parallel()
+
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	cleanup, cancelCleanup := CleanupContext(ctx)
+	defer cancelCleanup()
+	assert.NoError(t, cleanup.Err())
+	assert.Equal(t, "value", cleanup.Value(key{}))
+	d, ok := cleanup.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(cleanupTimeout), d, time.Second)
+}
+
+func TestPollStopsWhenContextDone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := poll(ctx, t, "poll", &VerifyOptions{Retry: setuptest.Retry{Max: 3, Wait: time.Hour}}, func(context.Context) error {
+		return errors.New("not yet")
+	})
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.EqualError(t, err, "poll: context deadline exceeded, last error: not yet")
+}
+
+func TestCancelSubscriptionAfterContextDone(t *testing.T) {
+	srv, id := newArmFake(t)
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := SubscriptionExists(ctx, id)
+	assert.ErrorContains(t, err, "context canceled")
+
+	// The subscription is still cleaned up, with a context of its own.
+	require.NoError(t, CancelSubscription(ctx, t, &id))
+	assert.Empty(t, srv.ResourceIDs("/subscriptions/"+id.String()+"/resourceGroups"))
+	sub, _ := srv.Subscription(id.String())
+	assert.Equal(t, "Warned", sub.State)
+}
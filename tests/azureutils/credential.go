@@ -0,0 +1,265 @@
+package azureutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+)
+
+// EnvAuthMode is the environment variable that selects the credential used by the azureutils clients,
+// one of the AuthMode* values.
+const EnvAuthMode = "ARM_AUTH_MODE"
+
+const (
+	// AuthModeOidc uses an OIDC assertion from ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH or the
+	// GitHub Actions token request URL.
+	AuthModeOidc = "oidc"
+
+	// AuthModeWorkloadIdentity uses the federated token file projected into a Kubernetes pod
+	// by Azure AD workload identity, AZURE_FEDERATED_TOKEN_FILE.
+	AuthModeWorkloadIdentity = "workload_identity"
+
+	// AuthModeCli uses the Azure CLI.
+	AuthModeCli = "cli"
+
+	// AuthModeMsi uses a managed identity, the user assigned identity with ARM_CLIENT_ID if set.
+	AuthModeMsi = "msi"
+
+	// AuthModeClientSecret uses a service principal and client secret.
+	AuthModeClientSecret = "client_secret"
+
+	// AuthModeClientCertificate uses a service principal and client certificate.
+	AuthModeClientCertificate = "client_certificate"
+
+	// AuthModeDefault uses the azidentity default credential chain, and is used if ARM_AUTH_MODE is not set.
+	AuthModeDefault = "default"
+
+	// AuthModeArmFake uses a credential accepted by the fake Azure Resource Manager, and is used if it is in use.
+	AuthModeArmFake = "armfake"
+)
+
+// authModes are the values accepted in ARM_AUTH_MODE.
+var authModes = []string{
+	AuthModeOidc,
+	AuthModeWorkloadIdentity,
+	AuthModeCli,
+	AuthModeMsi,
+	AuthModeClientSecret,
+	AuthModeClientCertificate,
+	AuthModeDefault,
+}
+
+// Environment variables, in order of precedence, read by the credential sources.
+var (
+	envTenantID            = []string{"ARM_TENANT_ID", "AZURE_TENANT_ID"}
+	envClientID            = []string{"ARM_CLIENT_ID", "AZURE_CLIENT_ID"}
+	envClientSecret        = []string{"ARM_CLIENT_SECRET", "AZURE_CLIENT_SECRET"}
+	envCertificatePath     = []string{"ARM_CLIENT_CERTIFICATE_PATH", "AZURE_CLIENT_CERTIFICATE_PATH"}
+	envCertificatePassword = []string{"ARM_CLIENT_CERTIFICATE_PASSWORD", "AZURE_CLIENT_CERTIFICATE_PASSWORD"}
+	envOidcToken           = []string{"ARM_OIDC_TOKEN"}
+	envOidcTokenFilePath   = []string{"ARM_OIDC_TOKEN_FILE_PATH"}
+	envOidcRequestToken    = []string{"ARM_OIDC_REQUEST_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"}
+	envOidcRequestUrl      = []string{"ARM_OIDC_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_URL"}
+	envFederatedTokenFile  = []string{"AZURE_FEDERATED_TOKEN_FILE"}
+	envAuthorityHost       = []string{"AZURE_AUTHORITY_HOST"}
+)
+
+// CredentialSource describes the credential selected for the azureutils clients, and why.
+type CredentialSource struct {
+	// Mode is the selected credential, one of the AuthMode* values.
+	Mode string
+
+	// Reason explains why the mode was selected.
+	Reason string
+
+	// Variables are the names of the environment variables used by the credential.
+	// Their values are not included as they may be secrets.
+	Variables []string
+
+	// values holds the values of the variables, keyed by the first name of each list of alternatives.
+	values map[string]string
+}
+
+// String returns a description of the credential source, for logging.
+func (cs CredentialSource) String() string {
+	s := fmt.Sprintf("credential %s selected because %s", cs.Mode, cs.Reason)
+	if len(cs.Variables) > 0 {
+		s += fmt.Sprintf(", using %s", strings.Join(cs.Variables, ", "))
+	}
+	return s
+}
+
+// SelectCredentialSource returns the credential that the azureutils clients use, and why it was selected,
+// without creating it.
+// It returns an error if ARM_AUTH_MODE is not valid, or if the environment variables required by the
+// selected mode are not set.
+//
+// The credential is selected as follows:
+//
+//   - the fake Azure Resource Manager credential, if ARMFAKE_ENDPOINT is set.
+//   - the mode in ARM_AUTH_MODE, if set.
+//   - oidc, if USE_OIDC or ARM_USE_OIDC is set.
+//   - the azidentity default credential chain.
+func SelectCredentialSource() (CredentialSource, error) {
+	cs := CredentialSource{values: make(map[string]string)}
+	switch {
+	case armFakeEndpoint() != "":
+		cs.Mode = AuthModeArmFake
+		cs.Reason = armfake.EnvEndpoint + " is set"
+		return cs, nil
+
+	case os.Getenv(EnvAuthMode) != "":
+		cs.Mode = strings.ToLower(os.Getenv(EnvAuthMode))
+		cs.Reason = fmt.Sprintf("%s is set to %s", EnvAuthMode, cs.Mode)
+		if !slices.Contains(authModes, cs.Mode) {
+			return cs, fmt.Errorf("invalid %s %q, must be one of: %s", EnvAuthMode, cs.Mode, strings.Join(authModes, ", "))
+		}
+
+	case multiEnvDefault("", "USE_OIDC", "ARM_USE_OIDC") != "":
+		cs.Mode = AuthModeOidc
+		cs.Reason = "USE_OIDC or ARM_USE_OIDC is set"
+
+	default:
+		cs.Mode = AuthModeDefault
+		cs.Reason = EnvAuthMode + " is not set"
+	}
+
+	errs := make([]error, 0)
+	require := func(names []string) {
+		if !cs.lookup(names) {
+			errs = append(errs, fmt.Errorf("%s requires %s to be set", cs.Mode, strings.Join(names, " or ")))
+		}
+	}
+	switch cs.Mode {
+	case AuthModeOidc:
+		require(envTenantID)
+		require(envClientID)
+		if !cs.lookup(envOidcToken) && !cs.lookup(envOidcTokenFilePath) {
+			hasUrl, hasToken := cs.lookup(envOidcRequestUrl), cs.lookup(envOidcRequestToken)
+			if !hasUrl || !hasToken {
+				errs = append(errs, fmt.Errorf("%s requires %s, %s, or both %s and %s to be set", cs.Mode,
+					envOidcToken[0], envOidcTokenFilePath[0], strings.Join(envOidcRequestUrl, " or "), strings.Join(envOidcRequestToken, " or ")))
+			}
+		}
+	case AuthModeWorkloadIdentity:
+		require(envTenantID)
+		require(envClientID)
+		require(envFederatedTokenFile)
+	case AuthModeCli:
+		cs.lookup(envTenantID)
+	case AuthModeMsi:
+		cs.lookup(envClientID)
+	case AuthModeClientSecret:
+		require(envTenantID)
+		require(envClientID)
+		require(envClientSecret)
+	case AuthModeClientCertificate:
+		require(envTenantID)
+		require(envClientID)
+		require(envCertificatePath)
+		cs.lookup(envCertificatePassword)
+	}
+	cs.lookup(envAuthorityHost)
+	sort.Strings(cs.Variables)
+	return cs, errors.Join(errs...)
+}
+
+// lookup records the first of the environment variables that is set, returning false if none are set.
+func (cs *CredentialSource) lookup(names []string) bool {
+	for _, n := range names {
+		if v := os.Getenv(n); v != "" {
+			cs.Variables = append(cs.Variables, n)
+			cs.values[names[0]] = v
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the value of the first set environment variable of the list of alternatives.
+func (cs CredentialSource) value(names []string) string {
+	return cs.values[names[0]]
+}
+
+// newCredential creates the credential for the source, in the supplied cloud.
+// AZURE_AUTHORITY_HOST, if set, overrides the authority host of the cloud.
+func (cs CredentialSource) newCredential(cloudConfig cloud.Configuration) (azcore.TokenCredential, error) {
+	if host := cs.value(envAuthorityHost); host != "" {
+		cloudConfig.ActiveDirectoryAuthorityHost = host
+	}
+	clientOpts := azcore.ClientOptions{
+		Cloud: cloudConfig,
+	}
+	tenantID, clientID := cs.value(envTenantID), cs.value(envClientID)
+
+	switch cs.Mode {
+	case AuthModeArmFake:
+		return armfake.Credential{}, nil
+
+	case AuthModeOidc:
+		return NewOidcCredential(&OidcCredentialOptions{
+			ClientOptions: clientOpts,
+			TenantID:      tenantID,
+			ClientID:      clientID,
+			RequestToken:  cs.value(envOidcRequestToken),
+			RequestUrl:    cs.value(envOidcRequestUrl),
+			Token:         cs.value(envOidcToken),
+			TokenFilePath: cs.value(envOidcTokenFilePath),
+		})
+
+	case AuthModeWorkloadIdentity:
+		// The projected token file is rotated by the kubelet, which the OIDC credential re-reads as it nears expiry.
+		return NewOidcCredential(&OidcCredentialOptions{
+			ClientOptions: clientOpts,
+			TenantID:      tenantID,
+			ClientID:      clientID,
+			TokenFilePath: cs.value(envFederatedTokenFile),
+		})
+
+	case AuthModeCli:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{
+			TenantID: tenantID,
+		})
+
+	case AuthModeMsi:
+		opts := &azidentity.ManagedIdentityCredentialOptions{
+			ClientOptions: clientOpts,
+		}
+		if clientID != "" {
+			opts.ID = azidentity.ClientID(clientID)
+		}
+		return azidentity.NewManagedIdentityCredential(opts)
+
+	case AuthModeClientSecret:
+		return azidentity.NewClientSecretCredential(tenantID, clientID, cs.value(envClientSecret), &azidentity.ClientSecretCredentialOptions{
+			ClientOptions: clientOpts,
+		})
+
+	case AuthModeClientCertificate:
+		data, err := os.ReadFile(cs.value(envCertificatePath))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read client certificate: %w", err)
+		}
+		certs, key, err := azidentity.ParseCertificates(data, []byte(cs.value(envCertificatePassword)))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse client certificate: %w", err)
+		}
+		return azidentity.NewClientCertificateCredential(tenantID, clientID, certs, key, &azidentity.ClientCertificateCredentialOptions{
+			ClientOptions: clientOpts,
+		})
+	}
+
+	// Get default credentials, this will look for the well-known environment variables,
+	// managed identity credentials, and az cli credentials
+	return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: clientOpts,
+	})
+}
@@ -0,0 +1,103 @@
+package azureutils
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearCredentialEnv unsets the environment variables read by SelectCredentialSource.
+// Tests using it cannot run in parallel as it sets environment variables.
+func clearCredentialEnv(t *testing.T) {
+	t.Setenv(armfake.EnvEndpoint, "")
+	t.Setenv(EnvAuthMode, "")
+	t.Setenv("USE_OIDC", "")
+	t.Setenv("ARM_USE_OIDC", "")
+	for _, names := range [][]string{
+		envTenantID, envClientID, envClientSecret, envCertificatePath, envCertificatePassword,
+		envOidcToken, envOidcTokenFilePath, envOidcRequestToken, envOidcRequestUrl,
+		envFederatedTokenFile, envAuthorityHost,
+	} {
+		for _, n := range names {
+			t.Setenv(n, "")
+		}
+	}
+}
+
+func TestSelectCredentialSourceWorkloadIdentity(t *testing.T) {
+	clearCredentialEnv(t)
+	t.Setenv(EnvAuthMode, "workload_identity")
+	t.Setenv("AZURE_TENANT_ID", "00000000-0000-0000-0000-000000000000")
+	t.Setenv("AZURE_CLIENT_ID", "00000000-0000-0000-0000-000000000001")
+	t.Setenv("AZURE_FEDERATED_TOKEN_FILE", "/var/run/secrets/azure/tokens/azure-identity-token")
+	t.Setenv("AZURE_AUTHORITY_HOST", "https://login.example.com/")
+
+	cs, err := SelectCredentialSource()
+	require.NoError(t, err)
+	assert.Equal(t, AuthModeWorkloadIdentity, cs.Mode)
+	assert.Equal(t, []string{"AZURE_AUTHORITY_HOST", "AZURE_CLIENT_ID", "AZURE_FEDERATED_TOKEN_FILE", "AZURE_TENANT_ID"}, cs.Variables)
+	assert.Equal(t, "credential workload_identity selected because ARM_AUTH_MODE is set to workload_identity, using AZURE_AUTHORITY_HOST, AZURE_CLIENT_ID, AZURE_FEDERATED_TOKEN_FILE, AZURE_TENANT_ID", cs.String())
+
+	cred, err := cs.newCredential(cloud.AzurePublic)
+	require.NoError(t, err)
+	oidc, ok := cred.(*OidcCredential)
+	require.True(t, ok, "workload identity should use the OIDC credential, got %T", cred)
+	assert.Equal(t, "/var/run/secrets/azure/tokens/azure-identity-token", oidc.tokenFilePath)
+}
+
+func TestSelectCredentialSourceMissingVariables(t *testing.T) {
+	clearCredentialEnv(t)
+	t.Setenv(EnvAuthMode, "client_secret")
+	t.Setenv("ARM_TENANT_ID", "00000000-0000-0000-0000-000000000000")
+
+	_, err := SelectCredentialSource()
+	assert.ErrorContains(t, err, "client_secret requires ARM_CLIENT_ID or AZURE_CLIENT_ID to be set")
+	assert.ErrorContains(t, err, "client_secret requires ARM_CLIENT_SECRET or AZURE_CLIENT_SECRET to be set")
+	assert.NotContains(t, err.Error(), "TENANT_ID")
+
+	t.Setenv(EnvAuthMode, "oidc")
+	t.Setenv("ARM_CLIENT_ID", "00000000-0000-0000-0000-000000000001")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", "https://token.actions.example.com")
+	_, err = SelectCredentialSource()
+	assert.ErrorContains(t, err, "oidc requires ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH, or both")
+
+	t.Setenv(EnvAuthMode, "kerberos")
+	_, err = SelectCredentialSource()
+	assert.ErrorContains(t, err, `invalid ARM_AUTH_MODE "kerberos", must be one of: oidc, workload_identity, cli, msi, client_secret, client_certificate, default`)
+}
+
+func TestSelectCredentialSourcePrecedence(t *testing.T) {
+	clearCredentialEnv(t)
+
+	cs, err := SelectCredentialSource()
+	require.NoError(t, err)
+	assert.Equal(t, AuthModeDefault, cs.Mode)
+	assert.Equal(t, "ARM_AUTH_MODE is not set", cs.Reason)
+
+	t.Setenv("ARM_USE_OIDC", "true")
+	t.Setenv("ARM_TENANT_ID", "00000000-0000-0000-0000-000000000000")
+	t.Setenv("ARM_CLIENT_ID", "00000000-0000-0000-0000-000000000001")
+	t.Setenv("ARM_OIDC_TOKEN", "token")
+	cs, err = SelectCredentialSource()
+	require.NoError(t, err)
+	assert.Equal(t, AuthModeOidc, cs.Mode)
+	assert.Equal(t, "USE_OIDC or ARM_USE_OIDC is set", cs.Reason)
+
+	t.Setenv(EnvAuthMode, "MSI")
+	cs, err = SelectCredentialSource()
+	require.NoError(t, err)
+	assert.Equal(t, AuthModeMsi, cs.Mode)
+	assert.Equal(t, []string{"ARM_CLIENT_ID"}, cs.Variables)
+	cred, err := cs.newCredential(cloud.AzurePublic)
+	require.NoError(t, err)
+	assert.IsType(t, &azidentity.ManagedIdentityCredential{}, cred)
+
+	t.Setenv(armfake.EnvEndpoint, "https://127.0.0.1:1")
+	cs, err = SelectCredentialSource()
+	require.NoError(t, err)
+	assert.Equal(t, AuthModeArmFake, cs.Mode)
+}
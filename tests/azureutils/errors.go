@@ -0,0 +1,108 @@
+package azureutils
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+)
+
+// The categories of the error responses from Azure Resource Manager.
+// The errors returned by the helpers in this package wrap the *azcore.ResponseError of a failed request
+// together with the categories it falls into, so they can be tested with errors.Is, e.g. errors.Is(err, ErrNotFound),
+// and the response error can still be extracted with errors.As. A response may fall into more than one category.
+var (
+	// ErrNotFound is a response with status 404 Not Found.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict is a response with status 409 Conflict, e.g. another operation on the resource is in progress.
+	ErrConflict = errors.New("conflict")
+
+	// ErrThrottled is a response with status 429 Too Many Requests.
+	ErrThrottled = errors.New("throttled")
+
+	// ErrAuthorizationFailed is a response with status 403 Forbidden, or an AuthorizationFailed error code,
+	// e.g. the credential has no role assignment that allows the operation.
+	ErrAuthorizationFailed = errors.New("authorization failed")
+
+	// ErrSubscriptionNotActive is a response to an operation that needs an active subscription,
+	// e.g. cancelling a subscription that is already cancelled.
+	ErrSubscriptionNotActive = errors.New("subscription not active")
+
+	// ErrResourceGroupLocked is a response to an operation prevented by a management lock, the ScopeLocked error code,
+	// e.g. deleting a resource group with a CanNotDelete lock.
+	ErrResourceGroupLocked = errors.New("resource group locked")
+)
+
+var (
+	// authorizationFailedCodes are the error codes of ErrAuthorizationFailed.
+	authorizationFailedCodes = []string{"AuthorizationFailed", "LinkedAuthorizationFailed"}
+
+	// subscriptionNotActiveCodes are the error codes of ErrSubscriptionNotActive.
+	subscriptionNotActiveCodes = []string{"SubscriptionNotActive", "ReadOnlyDisabledSubscription", "DisabledSubscription"}
+)
+
+// subscriptionNotActiveMessage is in the message of a response to cancelling a subscription that is not active,
+// whatever its error code.
+const subscriptionNotActiveMessage = "Subscription is not in active state"
+
+// classifiedError is an error wrapping an *azcore.ResponseError, with the categories of the response.
+type classifiedError struct {
+	err        error
+	categories []error
+}
+
+// Error returns the message of the wrapped error.
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error and the categories, for errors.Is and errors.As.
+func (e *classifiedError) Unwrap() []error {
+	return append([]error{e.err}, e.categories...)
+}
+
+// ClassifyError returns the error wrapped with the categories of the *azcore.ResponseError it wraps, see ErrNotFound.
+// The error is returned as is if it is nil, does not wrap a response error, or is already classified.
+func ClassifyError(err error) error {
+	var c *classifiedError
+	var re *azcore.ResponseError
+	if err == nil || errors.As(err, &c) || !errors.As(err, &re) {
+		return err
+	}
+	return &classifiedError{err: err, categories: responseCategories(re)}
+}
+
+// responseCategories returns the categories of the error response.
+func responseCategories(re *azcore.ResponseError) []error {
+	var categories []error
+	switch re.StatusCode {
+	case http.StatusNotFound:
+		categories = append(categories, ErrNotFound)
+	case http.StatusConflict:
+		categories = append(categories, ErrConflict)
+	case http.StatusTooManyRequests:
+		categories = append(categories, ErrThrottled)
+	}
+	if re.StatusCode == http.StatusForbidden || hasErrorCode(re, authorizationFailedCodes...) {
+		categories = append(categories, ErrAuthorizationFailed)
+	}
+	if hasErrorCode(re, subscriptionNotActiveCodes...) || strings.Contains(re.Error(), subscriptionNotActiveMessage) {
+		categories = append(categories, ErrSubscriptionNotActive)
+	}
+	if hasErrorCode(re, "ScopeLocked") {
+		categories = append(categories, ErrResourceGroupLocked)
+	}
+	return categories
+}
+
+// hasErrorCode returns true if the error code of the response is one of the codes, ignoring case.
+func hasErrorCode(re *azcore.ResponseError, codes ...string) bool {
+	for _, c := range codes {
+		if strings.EqualFold(re.ErrorCode, c) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// responseError returns the *azcore.ResponseError of a response with the status, error code and message.
+func responseError(t *testing.T, status int, code, message string) error {
+	req, err := http.NewRequest(http.MethodGet, "https://management.azure.com/subscriptions", nil)
+	require.NoError(t, err)
+	body := fmt.Sprintf(`{"error":{"code":%q,"message":%q}}`, code, message)
+	return runtime.NewResponseError(&http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	})
+}
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	all := []error{ErrNotFound, ErrConflict, ErrThrottled, ErrAuthorizationFailed, ErrSubscriptionNotActive, ErrResourceGroupLocked}
+	cases := []struct {
+		status  int
+		code    string
+		message string
+		want    []error
+	}{
+		{http.StatusNotFound, "ResourceGroupNotFound", "Resource group 'rg' could not be found.", []error{ErrNotFound}},
+		{http.StatusConflict, "AnotherOperationInProgress", "Another operation is in progress.", []error{ErrConflict}},
+		{http.StatusTooManyRequests, "TooManyRequests", "Too many requests.", []error{ErrThrottled}},
+		{http.StatusForbidden, "AuthorizationFailed", "The client does not have authorization.", []error{ErrAuthorizationFailed}},
+		{http.StatusBadRequest, "LinkedAuthorizationFailed", "The client does not have permission on the linked scope.", []error{ErrAuthorizationFailed}},
+		{http.StatusConflict, "SubscriptionNotActive", "Subscription is not in active state.", []error{ErrConflict, ErrSubscriptionNotActive}},
+		{http.StatusBadRequest, "BadRequest", "Subscription is not in active state.", []error{ErrSubscriptionNotActive}},
+		{http.StatusConflict, "ReadOnlyDisabledSubscription", "The subscription is disabled.", []error{ErrConflict, ErrSubscriptionNotActive}},
+		{http.StatusConflict, "ScopeLocked", "The scope cannot perform delete operation because of a lock.", []error{ErrConflict, ErrResourceGroupLocked}},
+		{http.StatusBadRequest, "InvalidRequestContent", "The request content was invalid.", nil},
+	}
+	for _, c := range cases {
+		err := fmt.Errorf("cannot do it: %w", ClassifyError(responseError(t, c.status, c.code, c.message)))
+		for _, category := range all {
+			assert.Equal(t, errorIn(category, c.want), errors.Is(err, category), "%s is %s", c.code, category)
+		}
+		var re *azcore.ResponseError
+		require.ErrorAs(t, err, &re, c.code)
+		assert.Equal(t, c.code, re.ErrorCode)
+	}
+
+	err := ClassifyError(responseError(t, http.StatusNotFound, "NotFound", "Not found."))
+	assert.Same(t, err, ClassifyError(err), "an error is classified once")
+	wrapped := fmt.Errorf("wrapped: %w", err)
+	assert.Same(t, wrapped, ClassifyError(wrapped), "a wrapped classified error is not classified again")
+	plain := errors.New("plain")
+	assert.Same(t, plain, ClassifyError(plain))
+	assert.NoError(t, ClassifyError(nil))
+}
+
+// errorIn returns true if err is one of the errors.
+func errorIn(err error, errs []error) bool {
+	for _, e := range errs {
+		if e == err {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHelperErrorsAreClassified(t *testing.T) {
+	srv, id := newArmFake(t)
+	ctx := context.Background()
+	sub := "/subscriptions/" + id.String()
+	srv.PutResource(sub+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock-rg1", lockBody)
+
+	_, err := GetSubscription(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	err = DeleteResourceGroup(ctx, "rg1", id)
+	assert.ErrorIs(t, err, ErrResourceGroupLocked)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	// the REST helpers classify their errors too
+	err = DeleteLock(ctx, sub+"/resourceGroups/rg2/providers/Microsoft.Authorization/locks/lock-rg2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	var re *azcore.ResponseError
+	require.ErrorAs(t, err, &re)
+	assert.Equal(t, http.StatusNotFound, re.StatusCode)
+
+	f, err := DefaultFactory()
+	require.NoError(t, err)
+	client, err := f.SubscriptionClient()
+	require.NoError(t, err)
+	_, err = client.Cancel(ctx, id.String(), nil)
+	require.NoError(t, err)
+	_, err = client.Cancel(ctx, id.String(), nil)
+	assert.ErrorIs(t, ClassifyError(err), ErrSubscriptionNotActive)
+}
@@ -0,0 +1,411 @@
+package azureutils
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/consumption/armconsumption"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/msi/armmsi"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armfeatures"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armlocks"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/cassette"
+	"github.com/google/uuid"
+)
+
+const (
+	// telemetryApplicationID is added to the User-Agent of the requests made by the clients.
+	telemetryApplicationID = "lz-vending-tests"
+
+	// defaultMaxRetries is the number of times a failed request is retried.
+	defaultMaxRetries = 5
+)
+
+// FactoryOptions are the options for NewFactory.
+type FactoryOptions struct {
+	// Credential is used by all the clients of the factory.
+	// If nil, the credential selected by SelectCredentialSource is used.
+	Credential azcore.TokenCredential
+
+	// Transport sends the requests of all the clients of the factory, e.g. a fake in tests.
+	// If nil, the default transport is used, or the one that trusts the fake Azure Resource Manager if it is in use.
+	Transport policy.Transporter
+
+	// Throttle are the options of the policy that retries the requests throttled by Azure Resource Manager.
+	// If nil, the defaults are used, see ThrottleOptions.
+	Throttle *ThrottleOptions
+}
+
+// Factory creates the Azure SDK clients used by the helpers in this package, sharing one credential and set of client options.
+// The clients are created when first used and then reused. A Factory is safe for concurrent use.
+type Factory struct {
+	cred azcore.TokenCredential
+	opts *arm.ClientOptions
+
+	throttle *throttlePolicy
+
+	mu      sync.Mutex
+	clients map[string]any
+}
+
+// defaultFactory is the factory used by the package level functions.
+// It is replaced if the environment variables that configure it change, e.g. by t.Setenv in a test.
+var defaultFactory struct {
+	mu      sync.Mutex
+	key     string
+	factory *Factory
+}
+
+// NewFactory creates a client factory.
+// The credential and client options are those selected by the environment, unless overridden by the options.
+// If the AZUREUTILS_CASSETTE_DIR environment variable is set, the requests of the clients are recorded to cassettes in the directory,
+// see the cassette package.
+func NewFactory(options *FactoryOptions) (*Factory, error) {
+	if options == nil {
+		options = &FactoryOptions{}
+	}
+	opts, err := newClientOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client options: %w", err)
+	}
+	opts.Retry.MaxRetries = defaultMaxRetries
+	opts.Retry.TryTimeout = requestTimeout
+	opts.Retry.StatusCodes = retryStatusCodes
+	throttle := newThrottlePolicy(options.Throttle)
+	opts.PerCallPolicies = append(opts.PerCallPolicies, throttle)
+	opts.Telemetry.ApplicationID = telemetryApplicationID
+	if options.Transport != nil {
+		opts.Transport = options.Transport
+	}
+	if dir := os.Getenv(cassette.EnvDir); dir != "" {
+		rec, err := cassette.NewRecorder(nil, dir)
+		if err != nil {
+			return nil, err
+		}
+		opts.PerRetryPolicies = append(opts.PerRetryPolicies, rec.Policy())
+	}
+
+	cred := options.Credential
+	if cred == nil {
+		if cred, err = newDefaultAzureCredential(); err != nil {
+			return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+		}
+	}
+	return &Factory{
+		cred:     cred,
+		opts:     opts,
+		throttle: throttle,
+		clients:  make(map[string]any),
+	}, nil
+}
+
+// DefaultFactory returns the factory used by the package level functions, e.g. ListResourceGroup.
+// It is created on first use, and again if the environment variables that select the credential or cloud have changed.
+func DefaultFactory() (*Factory, error) {
+	key := factoryEnvKey()
+	defaultFactory.mu.Lock()
+	defer defaultFactory.mu.Unlock()
+	if defaultFactory.factory != nil && defaultFactory.key == key {
+		return defaultFactory.factory, nil
+	}
+	f, err := NewFactory(nil)
+	if err != nil {
+		return nil, err
+	}
+	defaultFactory.key, defaultFactory.factory = key, f
+	return f, nil
+}
+
+// factoryEnvKey returns the values of the environment variables read when creating a factory,
+// which identifies the default factory to use.
+func factoryEnvKey() string {
+	names := []string{
+		armfake.EnvEndpoint, armfake.EnvCACert, cassette.EnvDir, EnvAuthMode, "USE_OIDC", "ARM_USE_OIDC",
+		EnvEnvironment, EnvMetadataHost, EnvEnvironmentFile,
+	}
+	for _, n := range [][]string{
+		envTenantID, envClientID, envClientSecret, envCertificatePath, envCertificatePassword,
+		envOidcToken, envOidcTokenFilePath, envOidcRequestToken, envOidcRequestUrl,
+		envFederatedTokenFile, envAuthorityHost,
+	} {
+		names = append(names, n...)
+	}
+	var b strings.Builder
+	for _, n := range names {
+		b.WriteString(n + "=" + os.Getenv(n) + "\n")
+	}
+	return b.String()
+}
+
+// client returns the client cached under the key, creating it on first use.
+func client[C any](f *Factory, key string, create func(azcore.TokenCredential, *arm.ClientOptions) (C, error)) (C, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[key]; ok {
+		return c.(C), nil
+	}
+	c, err := create(f.cred, f.opts)
+	if err != nil {
+		return c, err
+	}
+	f.clients[key] = c
+	return c, nil
+}
+
+// Credential returns the credential shared by the clients of the factory.
+func (f *Factory) Credential() azcore.TokenCredential {
+	return f.cred
+}
+
+// Throttling calls Factory.Throttling using the default factory, see DefaultFactory.
+func Throttling() (ThrottleStats, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return ThrottleStats{}, err
+	}
+	return f.Throttling(), nil
+}
+
+// Throttling returns the counters of the requests throttled by Azure Resource Manager, for all the clients of the factory
+// and those created with its client options.
+func (f *Factory) Throttling() ThrottleStats {
+	return f.throttle.Stats()
+}
+
+// ClientOptions returns a copy of the client options shared by the clients of the factory,
+// for creating clients the factory does not provide.
+func (f *Factory) ClientOptions() *arm.ClientOptions {
+	opts := *f.opts
+	opts.PerCallPolicies = slices.Clone(f.opts.PerCallPolicies)
+	opts.PerRetryPolicies = slices.Clone(f.opts.PerRetryPolicies)
+	return &opts
+}
+
+// SubnetsClient returns the subnets client for the subscription.
+func (f *Factory) SubnetsClient(subId uuid.UUID) (*armnetwork.SubnetsClient, error) {
+	c, err := client(f, "subnets/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armnetwork.SubnetsClient, error) {
+		return armnetwork.NewSubnetsClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subnet client: %w", err)
+	}
+	return c, nil
+}
+
+// VirtualNetworksClient returns the virtual networks client for the subscription.
+func (f *Factory) VirtualNetworksClient(subId uuid.UUID) (*armnetwork.VirtualNetworksClient, error) {
+	c, err := client(f, "virtualnetworks/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armnetwork.VirtualNetworksClient, error) {
+		return armnetwork.NewVirtualNetworksClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create virtual network client: %w", err)
+	}
+	return c, nil
+}
+
+// VirtualNetworkPeeringsClient returns the virtual network peerings client for the subscription.
+func (f *Factory) VirtualNetworkPeeringsClient(subId uuid.UUID) (*armnetwork.VirtualNetworkPeeringsClient, error) {
+	c, err := client(f, "virtualnetworkpeerings/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armnetwork.VirtualNetworkPeeringsClient, error) {
+		return armnetwork.NewVirtualNetworkPeeringsClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create virtual network peering client: %w", err)
+	}
+	return c, nil
+}
+
+// VirtualHubsClient returns the virtual hubs client for the subscription.
+func (f *Factory) VirtualHubsClient(subId uuid.UUID) (*armnetwork.VirtualHubsClient, error) {
+	c, err := client(f, "virtualhubs/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armnetwork.VirtualHubsClient, error) {
+		return armnetwork.NewVirtualHubsClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create virtual hub client: %w", err)
+	}
+	return c, nil
+}
+
+// HubVirtualNetworkConnectionsClient returns the virtual hub connections client for the subscription.
+func (f *Factory) HubVirtualNetworkConnectionsClient(subId uuid.UUID) (*armnetwork.HubVirtualNetworkConnectionsClient, error) {
+	c, err := client(f, "hubvirtualnetworkconnections/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armnetwork.HubVirtualNetworkConnectionsClient, error) {
+		return armnetwork.NewHubVirtualNetworkConnectionsClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create virtual hub connection client: %w", err)
+	}
+	return c, nil
+}
+
+// ResourceGroupsClient returns the resource groups client for the subscription.
+func (f *Factory) ResourceGroupsClient(subId uuid.UUID) (*armresources.ResourceGroupsClient, error) {
+	c, err := client(f, "resourcegroups/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armresources.ResourceGroupsClient, error) {
+		return armresources.NewResourceGroupsClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource group client: %w", err)
+	}
+	return c, nil
+}
+
+// ManagementLocksClient returns the management locks client for the subscription.
+func (f *Factory) ManagementLocksClient(subId uuid.UUID) (*armlocks.ManagementLocksClient, error) {
+	c, err := client(f, "locks/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armlocks.ManagementLocksClient, error) {
+		return armlocks.NewManagementLocksClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management locks client: %w", err)
+	}
+	return c, nil
+}
+
+// ProvidersClient returns the resource providers client for the subscription.
+func (f *Factory) ProvidersClient(subId uuid.UUID) (*armresources.ProvidersClient, error) {
+	c, err := client(f, "providers/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armresources.ProvidersClient, error) {
+		return armresources.NewProvidersClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource provider client: %w", err)
+	}
+	return c, nil
+}
+
+// TagsClient returns the tags client.
+// It is not bound to a subscription, as it is only used with operations that take a scope.
+func (f *Factory) TagsClient() (*armresources.TagsClient, error) {
+	c, err := client(f, "tags", func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armresources.TagsClient, error) {
+		return armresources.NewTagsClient("", cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tags client: %w", err)
+	}
+	return c, nil
+}
+
+// FeaturesClient returns the preview features client for the subscription.
+func (f *Factory) FeaturesClient(subId uuid.UUID) (*armfeatures.Client, error) {
+	c, err := client(f, "features/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armfeatures.Client, error) {
+		return armfeatures.NewClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create features client: %w", err)
+	}
+	return c, nil
+}
+
+// UserAssignedIdentitiesClient returns the user assigned identities client for the subscription.
+func (f *Factory) UserAssignedIdentitiesClient(subId uuid.UUID) (*armmsi.UserAssignedIdentitiesClient, error) {
+	c, err := client(f, "userassignedidentities/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armmsi.UserAssignedIdentitiesClient, error) {
+		return armmsi.NewUserAssignedIdentitiesClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user assigned identity client: %w", err)
+	}
+	return c, nil
+}
+
+// FederatedIdentityCredentialsClient returns the federated identity credentials client for the subscription.
+func (f *Factory) FederatedIdentityCredentialsClient(subId uuid.UUID) (*armmsi.FederatedIdentityCredentialsClient, error) {
+	c, err := client(f, "federatedidentitycredentials/"+subId.String(), func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armmsi.FederatedIdentityCredentialsClient, error) {
+		return armmsi.NewFederatedIdentityCredentialsClient(subId.String(), cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create federated identity credential client: %w", err)
+	}
+	return c, nil
+}
+
+// RoleAssignmentsClient returns the role assignments client.
+// It is not bound to a subscription, as it is only used with operations that take a scope.
+func (f *Factory) RoleAssignmentsClient() (*armauthorization.RoleAssignmentsClient, error) {
+	c, err := client(f, "roleassignments", func(cred azcore.TokenCredential, opts *arm.ClientOptions) (*armauthorization.RoleAssignmentsClient, error) {
+		return armauthorization.NewRoleAssignmentsClient("", cred, opts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role assignment client: %w", err)
+	}
+	return c, nil
+}
+
+// RoleDefinitionsClient returns the role definitions client.
+func (f *Factory) RoleDefinitionsClient() (*armauthorization.RoleDefinitionsClient, error) {
+	c, err := client(f, "roledefinitions", armauthorization.NewRoleDefinitionsClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create role definition client: %w", err)
+	}
+	return c, nil
+}
+
+// BudgetsClient returns the consumption budgets client.
+// It is not bound to a subscription, as budgets are at a scope, e.g. a subscription or resource group.
+func (f *Factory) BudgetsClient() (*armconsumption.BudgetsClient, error) {
+	c, err := client(f, "budgets", armconsumption.NewBudgetsClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create budgets client: %w", err)
+	}
+	return c, nil
+}
+
+// SubscriptionsClient returns the subscriptions client.
+func (f *Factory) SubscriptionsClient() (*armsubscription.SubscriptionsClient, error) {
+	c, err := client(f, "subscriptions", armsubscription.NewSubscriptionsClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscriptions client: %w", err)
+	}
+	return c, nil
+}
+
+// SubscriptionClient returns the subscription client, used to cancel subscriptions.
+func (f *Factory) SubscriptionClient() (*armsubscription.Client, error) {
+	c, err := client(f, "subscription", armsubscription.NewClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription client: %w", err)
+	}
+	return c, nil
+}
+
+// AliasClient returns the subscription alias client.
+func (f *Factory) AliasClient() (*armsubscription.AliasClient, error) {
+	c, err := client(f, "alias", armsubscription.NewAliasClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alias client: %w", err)
+	}
+	return c, nil
+}
+
+// ManagementGroupsClient returns the management groups client.
+func (f *Factory) ManagementGroupsClient() (*armmanagementgroups.Client, error) {
+	c, err := client(f, "managementgroups", armmanagementgroups.NewClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management group client: %w", err)
+	}
+	return c, nil
+}
+
+// EntitiesClient returns the management group entities client, used to resolve the hierarchy of a subscription.
+func (f *Factory) EntitiesClient() (*armmanagementgroups.EntitiesClient, error) {
+	c, err := client(f, "entities", armmanagementgroups.NewEntitiesClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management group entities client: %w", err)
+	}
+	return c, nil
+}
+
+// ManagementGroupSubscriptionsClient returns the management group subscriptions client.
+func (f *Factory) ManagementGroupSubscriptionsClient() (*armmanagementgroups.ManagementGroupSubscriptionsClient, error) {
+	c, err := client(f, "managementgroupsubscriptions", armmanagementgroups.NewManagementGroupSubscriptionsClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create management group subscription client: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,114 @@
+package azureutils
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/cassette"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingCredential counts the tokens requested from the fake credential.
+type countingCredential struct {
+	calls atomic.Int32
+}
+
+func (c *countingCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.calls.Add(1)
+	return armfake.Credential{}.GetToken(ctx, opts)
+}
+
+// countingTransport counts the requests sent by the clients, and records their User-Agent.
+type countingTransport struct {
+	client    *http.Client
+	calls     atomic.Int32
+	userAgent atomic.Value
+}
+
+func (c *countingTransport) Do(req *http.Request) (*http.Response, error) {
+	c.calls.Add(1)
+	c.userAgent.Store(req.Header.Get("User-Agent"))
+	return c.client.Do(req)
+}
+
+func TestFactorySharesCredentialAndClients(t *testing.T) {
+	srv, id := newArmFake(t)
+	for _, rg := range []string{"rg1", "rg2", "rg3", "rg4", "rg5"} {
+		srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/"+rg, map[string]any{"location": "westeurope"})
+	}
+	cred := &countingCredential{}
+	transport := &countingTransport{client: srv.Client()}
+
+	f, err := NewFactory(&FactoryOptions{
+		Credential: cred,
+		Transport:  transport,
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &id))
+	assert.Empty(t, srv.ResourceIDs("/subscriptions/"+id.String()+"/resourceGroups"))
+
+	// One token per client, rather than one per resource group.
+	assert.LessOrEqual(t, cred.calls.Load(), int32(4))
+	assert.Positive(t, transport.calls.Load())
+	assert.True(t, strings.HasPrefix(transport.userAgent.Load().(string), telemetryApplicationID+" "))
+
+	c1, err := f.ResourceGroupsClient(id)
+	require.NoError(t, err)
+	c2, err := f.ResourceGroupsClient(id)
+	require.NoError(t, err)
+	assert.Same(t, c1, c2)
+}
+
+func TestDefaultFactory(t *testing.T) {
+	newArmFake(t)
+
+	f1, err := DefaultFactory()
+	require.NoError(t, err)
+	f2, err := DefaultFactory()
+	require.NoError(t, err)
+	assert.Same(t, f1, f2)
+
+	// A new fake changes the environment, so the default factory is replaced.
+	newArmFake(t)
+	f3, err := DefaultFactory()
+	require.NoError(t, err)
+	assert.NotSame(t, f1, f3)
+}
+
+func TestFactoryClientOptions(t *testing.T) {
+	newArmFake(t)
+	f, err := NewFactory(nil)
+	require.NoError(t, err)
+
+	opts := f.ClientOptions()
+	n := len(f.opts.PerCallPolicies)
+	opts.PerCallPolicies = append(opts.PerCallPolicies[:0], nil)
+	opts.PerRetryPolicies = append(opts.PerRetryPolicies, nil)
+	assert.Len(t, f.opts.PerCallPolicies, n)
+	assert.NotNil(t, f.opts.PerCallPolicies[0], "the policies of the factory are not changed")
+	assert.Empty(t, f.opts.PerRetryPolicies)
+}
+
+func TestFactoryRecordsCassettes(t *testing.T) {
+	_, id := newArmFake(t)
+	dir := t.TempDir()
+	t.Setenv(cassette.EnvDir, dir)
+	f, err := NewFactory(nil)
+	require.NoError(t, err)
+
+	_, err = f.ListResourceGroup(cassette.WithName(context.Background(), "TestList"), id)
+	require.NoError(t, err)
+	c, err := cassette.Load(filepath.Join(dir, "TestList.json"))
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 1)
+	assert.Equal(t, http.MethodGet, c.Interactions[0].Request.Method)
+	assert.Equal(t, http.StatusOK, c.Interactions[0].Response.StatusCode)
+}
@@ -0,0 +1,225 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/google/uuid"
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+// ExpectedHubConnection is the state of a virtual hub connection expected from the input variables.
+type ExpectedHubConnection struct {
+	VirtualHubID           string // The resource id of the virtual hub the connection belongs to.
+	Name                   string // The name of the connection.
+	RemoteVirtualNetworkID string // The resource id of the connected virtual network.
+	EnableInternetSecurity bool
+
+	// RoutingIntent is true if the hub uses routing intent, in which case the connection must have no routing configuration
+	// and the route tables below are not checked.
+	RoutingIntent bool
+
+	AssociatedRouteTableID     string
+	PropagatedRouteTableIDs    []string
+	PropagatedRouteTableLabels []string
+}
+
+// ID returns the resource id of the connection.
+func (c ExpectedHubConnection) ID() string {
+	return c.VirtualHubID + "/hubVirtualNetworkConnections/" + c.Name
+}
+
+// HubConnections returns the virtual hub connections expected from the virtual networks of the inputs, see inputs.Inputs.VirtualNetworks,
+// using the resource ids of the deployed virtual networks, e.g. from the virtual_network_resource_ids output.
+// The name and route tables of the connections are calculated as the submodule does when they are not supplied.
+func HubConnections(vnets map[string]*inputs.VirtualNetwork, vnetIDs map[string]string) ([]ExpectedHubConnection, error) {
+	conns := make([]ExpectedHubConnection, 0)
+	for k, v := range vnets {
+		if !deref(v.VwanConnectionEnabled) {
+			continue
+		}
+		id, ok := vnetIDs[k]
+		if !ok {
+			return nil, fmt.Errorf("no resource id for virtual network %s", k)
+		}
+		hub := deref(v.VwanHubResourceID)
+		if hub == "" {
+			return nil, fmt.Errorf("virtual network %s has a virtual hub connection enabled but no vwan_hub_resource_id", k)
+		}
+		sec := valueOr(v.VwanSecurityConfiguration, inputs.VwanSecurityConfiguration{})
+		c := ExpectedHubConnection{
+			VirtualHubID:           hub,
+			Name:                   coalesce(deref(v.VwanConnectionName), "vhc-"+uuid.NewSHA1(uuid.NameSpaceURL, []byte(id)).String()),
+			RemoteVirtualNetworkID: id,
+			EnableInternetSecurity: deref(sec.SecureInternetTraffic),
+			RoutingIntent:          deref(sec.RoutingIntentEnabled),
+		}
+		if !c.RoutingIntent {
+			c.AssociatedRouteTableID = coalesce(deref(v.VwanAssociatedRoutetableResourceID), hub+"/hubRouteTables/defaultRouteTable")
+			if deref(sec.SecurePrivateTraffic) {
+				c.PropagatedRouteTableIDs = coalesceList(v.VwanPropagatedRoutetablesResourceIDs, []string{hub + "/hubRouteTables/noneRouteTable"})
+				c.PropagatedRouteTableLabels = []string{"none"}
+			} else {
+				c.PropagatedRouteTableIDs = coalesceList(v.VwanPropagatedRoutetablesResourceIDs, []string{hub + "/hubRouteTables/defaultRouteTable"})
+				c.PropagatedRouteTableLabels = coalesceList(v.VwanPropagatedRoutetablesLabels, []string{"default"})
+			}
+		}
+		conns = append(conns, c)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].ID() < conns[j].ID()
+	})
+	return conns, nil
+}
+
+// VerifyHubConnections calls Factory.VerifyHubConnections using the default factory, see DefaultFactory.
+func VerifyHubConnections(ctx context.Context, t TestingT, conns []ExpectedHubConnection, opts *VerifyOptions) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.VerifyHubConnections(ctx, t, conns, opts)
+}
+
+// VerifyHubConnections checks that Azure reports each virtual hub connection as provisioned,
+// with the expected remote virtual network, internet security and routing configuration.
+// The connection is polled until it is provisioned or the retries are exhausted.
+// The errors of all the connections are returned together.
+func (f *Factory) VerifyHubConnections(ctx context.Context, t TestingT, conns []ExpectedHubConnection, opts *VerifyOptions) error {
+	var errs []error
+	for _, c := range conns {
+		c := c
+		err := poll(ctx, t, "verify virtual hub connection "+c.ID(), opts, func(ctx context.Context) error {
+			return f.verifyHubConnection(ctx, c)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("virtual hub connection %s: %w", c.ID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// verifyHubConnection checks the connection once.
+// Differences in the configuration are returned as a retry.FatalError so they are not polled.
+func (f *Factory) verifyHubConnection(ctx context.Context, c ExpectedHubConnection) error {
+	conn, err := f.GetHubConnection(ctx, c.ID())
+	if err != nil {
+		return err
+	}
+	props := conn.Properties
+	if props == nil {
+		return fmt.Errorf("virtual hub connection has no properties")
+	}
+	if state := deref(props.ProvisioningState); state != armnetwork.ProvisioningStateSucceeded {
+		return fmt.Errorf("provisioningState is %s, expected %s", state, armnetwork.ProvisioningStateSucceeded)
+	}
+
+	var diffs []string
+	remote := ""
+	if props.RemoteVirtualNetwork != nil {
+		remote = deref(props.RemoteVirtualNetwork.ID)
+	}
+	if !strings.EqualFold(remote, c.RemoteVirtualNetworkID) {
+		diffs = append(diffs, fmt.Sprintf("remoteVirtualNetwork is %s, expected %s", remote, c.RemoteVirtualNetworkID))
+	}
+	if got := deref(props.EnableInternetSecurity); got != c.EnableInternetSecurity {
+		diffs = append(diffs, fmt.Sprintf("enableInternetSecurity is %t, expected %t", got, c.EnableInternetSecurity))
+	}
+
+	rc := props.RoutingConfiguration
+	switch {
+	case c.RoutingIntent:
+		if rc != nil && (rc.AssociatedRouteTable != nil || (rc.PropagatedRouteTables != nil && len(rc.PropagatedRouteTables.IDs) > 0)) {
+			diffs = append(diffs, "routingConfiguration is set, expected none as routing intent is enabled")
+		}
+	case rc == nil:
+		diffs = append(diffs, "routingConfiguration is not set")
+	default:
+		associated := ""
+		if rc.AssociatedRouteTable != nil {
+			associated = deref(rc.AssociatedRouteTable.ID)
+		}
+		if !strings.EqualFold(associated, c.AssociatedRouteTableID) {
+			diffs = append(diffs, fmt.Sprintf("associatedRouteTable is %s, expected %s", associated, c.AssociatedRouteTableID))
+		}
+		var ids, labels []string
+		if rc.PropagatedRouteTables != nil {
+			for _, r := range rc.PropagatedRouteTables.IDs {
+				if r != nil {
+					ids = append(ids, deref(r.ID))
+				}
+			}
+			for _, l := range rc.PropagatedRouteTables.Labels {
+				labels = append(labels, deref(l))
+			}
+		}
+		if !sameStrings(ids, c.PropagatedRouteTableIDs) {
+			diffs = append(diffs, fmt.Sprintf("propagatedRouteTables.ids is %v, expected %v", ids, c.PropagatedRouteTableIDs))
+		}
+		if !sameStrings(labels, c.PropagatedRouteTableLabels) {
+			diffs = append(diffs, fmt.Sprintf("propagatedRouteTables.labels is %v, expected %v", labels, c.PropagatedRouteTableLabels))
+		}
+	}
+	if len(diffs) > 0 {
+		return retry.FatalError{Underlying: errors.New(strings.Join(diffs, ", "))}
+	}
+	return nil
+}
+
+// GetHubConnection calls Factory.GetHubConnection using the default factory, see DefaultFactory.
+func GetHubConnection(ctx context.Context, id string) (*armnetwork.HubVirtualNetworkConnection, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetHubConnection(ctx, id)
+}
+
+// GetHubConnection returns the virtual hub connection with the supplied resource id.
+func (f *Factory) GetHubConnection(ctx context.Context, id string) (*armnetwork.HubVirtualNetworkConnection, error) {
+	rid, err := arm.ParseResourceID(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse virtual hub connection id %s: %w", id, err)
+	}
+	subId, err := uuid.Parse(rid.SubscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse subscription id of virtual hub connection %s: %w", id, err)
+	}
+	client, err := f.HubVirtualNetworkConnectionsClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(ctx, rid.ResourceGroupName, rid.Parent.Name, rid.Name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get virtual hub connection: %w", ClassifyError(err))
+	}
+	return &resp.HubVirtualNetworkConnection, nil
+}
+
+// sameStrings returns true if the lists contain the same strings, ignoring order and case, as resource ids are case insensitive.
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	norm := func(l []string) []string {
+		n := make([]string, len(l))
+		for i, s := range l {
+			n[i] = strings.ToLower(s)
+		}
+		sort.Strings(n)
+		return n
+	}
+	na, nb := norm(a), norm(b)
+	for i := range na {
+		if na[i] != nb[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubConnections(t *testing.T) {
+	hub := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/hub/providers/Microsoft.Network/virtualHubs/hub"
+	spoke := "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/spoke/providers/Microsoft.Network/virtualNetworks/spoke"
+	vnets := map[string]*inputs.VirtualNetwork{
+		"primary": inputs.NewVirtualNetwork("spoke", "spoke").WithVwanConnection(hub),
+	}
+
+	conns, err := HubConnections(vnets, map[string]string{"primary": spoke})
+	require.NoError(t, err)
+	assert.Equal(t, []ExpectedHubConnection{{
+		VirtualHubID:               hub,
+		Name:                       "vhc-" + peeringName(spoke)[len("peer-"):],
+		RemoteVirtualNetworkID:     spoke,
+		AssociatedRouteTableID:     hub + "/hubRouteTables/defaultRouteTable",
+		PropagatedRouteTableIDs:    []string{hub + "/hubRouteTables/defaultRouteTable"},
+		PropagatedRouteTableLabels: []string{"default"},
+	}}, conns)
+
+	vnets["primary"].WithVwanSecurityConfiguration(true, true, false)
+	conns, err = HubConnections(vnets, map[string]string{"primary": spoke})
+	require.NoError(t, err)
+	assert.True(t, conns[0].EnableInternetSecurity)
+	assert.Equal(t, []string{hub + "/hubRouteTables/noneRouteTable"}, conns[0].PropagatedRouteTableIDs)
+	assert.Equal(t, []string{"none"}, conns[0].PropagatedRouteTableLabels)
+
+	vnets["primary"].WithVwanSecurityConfiguration(false, false, true)
+	conns, err = HubConnections(vnets, map[string]string{"primary": spoke})
+	require.NoError(t, err)
+	assert.True(t, conns[0].RoutingIntent)
+	assert.Empty(t, conns[0].AssociatedRouteTableID)
+	assert.Empty(t, conns[0].PropagatedRouteTableIDs)
+}
+
+func TestVerifyHubConnections(t *testing.T) {
+	srv, id := newArmFake(t)
+	rg := "/subscriptions/" + id.String() + "/resourceGroups/rg"
+	hub := rg + "/providers/Microsoft.Network/virtualHubs/hub"
+	spoke := rg + "/providers/Microsoft.Network/virtualNetworks/spoke"
+	srv.PutResource(rg, map[string]any{"location": "westeurope"})
+	srv.PutResource(hub, map[string]any{})
+	srv.PutResource(spoke, map[string]any{})
+	vnet := inputs.NewVirtualNetwork("spoke", "rg").WithVwanConnection(hub)
+	vnet.VwanPropagatedRoutetablesLabels = []string{"b", "a"}
+	vnets := map[string]*inputs.VirtualNetwork{"primary": vnet}
+	conns, err := HubConnections(vnets, map[string]string{"primary": spoke})
+	require.NoError(t, err)
+	c := conns[0]
+
+	err = VerifyHubConnections(context.Background(), t, conns, fastVerify)
+	assert.ErrorContains(t, err, "ResourceNotFound")
+
+	srv.PutResource(c.ID(), map[string]any{
+		"properties": map[string]any{
+			"enableInternetSecurity": false,
+			"remoteVirtualNetwork":   map[string]any{"id": spoke},
+			"routingConfiguration": map[string]any{
+				"associatedRouteTable": map[string]any{"id": hub + "/hubRouteTables/defaultRouteTable"},
+				"propagatedRouteTables": map[string]any{
+					"ids":    []any{map[string]any{"id": hub + "/hubRouteTables/DEFAULTROUTETABLE"}},
+					"labels": []any{"a", "b"},
+				},
+			},
+		},
+	})
+	require.NoError(t, VerifyHubConnections(context.Background(), t, conns, fastVerify))
+
+	// the configuration does not converge, so it is reported without retrying
+	slow := &VerifyOptions{Retry: setuptest.Retry{Max: 3, Wait: time.Hour}}
+	c.EnableInternetSecurity = true
+	c.PropagatedRouteTableLabels = []string{"default"}
+	err = VerifyHubConnections(context.Background(), t, []ExpectedHubConnection{c}, slow)
+	assert.ErrorContains(t, err, "enableInternetSecurity is false, expected true")
+	assert.ErrorContains(t, err, "propagatedRouteTables.labels is [a b], expected [default]")
+
+	c = conns[0]
+	c.RoutingIntent = true
+	err = VerifyHubConnections(context.Background(), t, []ExpectedHubConnection{c}, slow)
+	assert.ErrorContains(t, err, "routingConfiguration is set, expected none as routing intent is enabled")
+}
@@ -0,0 +1,197 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armlocks"
+	"github.com/google/uuid"
+)
+
+const (
+	// moduleLockPrefix is the prefix of the default name of the resource group locks created by the submodules,
+	// which is lock-<resource group name>, truncated to moduleLockMaxLen.
+	moduleLockPrefix = "lock-"
+	moduleLockMaxLen = 90
+)
+
+// ManagementLock is a management lock, e.g. the CanNotDelete lock the submodules place on resource groups.
+type ManagementLock struct {
+	ID    string // The resource id of the lock.
+	Name  string // The name of the lock.
+	Level string // CanNotDelete or ReadOnly.
+	Scope string // The resource id of the scope the lock applies to, e.g. a resource group.
+}
+
+// String returns the lock name and scope, for logging.
+func (l ManagementLock) String() string {
+	return fmt.Sprintf("%s lock %s on %s", l.Level, l.Name, l.Scope)
+}
+
+// ResourceGroup returns the name of the resource group the lock is in, or empty if it is at subscription scope.
+func (l ManagementLock) ResourceGroup() string {
+	segs := strings.Split(strings.Trim(l.Scope, "/"), "/")
+	if len(segs) >= 4 && strings.EqualFold(segs[2], "resourceGroups") {
+		return segs[3]
+	}
+	return ""
+}
+
+// IsModuleLock returns true if the lock has the default name of the resource group locks created by the submodules,
+// lock-<resource group name>, and is on that resource group.
+func (l ManagementLock) IsModuleLock() bool {
+	rg := l.ResourceGroup()
+	if rg == "" || !isResourceGroupScope(l.Scope) {
+		return false
+	}
+	name := moduleLockPrefix + rg
+	if len(name) > moduleLockMaxLen {
+		name = name[:moduleLockMaxLen]
+	}
+	return strings.EqualFold(l.Name, name)
+}
+
+// ListLocks calls Factory.ListLocks using the default factory, see DefaultFactory.
+func ListLocks(ctx context.Context, subId uuid.UUID, rg string) ([]ManagementLock, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListLocks(ctx, subId, rg)
+}
+
+// ListLocks returns the management locks in the resource group, or in the whole subscription if rg is empty.
+// As in ARM, the locks at a scope include those on all resources beneath it.
+func (f *Factory) ListLocks(ctx context.Context, subId uuid.UUID, rg string) ([]ManagementLock, error) {
+	scope := "/subscriptions/" + subId.String()
+	if rg != "" {
+		scope += "/resourceGroups/" + rg
+	}
+	client, err := f.ManagementLocksClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	var res []*armlocks.ManagementLockObject
+	if rg == "" {
+		pager := client.NewListAtSubscriptionLevelPager(nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("cannot list locks at scope %s: %w", scope, ClassifyError(err))
+			}
+			res = append(res, page.Value...)
+		}
+	} else {
+		pager := client.NewListAtResourceGroupLevelPager(rg, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("cannot list locks at scope %s: %w", scope, ClassifyError(err))
+			}
+			res = append(res, page.Value...)
+		}
+	}
+	locks := make([]ManagementLock, 0, len(res))
+	for _, r := range res {
+		l := ManagementLock{
+			ID:    deref(r.ID),
+			Name:  deref(r.Name),
+			Scope: lockScope(deref(r.ID)),
+		}
+		if r.Properties != nil {
+			l.Level = string(deref(r.Properties.Level))
+		}
+		locks = append(locks, l)
+	}
+	return locks, nil
+}
+
+// DeleteLock calls Factory.DeleteLock using the default factory, see DefaultFactory.
+func DeleteLock(ctx context.Context, id string) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.DeleteLock(ctx, id)
+}
+
+// DeleteLock deletes the management lock with the supplied resource id.
+// The removal of a lock is eventually consistent, so the operations it prevented may still fail for a short time,
+// see RemoveResourceGroups.
+func (f *Factory) DeleteLock(ctx context.Context, id string) error {
+	subId, rg, target, name, err := parseLockID(id)
+	if err != nil {
+		return err
+	}
+	client, err := f.ManagementLocksClient(subId)
+	if err != nil {
+		return err
+	}
+	switch {
+	case rg == "":
+		_, err = client.DeleteAtSubscriptionLevel(ctx, name, nil)
+	case target == nil:
+		_, err = client.DeleteAtResourceGroupLevel(ctx, rg, name, nil)
+	default:
+		_, err = client.DeleteAtResourceLevel(ctx, rg, target.namespace, target.parentPath, target.resourceType, target.name, name, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot delete lock %s: %w", id, ClassifyError(err))
+	}
+	return nil
+}
+
+// lockTarget is the resource a resource level lock applies to, split as the management locks API requires.
+type lockTarget struct {
+	namespace    string // The resource provider namespace, e.g. Microsoft.Network.
+	parentPath   string // The types and names of the parent resources, e.g. virtualNetworks/vnet, or empty.
+	resourceType string // The type of the resource, e.g. subnets.
+	name         string // The name of the resource.
+}
+
+// parseLockID splits the resource id of a lock into its subscription, resource group, target resource and name.
+// The resource group is empty for a lock at subscription scope, and the target is nil for a lock at resource group scope.
+func parseLockID(id string) (subId uuid.UUID, rg string, target *lockTarget, name string, err error) {
+	scope := lockScope(id)
+	segs := strings.Split(strings.Trim(scope, "/"), "/")
+	if scope == "" || len(segs) < 2 || !strings.EqualFold(segs[0], "subscriptions") {
+		return uuid.Nil, "", nil, "", fmt.Errorf("cannot parse lock id %s", id)
+	}
+	if subId, err = uuid.Parse(segs[1]); err != nil {
+		return uuid.Nil, "", nil, "", fmt.Errorf("cannot parse subscription of lock id %s, %w", id, err)
+	}
+	name = id[strings.LastIndex(id, "/")+1:]
+	switch {
+	case len(segs) == 2:
+		return subId, "", nil, name, nil
+	case isResourceGroupScope(scope):
+		return subId, segs[3], nil, name, nil
+	}
+	// .../resourceGroups/<rg>/providers/<namespace>/<type>/<name>[/<type>/<name>]...
+	rest := segs[min(len(segs), 6):]
+	if len(segs) < 8 || !strings.EqualFold(segs[2], "resourceGroups") || !strings.EqualFold(segs[4], "providers") || len(rest)%2 != 0 {
+		return uuid.Nil, "", nil, "", fmt.Errorf("cannot parse resource of lock id %s", id)
+	}
+	return subId, segs[3], &lockTarget{
+		namespace:    segs[5],
+		parentPath:   strings.Join(rest[:len(rest)-2], "/"),
+		resourceType: rest[len(rest)-2],
+		name:         rest[len(rest)-1],
+	}, name, nil
+}
+
+// lockScope returns the resource id of the scope of the lock with the supplied id.
+func lockScope(id string) string {
+	i := strings.LastIndex(strings.ToLower(id), "/providers/microsoft.authorization/locks/")
+	if i < 0 {
+		return ""
+	}
+	return id[:i]
+}
+
+// isResourceGroupScope returns true if the id is that of a resource group.
+func isResourceGroupScope(id string) bool {
+	segs := strings.Split(strings.Trim(id, "/"), "/")
+	return len(segs) == 4 && strings.EqualFold(segs[0], "subscriptions") && strings.EqualFold(segs[2], "resourceGroups")
+}
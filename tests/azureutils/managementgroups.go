@@ -0,0 +1,250 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+)
+
+const (
+	// managementGroupPrefix is the prefix of the resource id of a management group.
+	managementGroupPrefix = "/providers/Microsoft.Management/managementGroups/"
+
+	// testManagementGroupPrefix is the prefix of the names of the management groups created by NewTestManagementGroup,
+	// the same as the subscriptions created by the deploy tests.
+	testManagementGroupPrefix = "testdeploy-"
+)
+
+// ManagementGroupAncestors calls Factory.ManagementGroupAncestors using the default factory, see DefaultFactory.
+func ManagementGroupAncestors(ctx context.Context, id uuid.UUID) ([]string, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ManagementGroupAncestors(ctx, id)
+}
+
+// ManagementGroupAncestors returns the names of the management groups above the subscription,
+// from the tenant root group down to the direct parent of the subscription.
+func (f *Factory) ManagementGroupAncestors(ctx context.Context, id uuid.UUID) ([]string, error) {
+	e, err := f.entity(ctx, id.String())
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.Properties == nil {
+		return nil, fmt.Errorf("subscription %s is not in the management group hierarchy", id)
+	}
+	chain := make([]string, 0, len(e.Properties.ParentNameChain))
+	for _, n := range e.Properties.ParentNameChain {
+		chain = append(chain, deref(n))
+	}
+	return chain, nil
+}
+
+// entity returns the management group or subscription with the name from the entities API, or nil if there is none.
+// The cache is bypassed, as the hierarchy is read straight after it is changed.
+func (f *Factory) entity(ctx context.Context, name string) (*armmanagementgroups.EntityInfo, error) {
+	client, err := f.EntitiesClient()
+	if err != nil {
+		return nil, err
+	}
+	filter := fmt.Sprintf("name eq '%s'", name)
+	cc := "no-cache"
+	pager := client.NewListPager(&armmanagementgroups.EntitiesClientListOptions{
+		Filter:       &filter,
+		CacheControl: &cc,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get management group entity %s: %w", name, ClassifyError(err))
+		}
+		for _, e := range page.Value {
+			if strings.EqualFold(deref(e.Name), name) {
+				return e, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// IsSubscriptionBeneathManagementGroup calls Factory.IsSubscriptionBeneathManagementGroup using the default factory, see DefaultFactory.
+func IsSubscriptionBeneathManagementGroup(ctx context.Context, t TestingT, id uuid.UUID, mg string) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.IsSubscriptionBeneathManagementGroup(ctx, t, id, mg)
+}
+
+// IsSubscriptionBeneathManagementGroup returns an error unless the management group is the direct parent of the subscription
+// or any of its ancestors, see ManagementGroupAncestors.
+// It retries a few times, as the management group hierarchy is eventually consistent.
+func (f *Factory) IsSubscriptionBeneathManagementGroup(ctx context.Context, t TestingT, id uuid.UUID, mg string) error {
+	var chain []string
+	err := doWithRetry(ctx, t, "is subscription beneath management group", setuptest.FastRetry, func() error {
+		var err error
+		if chain, err = f.ManagementGroupAncestors(ctx, id); err != nil {
+			return err
+		}
+		for _, a := range chain {
+			if strings.EqualFold(a, mg) {
+				return nil
+			}
+		}
+		return fmt.Errorf("management group %s is not in the ancestors %s", mg, strings.Join(chain, " > "))
+	})
+	if err != nil {
+		return fmt.Errorf("subscription %s is not beneath management group %s, ancestors are %s: %w", id, mg, strings.Join(chain, " > "), err)
+	}
+	return nil
+}
+
+// CreateManagementGroup calls Factory.CreateManagementGroup using the default factory, see DefaultFactory.
+func CreateManagementGroup(ctx context.Context, name, parent string) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.CreateManagementGroup(ctx, name, parent)
+}
+
+// CreateManagementGroup creates the management group as a child of the parent management group,
+// or of the tenant root group if the parent is empty, and waits up to 30 minutes for it to be created.
+func (f *Factory) CreateManagementGroup(ctx context.Context, name, parent string) error {
+	client, err := f.ManagementGroupsClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, longRunningTimeout)
+	defer cancel()
+	props := &armmanagementgroups.CreateManagementGroupProperties{
+		DisplayName: &name,
+	}
+	if parent != "" {
+		parentID := managementGroupPrefix + parent
+		props.Details = &armmanagementgroups.CreateManagementGroupDetails{
+			Parent: &armmanagementgroups.CreateParentGroupInfo{ID: &parentID},
+		}
+	}
+	cc := "no-cache"
+	poller, err := client.BeginCreateOrUpdate(ctx, name, armmanagementgroups.CreateManagementGroupRequest{
+		Name:       &name,
+		Properties: props,
+	}, &armmanagementgroups.ClientBeginCreateOrUpdateOptions{CacheControl: &cc})
+	if err != nil {
+		return fmt.Errorf("cannot create management group %s: %w", name, ClassifyError(err))
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("cannot create management group %s: %w", name, ClassifyError(err))
+	}
+	return nil
+}
+
+// DeleteManagementGroup calls Factory.DeleteManagementGroup using the default factory, see DefaultFactory.
+func DeleteManagementGroup(ctx context.Context, name string) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.DeleteManagementGroup(ctx, name)
+}
+
+// DeleteManagementGroup deletes the management group and waits up to 30 minutes for it to be deleted.
+// It fails if the management group has children.
+func (f *Factory) DeleteManagementGroup(ctx context.Context, name string) error {
+	client, err := f.ManagementGroupsClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, longRunningTimeout)
+	defer cancel()
+	cc := "no-cache"
+	poller, err := client.BeginDelete(ctx, name, &armmanagementgroups.ClientBeginDeleteOptions{CacheControl: &cc})
+	if err != nil {
+		return fmt.Errorf("cannot delete management group %s: %w", name, ClassifyError(err))
+	}
+	if _, err := poller.PollUntilDone(ctx, nil); err != nil {
+		return fmt.Errorf("cannot delete management group %s: %w", name, ClassifyError(err))
+	}
+	return nil
+}
+
+// NewTestManagementGroup calls Factory.NewTestManagementGroup using the default factory, see DefaultFactory.
+func NewTestManagementGroup(ctx context.Context, t TestingT, parent string) (string, func() error, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return "", nil, err
+	}
+	return f.NewTestManagementGroup(ctx, t, parent)
+}
+
+// NewTestManagementGroup creates a management group with a unique name beneath the parent, or the tenant root group if empty,
+// for the use of a single test, so that concurrent test runs do not share a management group.
+// It returns the name of the management group and a function that deletes it, which should be deferred.
+// Before deleting the management group, the function moves any subscriptions left in it to the parent.
+// It runs with a context from CleanupContext, so the management group is still deleted if ctx is done.
+func (f *Factory) NewTestManagementGroup(ctx context.Context, t TestingT, parent string) (string, func() error, error) {
+	name := testManagementGroupPrefix + strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+	if err := f.CreateManagementGroup(ctx, name, parent); err != nil {
+		return "", nil, err
+	}
+	t.Logf("created management group %s", name)
+	cleanup := func() error {
+		t.Logf("deleting management group %s", name)
+		ctx, cancel := CleanupContext(ctx)
+		defer cancel()
+		if err := f.moveSubscriptionsToParent(ctx, name); err != nil {
+			return err
+		}
+		return f.DeleteManagementGroup(ctx, name)
+	}
+	return name, cleanup, nil
+}
+
+// moveSubscriptionsToParent moves the subscriptions that are direct children of the management group to its parent.
+func (f *Factory) moveSubscriptionsToParent(ctx context.Context, name string) error {
+	mg, err := f.entity(ctx, name)
+	if err != nil {
+		return err
+	}
+	if mg == nil || mg.Properties == nil || mg.Properties.Parent == nil {
+		return fmt.Errorf("cannot find the parent of management group %s", name)
+	}
+	parentID := deref(mg.Properties.Parent.ID)
+	parent := parentID[strings.LastIndex(parentID, "/")+1:]
+
+	client, err := f.EntitiesClient()
+	if err != nil {
+		return err
+	}
+	cc := "no-cache"
+	pager := client.NewListPager(&armmanagementgroups.EntitiesClientListOptions{CacheControl: &cc})
+	var errs []error
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot list management group entities: %w", ClassifyError(err))
+		}
+		for _, e := range page.Value {
+			if deref(e.Type) != "/subscriptions" || e.Properties == nil || e.Properties.Parent == nil ||
+				!strings.EqualFold(deref(e.Properties.Parent.ID), managementGroupPrefix+name) {
+				continue
+			}
+			id, err := uuid.Parse(deref(e.Name))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cannot parse subscription id %s: %w", deref(e.Name), err))
+				continue
+			}
+			if err := f.SetSubscriptionManagementGroup(ctx, id, parent); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
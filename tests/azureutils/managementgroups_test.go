@@ -0,0 +1,57 @@
+package azureutils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagementGroupHierarchy(t *testing.T) {
+	srv, id := newArmFake(t)
+	srv.PageSize = 1
+	ctx := context.Background()
+
+	chain, err := ManagementGroupAncestors(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{armfake.DefaultTenantID}, chain)
+
+	require.NoError(t, CreateManagementGroup(ctx, "platform", ""))
+	require.NoError(t, CreateManagementGroup(ctx, "landingzones", "platform"))
+	require.NoError(t, SetSubscriptionManagementGroup(context.Background(), id, "landingzones"))
+
+	chain, err = ManagementGroupAncestors(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{armfake.DefaultTenantID, "platform", "landingzones"}, chain)
+	assert.NoError(t, IsSubscriptionBeneathManagementGroup(context.Background(), t, id, "platform"))
+	assert.NoError(t, IsSubscriptionBeneathManagementGroup(context.Background(), t, id, "LandingZones"))
+
+	require.NoError(t, CreateManagementGroup(ctx, "sandbox", ""))
+	assert.NoError(t, IsSubscriptionInManagementGroup(context.Background(), t, id, "landingzones"))
+
+	// a management group with children cannot be deleted
+	err = DeleteManagementGroup(ctx, "platform")
+	assert.ErrorContains(t, err, "it has children: landingzones")
+	require.NoError(t, DeleteManagementGroup(ctx, "sandbox"))
+}
+
+func TestNewTestManagementGroup(t *testing.T) {
+	srv, id := newArmFake(t)
+	ctx := context.Background()
+	require.NoError(t, CreateManagementGroup(ctx, "platform", ""))
+
+	name, cleanup, err := NewTestManagementGroup(context.Background(), t, "platform")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, testManagementGroupPrefix))
+	require.NoError(t, SetSubscriptionManagementGroup(context.Background(), id, name))
+	require.NoError(t, IsSubscriptionBeneathManagementGroup(context.Background(), t, id, "platform"))
+
+	// the subscription is moved to the parent, so that the management group can be deleted
+	require.NoError(t, cleanup())
+	assert.Equal(t, "platform", srv.ManagementGroupOf(id.String()))
+	_, ok := srv.Resource("/providers/Microsoft.Management/managementGroups/" + name)
+	assert.False(t, ok)
+}
@@ -2,12 +2,16 @@ package azureutils
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 
@@ -15,6 +19,10 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 )
 
+// assertionExpiryMargin is how long before its expiry a cached assertion is refreshed,
+// so that it is still valid when exchanged for an access token.
+const assertionExpiryMargin = time.Minute
+
 // OidcCredential contains the fields needed to authenticate to Azure using an OIDC token
 type OidcCredential struct {
 	requestToken  string
@@ -22,6 +30,17 @@ type OidcCredential struct {
 	token         string
 	tokenFilePath string
 	cred          *azidentity.ClientAssertionCredential
+
+	// transport sends the request for an assertion to the request URL.
+	transport policy.Transporter
+
+	// now returns the current time, it is overridden in tests.
+	now func() time.Time
+
+	// mu protects the cached assertion and its expiry.
+	mu        sync.Mutex
+	assertion string
+	expiresOn time.Time
 }
 
 // OidcCredentialOptions contains the fields needed to create an OidcCredential
@@ -35,13 +54,22 @@ type OidcCredentialOptions struct {
 	TokenFilePath string
 }
 
-// NewOidcCredential creates a new OidcCredential
+// NewOidcCredential creates a new OidcCredential.
+// The assertion is read from the token, the token file or the request URL, in that order of precedence.
+// Assertions from the token file or request URL are cached until shortly before they expire,
+// so a rotated token file is re-read and a new assertion requested only when needed.
+// If the Transport of the client options is set, it is also used for requests to the request URL.
 func NewOidcCredential(options *OidcCredentialOptions) (*OidcCredential, error) {
 	w := &OidcCredential{
 		requestToken:  options.RequestToken,
 		requestUrl:    options.RequestUrl,
 		token:         options.Token,
 		tokenFilePath: options.TokenFilePath,
+		transport:     options.Transport,
+		now:           time.Now,
+	}
+	if w.transport == nil {
+		w.transport = http.DefaultClient
 	}
 
 	cred, err := azidentity.NewClientAssertionCredential(options.TenantID, options.ClientID, w.getAssertion, &azidentity.ClientAssertionCredentialOptions{ClientOptions: options.ClientOptions})
@@ -58,20 +86,67 @@ func (w *OidcCredential) GetToken(ctx context.Context, opts policy.TokenRequestO
 	return w.cred.GetToken(ctx, opts)
 }
 
+// getAssertion returns a JWT assertion that has not expired, returning the cached assertion
+// if it is not about to expire.
+// It is called by the azidentity.ClientAssertionCredential whenever it needs a new access token.
 func (w *OidcCredential) getAssertion(ctx context.Context) (string, error) {
 	if w.token != "" {
-		return w.token, nil
+		return w.checkAssertion(w.token, "ARM_OIDC_TOKEN")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Assertions without an expiry are not cached, as there is no way to tell when they have been rotated.
+	if w.assertion != "" && !w.expiresOn.IsZero() && w.now().Add(assertionExpiryMargin).Before(w.expiresOn) {
+		return w.assertion, nil
 	}
 
+	var (
+		assertion, source string
+		err               error
+	)
 	if w.tokenFilePath != "" {
-		idTokenData, err := os.ReadFile(w.tokenFilePath)
-		if err != nil {
-			return "", fmt.Errorf("reading token file: %v", err)
-		}
+		source = "token file " + w.tokenFilePath
+		assertion, err = w.readAssertionFile()
+	} else {
+		source = "request URL"
+		assertion, err = w.requestAssertion(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.checkAssertion(assertion, source); err != nil {
+		return "", err
+	}
+	w.assertion = assertion
+	w.expiresOn, _ = assertionExpiry(assertion)
+	return assertion, nil
+}
+
+// checkAssertion returns the assertion, or an error if it has expired.
+// Assertions without a readable exp claim are assumed to be valid.
+func (w *OidcCredential) checkAssertion(assertion, source string) (string, error) {
+	exp, err := assertionExpiry(assertion)
+	if err != nil || exp.IsZero() {
+		return assertion, nil
+	}
+	if !w.now().Before(exp) {
+		return "", fmt.Errorf("getAssertion: OIDC assertion from %s expired at %s, the identity token must be refreshed before it expires", source, exp.UTC().Format(time.RFC3339))
+	}
+	return assertion, nil
+}
 
-		return string(idTokenData), nil
+// readAssertionFile reads the assertion from the token file.
+func (w *OidcCredential) readAssertionFile() (string, error) {
+	idTokenData, err := os.ReadFile(w.tokenFilePath)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
 	}
+	return strings.TrimSpace(string(idTokenData)), nil
+}
 
+// requestAssertion requests a new assertion from the request URL, e.g. the GitHub Actions OIDC provider.
+func (w *OidcCredential) requestAssertion(ctx context.Context) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.requestUrl, http.NoBody)
 	if err != nil {
 		return "", fmt.Errorf("getAssertion: failed to build request")
@@ -91,15 +166,15 @@ func (w *OidcCredential) getAssertion(ctx context.Context) (string, error) {
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", w.requestToken))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := w.transport.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("getAssertion: cannot request token: %v", err)
+		return "", fmt.Errorf("getAssertion: cannot request token: %w", err)
 	}
 
 	defer resp.Body.Close()
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if err != nil {
-		return "", fmt.Errorf("getAssertion: cannot parse response: %v", err)
+		return "", fmt.Errorf("getAssertion: cannot parse response: %w", err)
 	}
 
 	if c := resp.StatusCode; c < 200 || c > 299 {
@@ -111,7 +186,7 @@ func (w *OidcCredential) getAssertion(ctx context.Context) (string, error) {
 		Value *string `json:"value"`
 	}
 	if err := json.Unmarshal(body, &tokenRes); err != nil {
-		return "", fmt.Errorf("getAssertion: cannot unmarshal response: %v", err)
+		return "", fmt.Errorf("getAssertion: cannot unmarshal response: %w", err)
 	}
 
 	if tokenRes.Value == nil {
@@ -120,3 +195,30 @@ func (w *OidcCredential) getAssertion(ctx context.Context) (string, error) {
 
 	return *tokenRes.Value, nil
 }
+
+// assertionExpiry returns the time of the exp claim of the JWT assertion,
+// or the zero time if it does not have one.
+func assertionExpiry(assertion string) (time.Time, error) {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("assertion is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot decode JWT payload: %w", err)
+	}
+	var claims struct {
+		Exp *json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse JWT claims: %w", err)
+	}
+	if claims.Exp == nil {
+		return time.Time{}, nil
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("cannot parse JWT exp claim: %w", err)
+	}
+	return time.Unix(int64(exp), 0), nil
+}
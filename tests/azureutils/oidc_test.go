@@ -0,0 +1,184 @@
+package azureutils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testJWT returns an unsigned JWT with the supplied exp claim.
+func testJWT(t *testing.T, exp time.Time) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	return enc(map[string]any{"alg": "none", "typ": "JWT"}) + "." + enc(map[string]any{"sub": "repo:test", "exp": exp.Unix()}) + ".sig"
+}
+
+// newTokenEndpoint returns an OIDC token endpoint, such as that of GitHub Actions,
+// that returns the assertion produced by next, and a counter of the requests made.
+func newTokenEndpoint(t *testing.T, next func() string) (*httptest.Server, *atomic.Int32) {
+	count := new(atomic.Int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		if r.Header.Get("Authorization") != "Bearer request-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("audience") != "api://AzureADTokenExchange" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"count": 1, "value": next()})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, count
+}
+
+func newTestOidcCredential(t *testing.T, opts *OidcCredentialOptions, now *time.Time) *OidcCredential {
+	opts.TenantID = "00000000-0000-0000-0000-000000000000"
+	opts.ClientID = "00000000-0000-0000-0000-000000000001"
+	cred, err := NewOidcCredential(opts)
+	require.NoError(t, err)
+	cred.now = func() time.Time { return *now }
+	return cred
+}
+
+func TestOidcCredentialRequestUrlCachesAssertion(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	var issued atomic.Int32
+	srv, count := newTokenEndpoint(t, func() string {
+		issued.Add(1)
+		return testJWT(t, now.Add(10*time.Minute))
+	})
+	cred := newTestOidcCredential(t, &OidcCredentialOptions{
+		RequestToken: "request-token",
+		RequestUrl:   srv.URL + "/token?api-version=2.0",
+	}, &now)
+
+	first, err := cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	second, err := cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), count.Load(), "assertion should be cached while it is valid")
+
+	// Within the expiry margin a new assertion is requested.
+	now = now.Add(9*time.Minute + 30*time.Second)
+	third, err := cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, first, third)
+	assert.Equal(t, int32(2), count.Load())
+}
+
+func TestOidcCredentialRequestUrlExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	srv, _ := newTokenEndpoint(t, func() string { return testJWT(t, now.Add(-time.Second)) })
+	cred := newTestOidcCredential(t, &OidcCredentialOptions{
+		RequestToken: "request-token",
+		RequestUrl:   srv.URL,
+	}, &now)
+
+	_, err := cred.getAssertion(context.Background())
+	assert.ErrorContains(t, err, "OIDC assertion from request URL expired at")
+}
+
+func TestOidcCredentialRequestUrlError(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	srv, _ := newTokenEndpoint(t, func() string { return testJWT(t, now.Add(time.Hour)) })
+	cred := newTestOidcCredential(t, &OidcCredentialOptions{
+		RequestToken: "wrong-token",
+		RequestUrl:   srv.URL,
+	}, &now)
+
+	_, err := cred.getAssertion(context.Background())
+	assert.ErrorContains(t, err, "received HTTP status 401")
+}
+
+func TestOidcCredentialTokenFileRotation(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	fn := filepath.Join(t.TempDir(), "token")
+	first := testJWT(t, now.Add(5*time.Minute))
+	require.NoError(t, os.WriteFile(fn, []byte(first+"\n"), 0600))
+	cred := newTestOidcCredential(t, &OidcCredentialOptions{TokenFilePath: fn}, &now)
+
+	got, err := cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first, got)
+
+	// The file is rotated, but the cached assertion is still valid.
+	rotated := testJWT(t, now.Add(15*time.Minute))
+	require.NoError(t, os.WriteFile(fn, []byte(rotated), 0600))
+	got, err = cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, first, got)
+
+	// Near expiry the file is re-read.
+	now = now.Add(4*time.Minute + 30*time.Second)
+	got, err = cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, rotated, got)
+
+	// The file is not rotated again, so the assertion expires.
+	now = now.Add(11 * time.Minute)
+	_, err = cred.getAssertion(context.Background())
+	assert.ErrorContains(t, err, fmt.Sprintf("OIDC assertion from token file %s expired at", fn))
+}
+
+func TestOidcCredentialToken(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	token := testJWT(t, now.Add(time.Minute))
+	cred := newTestOidcCredential(t, &OidcCredentialOptions{Token: token}, &now)
+	got, err := cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, token, got)
+
+	now = now.Add(2 * time.Minute)
+	_, err = cred.getAssertion(context.Background())
+	assert.ErrorContains(t, err, "OIDC assertion from ARM_OIDC_TOKEN expired at")
+
+	// Tokens that are not JWTs, or have no exp claim, are passed through unchanged.
+	cred = newTestOidcCredential(t, &OidcCredentialOptions{Token: "opaque"}, &now)
+	got, err = cred.getAssertion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "opaque", got)
+}
+
+func TestAssertionExpiry(t *testing.T) {
+	t.Parallel()
+
+	exp := time.Unix(1700000000, 0)
+	got, err := assertionExpiry(testJWT(t, exp))
+	require.NoError(t, err)
+	assert.True(t, exp.Equal(got))
+
+	_, err = assertionExpiry("not-a-jwt")
+	assert.Error(t, err)
+
+	got, err = assertionExpiry("e30.e30.sig")
+	require.NoError(t, err)
+	assert.True(t, got.IsZero())
+}
@@ -0,0 +1,296 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/google/uuid"
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+const (
+	// hub peering directions, see the hub_peering_direction input of the virtualnetwork submodule.
+	peeringDirectionBoth    = "both"
+	peeringDirectionToHub   = "tohub"
+	peeringDirectionFromHub = "fromhub"
+)
+
+// ExpectedPeering is the state of a virtual network peering expected from the input variables.
+type ExpectedPeering struct {
+	VirtualNetworkID          string // The resource id of the virtual network the peering belongs to.
+	Name                      string // The name of the peering.
+	RemoteVirtualNetworkID    string // The resource id of the peered virtual network.
+	AllowVirtualNetworkAccess bool
+	AllowForwardedTraffic     bool
+	AllowGatewayTransit       bool
+	UseRemoteGateways         bool
+
+	// Bidirectional is true if the remote virtual network is expected to have a peering back,
+	// in which case both sides must be Connected and FullyInSync. Otherwise the peering is Initiated.
+	Bidirectional bool
+}
+
+// ID returns the resource id of the peering.
+func (p ExpectedPeering) ID() string {
+	return p.VirtualNetworkID + "/virtualNetworkPeerings/" + p.Name
+}
+
+// expectedState returns the peering state reported by Azure once the peering has converged.
+func (p ExpectedPeering) expectedState() armnetwork.VirtualNetworkPeeringState {
+	if p.Bidirectional {
+		return armnetwork.VirtualNetworkPeeringStateConnected
+	}
+	return armnetwork.VirtualNetworkPeeringStateInitiated
+}
+
+// HubPeerings returns the hub peerings expected from the virtual networks of the inputs, see inputs.Inputs.VirtualNetworks,
+// using the resource ids of the deployed virtual networks, e.g. from the virtual_network_resource_ids output.
+// The names of the peerings are calculated as the submodule does when they are not supplied.
+func HubPeerings(vnets map[string]*inputs.VirtualNetwork, vnetIDs map[string]string) ([]ExpectedPeering, error) {
+	peerings := make([]ExpectedPeering, 0)
+	for k, v := range vnets {
+		if !deref(v.HubPeeringEnabled) {
+			continue
+		}
+		id, ok := vnetIDs[k]
+		if !ok {
+			return nil, fmt.Errorf("no resource id for virtual network %s", k)
+		}
+		hub := deref(v.HubNetworkResourceID)
+		if hub == "" {
+			return nil, fmt.Errorf("virtual network %s has hub peering enabled but no hub_network_resource_id", k)
+		}
+		direction := strings.ToLower(coalesce(deref(v.HubPeeringDirection), peeringDirectionBoth))
+		if direction != peeringDirectionToHub && direction != peeringDirectionFromHub {
+			direction = peeringDirectionBoth
+		}
+		if direction != peeringDirectionFromHub {
+			peerings = append(peerings, ExpectedPeering{
+				VirtualNetworkID:          id,
+				Name:                      coalesce(deref(v.HubPeeringNameToHub), peeringName(hub)),
+				RemoteVirtualNetworkID:    hub,
+				AllowVirtualNetworkAccess: true,
+				AllowForwardedTraffic:     true,
+				AllowGatewayTransit:       false,
+				UseRemoteGateways:         valueOr(v.HubPeeringUseRemoteGateways, true),
+				Bidirectional:             direction == peeringDirectionBoth,
+			})
+		}
+		if direction != peeringDirectionToHub {
+			peerings = append(peerings, ExpectedPeering{
+				VirtualNetworkID:          hub,
+				Name:                      coalesce(deref(v.HubPeeringNameFromHub), peeringName(id)),
+				RemoteVirtualNetworkID:    id,
+				AllowVirtualNetworkAccess: true,
+				AllowForwardedTraffic:     true,
+				AllowGatewayTransit:       true,
+				UseRemoteGateways:         false,
+				Bidirectional:             direction == peeringDirectionBoth,
+			})
+		}
+	}
+	sortPeerings(peerings)
+	return peerings, nil
+}
+
+// MeshPeerings returns the mesh peerings expected from the virtual networks of the inputs, see inputs.Inputs.VirtualNetworks,
+// using the resource ids of the deployed virtual networks, e.g. from the virtual_network_resource_ids output.
+func MeshPeerings(vnets map[string]*inputs.VirtualNetwork, vnetIDs map[string]string) ([]ExpectedPeering, error) {
+	peerings := make([]ExpectedPeering, 0)
+	for src, v := range vnets {
+		if !deref(v.MeshPeeringEnabled) {
+			continue
+		}
+		for dst, dv := range vnets {
+			if dst == src || !deref(dv.MeshPeeringEnabled) {
+				continue
+			}
+			srcID, ok := vnetIDs[src]
+			if !ok {
+				return nil, fmt.Errorf("no resource id for virtual network %s", src)
+			}
+			dstID, ok := vnetIDs[dst]
+			if !ok {
+				return nil, fmt.Errorf("no resource id for virtual network %s", dst)
+			}
+			peerings = append(peerings, ExpectedPeering{
+				VirtualNetworkID:          srcID,
+				Name:                      peeringName(dstID),
+				RemoteVirtualNetworkID:    dstID,
+				AllowVirtualNetworkAccess: true,
+				AllowForwardedTraffic:     deref(v.MeshPeeringAllowForwardedTraffic),
+				AllowGatewayTransit:       false,
+				UseRemoteGateways:         false,
+				Bidirectional:             true,
+			})
+		}
+	}
+	sortPeerings(peerings)
+	return peerings, nil
+}
+
+// peeringName returns the default name of a peering to the remote virtual network,
+// the same as peer-${uuidv5("url", id)} in the submodule.
+func peeringName(remoteID string) string {
+	return "peer-" + uuid.NewSHA1(uuid.NameSpaceURL, []byte(remoteID)).String()
+}
+
+// sortPeerings sorts the peerings by id, so that the results and errors are deterministic.
+func sortPeerings(peerings []ExpectedPeering) {
+	sort.Slice(peerings, func(i, j int) bool {
+		return peerings[i].ID() < peerings[j].ID()
+	})
+}
+
+// VerifyPeerings calls Factory.VerifyPeerings using the default factory, see DefaultFactory.
+func VerifyPeerings(ctx context.Context, t TestingT, peerings []ExpectedPeering, opts *VerifyOptions) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.VerifyPeerings(ctx, t, peerings, opts)
+}
+
+// VerifyPeerings checks that Azure reports each peering with the expected remote virtual network and flags,
+// and that it has converged: Connected and FullyInSync on both sides if bidirectional, otherwise Initiated.
+// As the peering state converges asynchronously, each peering is polled until it has converged or the retries are exhausted.
+// The errors of all the peerings are returned together.
+func (f *Factory) VerifyPeerings(ctx context.Context, t TestingT, peerings []ExpectedPeering, opts *VerifyOptions) error {
+	var errs []error
+	for _, p := range peerings {
+		p := p
+		err := poll(ctx, t, "verify peering "+p.ID(), opts, func(ctx context.Context) error {
+			return f.verifyPeering(ctx, p)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("peering %s: %w", p.ID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// verifyPeering checks the peering once.
+// Differences that do not converge, e.g. the flags, are returned as a retry.FatalError so they are not polled.
+func (f *Factory) verifyPeering(ctx context.Context, p ExpectedPeering) error {
+	peering, err := f.GetPeering(ctx, p.ID())
+	if err != nil {
+		return err
+	}
+	props := peering.Properties
+	if props == nil {
+		return fmt.Errorf("peering has no properties")
+	}
+
+	var diffs []string
+	diff := func(name string, got, want any) {
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			diffs = append(diffs, fmt.Sprintf("%s is %v, expected %v", name, got, want))
+		}
+	}
+	remote := ""
+	if props.RemoteVirtualNetwork != nil && props.RemoteVirtualNetwork.ID != nil {
+		remote = *props.RemoteVirtualNetwork.ID
+	}
+	if !strings.EqualFold(remote, p.RemoteVirtualNetworkID) {
+		diff("remoteVirtualNetwork", remote, p.RemoteVirtualNetworkID)
+	}
+	diff("allowVirtualNetworkAccess", deref(props.AllowVirtualNetworkAccess), p.AllowVirtualNetworkAccess)
+	diff("allowForwardedTraffic", deref(props.AllowForwardedTraffic), p.AllowForwardedTraffic)
+	diff("allowGatewayTransit", deref(props.AllowGatewayTransit), p.AllowGatewayTransit)
+	diff("useRemoteGateways", deref(props.UseRemoteGateways), p.UseRemoteGateways)
+	if len(diffs) > 0 {
+		return retry.FatalError{Underlying: errors.New(strings.Join(diffs, ", "))}
+	}
+
+	if state := deref(props.PeeringState); state != p.expectedState() {
+		return fmt.Errorf("peeringState is %s, expected %s", state, p.expectedState())
+	}
+	if !p.Bidirectional {
+		return nil
+	}
+	if sync := deref(props.PeeringSyncLevel); sync != armnetwork.VirtualNetworkPeeringLevelFullyInSync {
+		return fmt.Errorf("peeringSyncLevel is %s, expected %s", sync, armnetwork.VirtualNetworkPeeringLevelFullyInSync)
+	}
+
+	// check the remote side, which may be in another subscription and not created by the module
+	back, err := f.findPeering(ctx, p.RemoteVirtualNetworkID, p.VirtualNetworkID)
+	if err != nil {
+		return err
+	}
+	if back == nil {
+		return fmt.Errorf("remote virtual network %s has no peering back", p.RemoteVirtualNetworkID)
+	}
+	if state := deref(back.Properties.PeeringState); state != armnetwork.VirtualNetworkPeeringStateConnected {
+		return fmt.Errorf("remote peering %s peeringState is %s, expected %s", *back.ID, state, armnetwork.VirtualNetworkPeeringStateConnected)
+	}
+	if sync := deref(back.Properties.PeeringSyncLevel); sync != armnetwork.VirtualNetworkPeeringLevelFullyInSync {
+		return fmt.Errorf("remote peering %s peeringSyncLevel is %s, expected %s", *back.ID, sync, armnetwork.VirtualNetworkPeeringLevelFullyInSync)
+	}
+	return nil
+}
+
+// GetPeering calls Factory.GetPeering using the default factory, see DefaultFactory.
+func GetPeering(ctx context.Context, id string) (*armnetwork.VirtualNetworkPeering, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetPeering(ctx, id)
+}
+
+// GetPeering returns the virtual network peering with the supplied resource id.
+func (f *Factory) GetPeering(ctx context.Context, id string) (*armnetwork.VirtualNetworkPeering, error) {
+	rid, err := arm.ParseResourceID(id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse peering id %s: %w", id, err)
+	}
+	subId, err := uuid.Parse(rid.SubscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse subscription id of peering %s: %w", id, err)
+	}
+	client, err := f.VirtualNetworkPeeringsClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(ctx, rid.ResourceGroupName, rid.Parent.Name, rid.Name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get peering: %w", ClassifyError(err))
+	}
+	return &resp.VirtualNetworkPeering, nil
+}
+
+// findPeering returns the peering of the virtual network to the remote virtual network, or nil if there is none.
+func (f *Factory) findPeering(ctx context.Context, vnetID, remoteID string) (*armnetwork.VirtualNetworkPeering, error) {
+	rid, err := arm.ParseResourceID(vnetID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse virtual network id %s: %w", vnetID, err)
+	}
+	subId, err := uuid.Parse(rid.SubscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse subscription id of virtual network %s: %w", vnetID, err)
+	}
+	client, err := f.VirtualNetworkPeeringsClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	pager := client.NewListPager(rid.ResourceGroupName, rid.Name, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list peerings of %s: %w", vnetID, ClassifyError(err))
+		}
+		for _, p := range page.Value {
+			if p.Properties != nil && p.Properties.RemoteVirtualNetwork != nil &&
+				strings.EqualFold(deref(p.Properties.RemoteVirtualNetwork.ID), remoteID) {
+				return p, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,102 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastVerify = &VerifyOptions{Retry: setuptest.Retry{Max: 2, Wait: time.Millisecond}}
+
+// putPeerings creates the peerings in the fake as the virtualnetwork submodule would.
+func putPeerings(srv *armfake.Server, peerings []ExpectedPeering) {
+	for _, p := range peerings {
+		srv.PutResource(p.ID(), map[string]any{
+			"properties": map[string]any{
+				"remoteVirtualNetwork":      map[string]any{"id": p.RemoteVirtualNetworkID},
+				"allowVirtualNetworkAccess": p.AllowVirtualNetworkAccess,
+				"allowForwardedTraffic":     p.AllowForwardedTraffic,
+				"allowGatewayTransit":       p.AllowGatewayTransit,
+				"useRemoteGateways":         p.UseRemoteGateways,
+			},
+		})
+	}
+}
+
+func TestHubPeerings(t *testing.T) {
+	hub := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/hub/providers/Microsoft.Network/virtualNetworks/hub"
+	spoke := "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/spoke/providers/Microsoft.Network/virtualNetworks/spoke"
+	vnets := map[string]*inputs.VirtualNetwork{
+		"primary":   inputs.NewVirtualNetwork("spoke", "spoke").WithHubPeering(hub).WithHubPeeringDirection("ToHub"),
+		"secondary": inputs.NewVirtualNetwork("spoke2", "spoke"),
+	}
+
+	peerings, err := HubPeerings(vnets, map[string]string{"primary": spoke})
+	require.NoError(t, err)
+	require.Len(t, peerings, 1)
+	assert.Equal(t, ExpectedPeering{
+		VirtualNetworkID:          spoke,
+		Name:                      "peer-868627cb-21fa-5970-9f1c-e657ed059a99",
+		RemoteVirtualNetworkID:    hub,
+		AllowVirtualNetworkAccess: true,
+		AllowForwardedTraffic:     true,
+		UseRemoteGateways:         true,
+	}, peerings[0])
+
+	vnets["primary"].WithHubPeeringDirection("both").WithHubPeeringNames("", "fromhub")
+	peerings, err = HubPeerings(vnets, map[string]string{"primary": spoke})
+	require.NoError(t, err)
+	require.Len(t, peerings, 2)
+	assert.Equal(t, hub+"/virtualNetworkPeerings/fromhub", peerings[0].ID())
+	assert.True(t, peerings[0].AllowGatewayTransit)
+	assert.True(t, peerings[0].Bidirectional)
+
+	vnets["primary"].HubNetworkResourceID = nil
+	_, err = HubPeerings(vnets, map[string]string{"primary": spoke})
+	assert.ErrorContains(t, err, "no hub_network_resource_id")
+}
+
+func TestVerifyPeerings(t *testing.T) {
+	srv, id := newArmFake(t)
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg", map[string]any{"location": "westeurope"})
+	rg := "/subscriptions/" + id.String() + "/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/"
+	ids := map[string]string{"primary": rg + "primary", "secondary": rg + "secondary", "third": rg + "third"}
+	for _, v := range ids {
+		srv.PutResource(v, map[string]any{})
+	}
+	vnets := map[string]*inputs.VirtualNetwork{
+		"primary":   inputs.NewVirtualNetwork("primary", "rg").WithMeshPeering(true),
+		"secondary": inputs.NewVirtualNetwork("secondary", "rg").WithMeshPeering(false),
+		"third":     inputs.NewVirtualNetwork("third", "rg").WithHubPeering(ids["primary"]).WithHubPeeringDirection("tohub"),
+	}
+
+	mesh, err := MeshPeerings(vnets, ids)
+	require.NoError(t, err)
+	require.Len(t, mesh, 2)
+	hub, err := HubPeerings(vnets, ids)
+	require.NoError(t, err)
+	require.Len(t, hub, 1)
+
+	// only one side of the mesh, so it is not connected
+	putPeerings(srv, mesh[:1])
+	err = VerifyPeerings(context.Background(), t, mesh[:1], fastVerify)
+	assert.ErrorContains(t, err, "peeringState is Initiated, expected Connected")
+
+	putPeerings(srv, mesh[1:])
+	putPeerings(srv, hub)
+	require.NoError(t, VerifyPeerings(context.Background(), t, append(mesh, hub...), fastVerify))
+
+	// the flags do not converge, so they are reported without retrying
+	wrong := mesh[0]
+	wrong.AllowForwardedTraffic = !wrong.AllowForwardedTraffic
+	wrong.UseRemoteGateways = true
+	err = VerifyPeerings(context.Background(), t, []ExpectedPeering{wrong}, &VerifyOptions{Retry: setuptest.Retry{Max: 3, Wait: time.Hour}})
+	assert.ErrorContains(t, err, "allowForwardedTraffic is")
+	assert.ErrorContains(t, err, "useRemoteGateways is false, expected true")
+}
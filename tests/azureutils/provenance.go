@@ -0,0 +1,215 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/google/uuid"
+)
+
+// The provenance tags the deployment tests add to the subscriptions, resource groups and other resources they create,
+// see utils.WithProvenanceTags, so that a leftover resource can be traced to the test, run and commit that created it,
+// and removed once it has expired.
+const (
+	// TagTest is the name of the test that created the resource.
+	TagTest = "lz-vending-test"
+
+	// TagRunID identifies the test run, e.g. the GitHub Actions run and attempt.
+	TagRunID = "lz-vending-run-id"
+
+	// TagCommit is the commit under test.
+	TagCommit = "lz-vending-commit"
+
+	// TagCreated is the time the test started, in RFC 3339 format.
+	TagCreated = "lz-vending-created"
+
+	// TagExpires is the time after which the resource is no longer in use and can be removed, in RFC 3339 format.
+	TagExpires = "lz-vending-expires"
+)
+
+// Provenance is where a resource created by the deployment tests came from, as recorded in its provenance tags.
+type Provenance struct {
+	Test    string
+	RunID   string
+	Commit  string
+	Created time.Time
+	Expires time.Time
+}
+
+// Tags returns the provenance tags.
+func (p Provenance) Tags() map[string]string {
+	return map[string]string{
+		TagTest:    p.Test,
+		TagRunID:   p.RunID,
+		TagCommit:  p.Commit,
+		TagCreated: p.Created.UTC().Format(time.RFC3339),
+		TagExpires: p.Expires.UTC().Format(time.RFC3339),
+	}
+}
+
+// Expired returns true if the resource has expired at the time.
+// A resource without an expiry time never expires.
+func (p Provenance) Expired(now time.Time) bool {
+	return !p.Expires.IsZero() && now.After(p.Expires)
+}
+
+// String returns the provenance, for logging.
+func (p Provenance) String() string {
+	return fmt.Sprintf("test %s, run %s, commit %s, created %s, expires %s",
+		p.Test, p.RunID, p.Commit, p.Created.Format(time.RFC3339), p.Expires.Format(time.RFC3339))
+}
+
+// ParseProvenance returns the provenance recorded in the tags, and true if they include the TagTest tag.
+// Tag names are compared ignoring case, as Azure does, and times that cannot be parsed are left zero.
+func ParseProvenance(tags map[string]string) (Provenance, bool) {
+	get := func(name string) string {
+		for k, v := range tags {
+			if strings.EqualFold(k, name) {
+				return v
+			}
+		}
+		return ""
+	}
+	p := Provenance{
+		Test:   get(TagTest),
+		RunID:  get(TagRunID),
+		Commit: get(TagCommit),
+	}
+	p.Created, _ = time.Parse(time.RFC3339, get(TagCreated))
+	p.Expires, _ = time.Parse(time.RFC3339, get(TagExpires))
+	return p, p.Test != ""
+}
+
+// TagFilter selects resources by their tags. A resource matches if it has every tag of the filter,
+// with the same value, or any value if the value in the filter is empty. Tag names are compared ignoring case.
+type TagFilter map[string]string
+
+// Matches returns true if the tags match the filter.
+func (tf TagFilter) Matches(tags map[string]string) bool {
+	for name, want := range tf {
+		found := false
+		for k, v := range tags {
+			if strings.EqualFold(k, name) && (want == "" || v == want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// ProvenanceFilter returns a filter matching the resources created by the deployment tests, see TagTest.
+func ProvenanceFilter() TagFilter {
+	return TagFilter{TagTest: ""}
+}
+
+// TaggedSubscription is a subscription with its tags.
+type TaggedSubscription struct {
+	ID          string
+	DisplayName string
+	State       string
+	Tags        map[string]string
+}
+
+// ListSubscriptionsByTag calls Factory.ListSubscriptionsByTag using the default factory, see DefaultFactory.
+func ListSubscriptionsByTag(ctx context.Context, filter TagFilter) ([]TaggedSubscription, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListSubscriptionsByTag(ctx, filter)
+}
+
+// ListSubscriptionsByTag returns the subscriptions the credential has access to whose tags match the filter,
+// ordered by display name.
+func (f *Factory) ListSubscriptionsByTag(ctx context.Context, filter TagFilter) ([]TaggedSubscription, error) {
+	subs, err := f.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]TaggedSubscription, 0)
+	for _, s := range subs {
+		id := deref(s.SubscriptionID)
+		tags, err := f.GetTags(ctx, "/subscriptions/"+id)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Matches(tags) {
+			matched = append(matched, TaggedSubscription{
+				ID:          id,
+				DisplayName: deref(s.DisplayName),
+				State:       string(deref(s.State)),
+				Tags:        tags,
+			})
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].DisplayName < matched[j].DisplayName })
+	return matched, nil
+}
+
+// GetTags calls Factory.GetTags using the default factory, see DefaultFactory.
+func GetTags(ctx context.Context, scope string) (map[string]string, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetTags(ctx, scope)
+}
+
+// GetTags returns the tags of the resource with the supplied id, e.g. a subscription, whose SDK model may not include them.
+func (f *Factory) GetTags(ctx context.Context, scope string) (map[string]string, error) {
+	client, err := f.TagsClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.GetAtScope(ctx, strings.TrimPrefix(scope, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get tags of %s: %w", scope, ClassifyError(err))
+	}
+	if resp.Properties == nil {
+		return map[string]string{}, nil
+	}
+	return ResourceTags(resp.Properties.Tags), nil
+}
+
+// ListResourceGroupsByTag calls Factory.ListResourceGroupsByTag using the default factory, see DefaultFactory.
+func ListResourceGroupsByTag(ctx context.Context, subId uuid.UUID, filter TagFilter) ([]*armresources.ResourceGroup, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListResourceGroupsByTag(ctx, subId, filter)
+}
+
+// ListResourceGroupsByTag returns the resource groups in the subscription whose tags match the filter.
+func (f *Factory) ListResourceGroupsByTag(ctx context.Context, subId uuid.UUID, filter TagFilter) ([]*armresources.ResourceGroup, error) {
+	rgs, err := f.ListResourceGroup(ctx, subId)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*armresources.ResourceGroup, 0)
+	for _, rg := range rgs {
+		if filter.Matches(ResourceTags(rg.Tags)) {
+			matched = append(matched, rg)
+		}
+	}
+	return matched, nil
+}
+
+// ResourceTags returns the tags of a resource from an SDK model, without the nil values.
+func ResourceTags(tags map[string]*string) map[string]string {
+	m := make(map[string]string, len(tags))
+	for k, v := range tags {
+		if v != nil {
+			m[k] = *v
+		}
+	}
+	return m
+}
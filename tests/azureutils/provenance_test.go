@@ -0,0 +1,70 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProvenance(t *testing.T) {
+	t.Parallel()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := Provenance{Test: "TestDeploy/sub", RunID: "42-1", Commit: "abc", Created: created, Expires: created.Add(6 * time.Hour)}
+
+	got, ok := ParseProvenance(want.Tags())
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+	assert.False(t, got.Expired(created.Add(time.Hour)))
+	assert.True(t, got.Expired(created.Add(7*time.Hour)))
+
+	got, ok = ParseProvenance(map[string]string{"LZ-VENDING-TEST": "TestX", TagExpires: "soon"})
+	assert.True(t, ok, "tag names are compared ignoring case")
+	assert.Equal(t, "TestX", got.Test)
+	assert.True(t, got.Expires.IsZero())
+	assert.False(t, got.Expired(time.Now()), "a resource without an expiry time never expires")
+
+	_, ok = ParseProvenance(map[string]string{"env": "prod"})
+	assert.False(t, ok)
+}
+
+func TestTagFilterMatches(t *testing.T) {
+	t.Parallel()
+	tags := map[string]string{"Lz-Vending-Test": "TestX", TagRunID: "42-1"}
+	assert.True(t, ProvenanceFilter().Matches(tags))
+	assert.True(t, TagFilter{TagRunID: "42-1", TagTest: "TestX"}.Matches(tags))
+	assert.False(t, TagFilter{TagRunID: "43-1"}.Matches(tags))
+	assert.False(t, TagFilter{TagCommit: ""}.Matches(tags))
+	assert.True(t, TagFilter{}.Matches(nil))
+	assert.False(t, ProvenanceFilter().Matches(nil))
+}
+
+func TestListByTag(t *testing.T) {
+	srv, id := newArmFake(t)
+	tagged := srv.AddSubscription("testdeploy-0000000b")
+	srv.SetSubscriptionTags(tagged.ID, map[string]string{TagTest: "TestB", TagRunID: "42-1"})
+	other := srv.AddSubscription("testdeploy-0000000a")
+	srv.SetSubscriptionTags(other.ID, map[string]string{TagTest: "TestA", TagRunID: "43-1"})
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg1", map[string]any{"location": "westeurope", "tags": map[string]string{TagTest: "TestA"}})
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg2", map[string]any{"location": "westeurope"})
+
+	subs, err := ListSubscriptionsByTag(context.Background(), ProvenanceFilter())
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	assert.Equal(t, other.ID, subs[0].ID, "ordered by display name")
+	assert.Equal(t, "TestA", subs[0].Tags[TagTest])
+	assert.Equal(t, tagged.ID, subs[1].ID)
+
+	subs, err = ListSubscriptionsByTag(context.Background(), TagFilter{TagRunID: "42-1"})
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "testdeploy-0000000b", subs[0].DisplayName)
+
+	rgs, err := ListResourceGroupsByTag(context.Background(), id, ProvenanceFilter())
+	require.NoError(t, err)
+	require.Len(t, rgs, 1)
+	assert.Equal(t, "rg1", *rgs[0].Name)
+	assert.Equal(t, map[string]string{TagTest: "TestA"}, ResourceTags(rgs[0].Tags))
+}
@@ -0,0 +1,192 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// registeredState is the registration state of a registered resource provider or feature.
+const registeredState = "Registered"
+
+// registrationRetry bounds the polling of VerifyRegistrations if no options are supplied, ten minutes in total,
+// as registering a resource provider can take several minutes.
+var registrationRetry = setuptest.Retry{Max: 30, Wait: 20 * time.Second}
+
+// resourceProviderAddressRe matches the addresses of the resources of the resourceprovider submodule in a plan,
+// capturing the resource provider namespace and, for a feature registration, the feature.
+var resourceProviderAddressRe = regexp.MustCompile(
+	`module\.resourceproviders\["([^"]+)"\]\.azapi_resource_action\.resource_provider_(?:registration|feature_registration\["([^"]+)"\])$`)
+
+// Registration is the registration state of a resource provider, or of a feature of it, in a subscription.
+type Registration struct {
+	Namespace string // The resource provider namespace, e.g. Microsoft.Compute.
+	Feature   string // The feature, or empty for the resource provider itself.
+	State     string // The registration state, e.g. Registered, Registering or Pending.
+}
+
+// String returns the resource provider namespace, and feature if set, and the state.
+func (r Registration) String() string {
+	name := r.Namespace
+	if r.Feature != "" {
+		name += "/" + r.Feature
+	}
+	return name + " is " + r.State
+}
+
+// ResourceProvidersInPlan returns the resource providers and their features registered by the plan,
+// as in the subscription_register_resource_providers_and_features input including its default,
+// from the addresses of the resources of the resourceprovider submodule.
+func ResourceProvidersInPlan(plan *terraform.PlanStruct) map[string][]string {
+	rps := make(map[string][]string)
+	for addr := range plan.ResourceChangesMap {
+		m := resourceProviderAddressRe.FindStringSubmatch(addr)
+		if m == nil {
+			continue
+		}
+		if _, ok := rps[m[1]]; !ok {
+			rps[m[1]] = make([]string, 0)
+		}
+		if m[2] != "" {
+			rps[m[1]] = append(rps[m[1]], m[2])
+		}
+	}
+	for _, features := range rps {
+		sort.Strings(features)
+	}
+	return rps
+}
+
+// GetProviderRegistration calls Factory.GetProviderRegistration using the default factory, see DefaultFactory.
+func GetProviderRegistration(ctx context.Context, subId uuid.UUID, namespace string) (*Registration, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetProviderRegistration(ctx, subId, namespace)
+}
+
+// GetProviderRegistration returns the registration state of the resource provider in the subscription.
+func (f *Factory) GetProviderRegistration(ctx context.Context, subId uuid.UUID, namespace string) (*Registration, error) {
+	client, err := f.ProvidersClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(ctx, namespace, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get resource provider %s: %w", namespace, ClassifyError(err))
+	}
+	return &Registration{
+		Namespace: namespace,
+		State:     deref(resp.RegistrationState),
+	}, nil
+}
+
+// GetFeatureRegistration calls Factory.GetFeatureRegistration using the default factory, see DefaultFactory.
+func GetFeatureRegistration(ctx context.Context, subId uuid.UUID, namespace, feature string) (*Registration, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetFeatureRegistration(ctx, subId, namespace, feature)
+}
+
+// GetFeatureRegistration returns the registration state of the feature of the resource provider in the subscription.
+func (f *Factory) GetFeatureRegistration(ctx context.Context, subId uuid.UUID, namespace, feature string) (*Registration, error) {
+	client, err := f.FeaturesClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(ctx, namespace, feature, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get feature %s/%s: %w", namespace, feature, ClassifyError(err))
+	}
+	r := &Registration{
+		Namespace: namespace,
+		Feature:   feature,
+	}
+	if resp.Properties != nil {
+		r.State = deref(resp.Properties.State)
+	}
+	return r, nil
+}
+
+// GetRegistrations calls Factory.GetRegistrations using the default factory, see DefaultFactory.
+func GetRegistrations(ctx context.Context, subId uuid.UUID, rps map[string][]string) ([]Registration, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetRegistrations(ctx, subId, rps)
+}
+
+// GetRegistrations returns the registration state of each resource provider and feature in the map,
+// which has the form of the subscription_register_resource_providers_and_features input,
+// sorted by namespace with each provider before its features.
+func (f *Factory) GetRegistrations(ctx context.Context, subId uuid.UUID, rps map[string][]string) ([]Registration, error) {
+	namespaces := make([]string, 0, len(rps))
+	for ns := range rps {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	regs := make([]Registration, 0, len(rps))
+	for _, ns := range namespaces {
+		r, err := f.GetProviderRegistration(ctx, subId, ns)
+		if err != nil {
+			return nil, err
+		}
+		regs = append(regs, *r)
+		features := append([]string(nil), rps[ns]...)
+		sort.Strings(features)
+		for _, feature := range features {
+			r, err := f.GetFeatureRegistration(ctx, subId, ns, feature)
+			if err != nil {
+				return nil, err
+			}
+			regs = append(regs, *r)
+		}
+	}
+	return regs, nil
+}
+
+// VerifyRegistrations calls Factory.VerifyRegistrations using the default factory, see DefaultFactory.
+func VerifyRegistrations(ctx context.Context, t TestingT, subId uuid.UUID, rps map[string][]string, opts *VerifyOptions) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.VerifyRegistrations(ctx, t, subId, rps, opts)
+}
+
+// VerifyRegistrations checks that each resource provider and feature in the map, which has the form of the
+// subscription_register_resource_providers_and_features input, is registered in the subscription.
+// The states are polled until all are registered or the retries are exhausted, ten minutes if opts is nil,
+// and the error lists those that are not registered, e.g. still Registering or Pending, with their last state.
+func (f *Factory) VerifyRegistrations(ctx context.Context, t TestingT, subId uuid.UUID, rps map[string][]string, opts *VerifyOptions) error {
+	if opts == nil {
+		opts = &VerifyOptions{Retry: registrationRetry}
+	}
+	return poll(ctx, t, "verify resource provider registration in subscription "+subId.String(), opts, func(ctx context.Context) error {
+		regs, err := f.GetRegistrations(ctx, subId, rps)
+		if err != nil {
+			return err
+		}
+		var pending []string
+		for _, r := range regs {
+			if !strings.EqualFold(r.State, registeredState) {
+				pending = append(pending, r.String())
+			}
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("not registered: %s", strings.Join(pending, ", "))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,64 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceProvidersInPlan(t *testing.T) {
+	plan := &terraform.PlanStruct{ResourceChangesMap: map[string]*tfjson.ResourceChange{
+		`module.lz_vending.module.resourceproviders["Microsoft.Compute"].azapi_resource_action.resource_provider_registration`:                    {},
+		`module.lz_vending.module.resourceproviders["Microsoft.PowerBI"].azapi_resource_action.resource_provider_registration`:                    {},
+		`module.lz_vending.module.resourceproviders["Microsoft.PowerBI"].azapi_resource_action.resource_provider_feature_registration["b"]`:       {},
+		`module.lz_vending.module.resourceproviders["Microsoft.PowerBI"].azapi_resource_action.resource_provider_feature_registration["a"]`:       {},
+		`module.lz_vending.module.subscription[0].azapi_resource.subscription[0]`:                                                                 {},
+		`module.lz_vending.module.resourceproviders["Microsoft.Network"].azapi_resource_action.resource_provider_feature_registration["feature"]`: {},
+	}}
+
+	assert.Equal(t, map[string][]string{
+		"Microsoft.Compute": {},
+		"Microsoft.Network": {"feature"},
+		"Microsoft.PowerBI": {"a", "b"},
+	}, ResourceProvidersInPlan(plan))
+}
+
+func TestVerifyRegistrations(t *testing.T) {
+	srv, id := newArmFake(t)
+	rps := map[string][]string{
+		"Microsoft.PowerBI": {"DailyPrivateLinkServicesForPowerBI"},
+		"Microsoft.Compute": {},
+	}
+
+	err := VerifyRegistrations(context.Background(), t, id, rps, fastVerify)
+	assert.EqualError(t, err, "not registered: Microsoft.Compute is NotRegistered, Microsoft.PowerBI is NotRegistered, "+
+		"Microsoft.PowerBI/DailyPrivateLinkServicesForPowerBI is NotRegistered")
+
+	f, err := DefaultFactory()
+	require.NoError(t, err)
+	client, err := f.ProvidersClient(id)
+	require.NoError(t, err)
+	for ns := range rps {
+		_, err := client.Register(context.Background(), ns, nil)
+		require.NoError(t, err)
+	}
+	srv.SetProviderState(id.String(), "Microsoft.Compute", "Registering")
+	srv.PutResource("/subscriptions/"+id.String()+"/providers/Microsoft.Features/providers/Microsoft.PowerBI/features/DailyPrivateLinkServicesForPowerBI",
+		map[string]any{"properties": map[string]any{"state": "Pending"}})
+
+	err = VerifyRegistrations(context.Background(), t, id, rps, fastVerify)
+	assert.EqualError(t, err, "not registered: Microsoft.Compute is Registering, Microsoft.PowerBI/DailyPrivateLinkServicesForPowerBI is Pending")
+
+	srv.SetProviderState(id.String(), "Microsoft.Compute", "Registered")
+	srv.PutResource("/subscriptions/"+id.String()+"/providers/Microsoft.Features/providers/Microsoft.PowerBI/features/DailyPrivateLinkServicesForPowerBI",
+		map[string]any{"properties": map[string]any{"state": "Registered"}})
+	require.NoError(t, VerifyRegistrations(context.Background(), t, id, rps, fastVerify))
+
+	regs, err := GetRegistrations(context.Background(), id, rps)
+	require.NoError(t, err)
+	assert.Len(t, regs, 3)
+}
@@ -0,0 +1,73 @@
+package azureutils
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/cassette"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The subscription ids of the cassettes, as scrubbed by the recorder.
+// The cassettes were recorded against the fake Azure Resource Manager, see the armfake package, not Azure,
+// so they check the paging, polling and error handling of the clients against the responses of the fake.
+var (
+	cassetteSubscription    = uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	cassetteHubSubscription = uuid.MustParse("00000000-0000-0000-0000-000000000003")
+)
+
+// replayFactory returns a factory whose clients replay the cassette named after the test in testdata/cassettes,
+// as the recorder names it, and checks that the test made all the requests recorded.
+// Tests using it cannot run in parallel as it sets environment variables.
+func replayFactory(t *testing.T) *Factory {
+	t.Setenv(armfake.EnvEndpoint, "")
+	t.Setenv(cassette.EnvDir, "")
+	c, err := cassette.Load(filepath.Join("testdata", "cassettes", t.Name()+".json"))
+	require.NoError(t, err)
+	player := cassette.NewPlayer(c)
+	t.Cleanup(func() {
+		assert.Empty(t, player.Unused(), "requests recorded but not made")
+	})
+	f, err := NewFactory(&FactoryOptions{Credential: armfake.Credential{}, Transport: player})
+	require.NoError(t, err)
+	return f
+}
+
+func TestReplayListResourceGroup(t *testing.T) {
+	f := replayFactory(t)
+
+	// the resource groups are returned in two pages
+	rgs, err := f.ListResourceGroup(context.Background(), cassetteSubscription)
+	require.NoError(t, err)
+	require.Len(t, rgs, 2)
+	assert.Equal(t, "rg1", *rgs[0].Name)
+	assert.Equal(t, "rg2", *rgs[1].Name)
+}
+
+func TestReplayListSubnets(t *testing.T) {
+	f := replayFactory(t)
+
+	subnets, err := f.ListSubnets(context.Background(), "rg1", "vnet", cassetteSubscription)
+	require.NoError(t, err)
+	require.Len(t, subnets, 2)
+	assert.Equal(t, "snet1", *subnets[0].Name)
+	assert.Equal(t, "10.0.1.0/24", *subnets[1].Properties.AddressPrefix)
+}
+
+func TestReplayIsSubscriptionInManagementGroup(t *testing.T) {
+	f := replayFactory(t)
+
+	require.NoError(t, f.IsSubscriptionInManagementGroup(context.Background(), t, cassetteSubscription, "mg1"))
+}
+
+func TestReplayCancelSubscription(t *testing.T) {
+	f := replayFactory(t)
+	t.Setenv("AZURE_SUBSCRIPTION_ID", cassetteHubSubscription.String())
+
+	// the resource groups are deleted by long running operations, polled until done
+	require.NoError(t, f.CancelSubscription(context.Background(), t, &cassetteSubscription))
+}
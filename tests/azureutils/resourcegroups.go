@@ -2,21 +2,25 @@ package azureutils
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 	"github.com/google/uuid"
 )
 
-// ListResourceGroup returns all resource groups in the subscription
+// ListResourceGroup calls Factory.ListResourceGroup using the default factory, see DefaultFactory.
 func ListResourceGroup(ctx context.Context, subId uuid.UUID) ([]*armresources.ResourceGroup, error) {
-	cred, err := newDefaultAzureCredential()
+	f, err := DefaultFactory()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Azure credential: %v", err)
+		return nil, err
 	}
-	resourceGroupClient, err := armresources.NewResourceGroupsClient(subId.String(), cred, nil)
+	return f.ListResourceGroup(ctx, subId)
+}
+
+// ListResourceGroup returns all resource groups in the subscription
+func (f *Factory) ListResourceGroup(ctx context.Context, subId uuid.UUID) ([]*armresources.ResourceGroup, error) {
+	resourceGroupClient, err := f.ResourceGroupsClient(subId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create resource group client: %v", err)
+		return nil, err
 	}
 
 	resultPager := resourceGroupClient.NewListPager(nil)
@@ -25,32 +29,39 @@ func ListResourceGroup(ctx context.Context, subId uuid.UUID) ([]*armresources.Re
 	for resultPager.More() {
 		pageResp, err := resultPager.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, ClassifyError(err)
 		}
 		resourceGroups = append(resourceGroups, pageResp.ResourceGroupListResult.Value...)
 	}
 	return resourceGroups, nil
 }
 
-// DeleteResourceGroup deletes a resource group by name and subscription id
+// DeleteResourceGroup calls Factory.DeleteResourceGroup using the default factory, see DefaultFactory.
 func DeleteResourceGroup(ctx context.Context, rgname string, subId uuid.UUID) error {
-	cred, err := newDefaultAzureCredential()
+	f, err := DefaultFactory()
 	if err != nil {
-		return fmt.Errorf("failed to create Azure credential: %v", err)
+		return err
 	}
-	resourceGroupClient, err := armresources.NewResourceGroupsClient(subId.String(), cred, nil)
+	return f.DeleteResourceGroup(ctx, rgname, subId)
+}
+
+// DeleteResourceGroup deletes a resource group by name and subscription id, waiting up to 30 minutes for it to be deleted
+func (f *Factory) DeleteResourceGroup(ctx context.Context, rgname string, subId uuid.UUID) error {
+	resourceGroupClient, err := f.ResourceGroupsClient(subId)
 	if err != nil {
-		return fmt.Errorf("failed to create resource group client: %v", err)
+		return err
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, longRunningTimeout)
+	defer cancel()
 	pollerResp, err := resourceGroupClient.BeginDelete(ctx, rgname, nil)
 	if err != nil {
-		return err
+		return ClassifyError(err)
 	}
 
 	_, err = pollerResp.PollUntilDone(ctx, nil)
 	if err != nil {
-		return err
+		return ClassifyError(err)
 	}
 	return nil
 }
@@ -0,0 +1,223 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+// roleDefinitionsPath is the path segment identifying a role definition resource id.
+const roleDefinitionsPath = "/providers/Microsoft.Authorization/roleDefinitions/"
+
+// RoleAssignment is a role assignment, as returned by the authorization API.
+type RoleAssignment struct {
+	ID               string // The resource id of the role assignment.
+	Name             string // The name (uuid) of the role assignment.
+	Scope            string // The scope the role is assigned at.
+	PrincipalID      string // The principal (object) id the role is assigned to.
+	RoleDefinitionID string // The resource id of the role definition.
+	Condition        string // The ABAC condition, or empty.
+	ConditionVersion string // The version of the condition, or empty.
+}
+
+// String returns the role, principal and scope of the assignment, for logging.
+func (r RoleAssignment) String() string {
+	return fmt.Sprintf("role %s assigned to %s at %s", roleDefinitionGUID(r.RoleDefinitionID), r.PrincipalID, r.Scope)
+}
+
+// ListRoleAssignmentsOptions are the options for ListRoleAssignments.
+type ListRoleAssignmentsOptions struct {
+	// PrincipalID limits the assignments to those of the principal (object) id, if set.
+	PrincipalID string
+
+	// IncludeInherited includes the assignments at the scopes above the scope, e.g. the subscription of a resource group,
+	// which also apply at the scope.
+	IncludeInherited bool
+}
+
+// ListRoleAssignments calls Factory.ListRoleAssignments using the default factory, see DefaultFactory.
+func ListRoleAssignments(ctx context.Context, scope string, opts *ListRoleAssignmentsOptions) ([]RoleAssignment, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListRoleAssignments(ctx, scope, opts)
+}
+
+// ListRoleAssignments returns the role assignments at the scope, and optionally those inherited from above it.
+// The assignments at the scopes beneath it are never returned.
+func (f *Factory) ListRoleAssignments(ctx context.Context, scope string, opts *ListRoleAssignmentsOptions) ([]RoleAssignment, error) {
+	if opts == nil {
+		opts = &ListRoleAssignmentsOptions{}
+	}
+	client, err := f.RoleAssignmentsClient()
+	if err != nil {
+		return nil, err
+	}
+	filter := "atScope()"
+	pager := client.NewListForScopePager(strings.TrimPrefix(scope, "/"), &armauthorization.RoleAssignmentsClientListForScopeOptions{
+		Filter: &filter,
+	})
+	assignments := make([]RoleAssignment, 0)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list role assignments at scope %s: %w", scope, ClassifyError(err))
+		}
+		for _, ra := range page.Value {
+			r := newRoleAssignment(ra)
+			if opts.PrincipalID != "" && !strings.EqualFold(r.PrincipalID, opts.PrincipalID) {
+				continue
+			}
+			if !opts.IncludeInherited && !sameScope(r.Scope, scope) {
+				continue
+			}
+			assignments = append(assignments, r)
+		}
+	}
+	return assignments, nil
+}
+
+// newRoleAssignment converts the SDK role assignment.
+func newRoleAssignment(ra *armauthorization.RoleAssignment) RoleAssignment {
+	r := RoleAssignment{
+		ID:   deref(ra.ID),
+		Name: deref(ra.Name),
+	}
+	if p := ra.Properties; p != nil {
+		r.Scope = deref(p.Scope)
+		r.PrincipalID = deref(p.PrincipalID)
+		r.RoleDefinitionID = deref(p.RoleDefinitionID)
+		r.Condition = deref(p.Condition)
+		r.ConditionVersion = deref(p.ConditionVersion)
+	}
+	return r
+}
+
+// ResolveRoleDefinitionID calls Factory.ResolveRoleDefinitionID using the default factory, see DefaultFactory.
+func ResolveRoleDefinitionID(ctx context.Context, scope, definition string) (string, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return "", err
+	}
+	return f.ResolveRoleDefinitionID(ctx, scope, definition)
+}
+
+// ResolveRoleDefinitionID returns the resource id of the role definition, which is either a role name, e.g. Contributor,
+// or already a role definition id, which is returned unchanged, as the role_assignment_definition input accepts.
+// Role names are looked up at the scope, so that custom roles assignable there are found.
+func (f *Factory) ResolveRoleDefinitionID(ctx context.Context, scope, definition string) (string, error) {
+	if strings.Contains(strings.ToLower(definition), strings.ToLower(roleDefinitionsPath)) {
+		return definition, nil
+	}
+	client, err := f.RoleDefinitionsClient()
+	if err != nil {
+		return "", err
+	}
+	filter := fmt.Sprintf("roleName eq '%s'", definition)
+	pager := client.NewListPager(strings.TrimPrefix(scope, "/"), &armauthorization.RoleDefinitionsClientListOptions{
+		Filter: &filter,
+	})
+	ids := make([]string, 0, 1)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("cannot list role definitions named %q at scope %s: %w", definition, scope, ClassifyError(err))
+		}
+		for _, rd := range page.Value {
+			ids = append(ids, deref(rd.ID))
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no role definition named %q at scope %s", definition, scope)
+	case 1:
+		return ids[0], nil
+	default:
+		return "", fmt.Errorf("%d role definitions named %q at scope %s: %s", len(ids), definition, scope, strings.Join(ids, ", "))
+	}
+}
+
+// ExpectedRoleAssignment is a role assignment expected from the input variables, e.g. an element of role_assignments.
+type ExpectedRoleAssignment struct {
+	PrincipalID      string // The principal (object) id.
+	RoleDefinition   string // The role definition name, e.g. Contributor, or id.
+	Scope            string // The exact scope of the assignment.
+	Condition        string // The ABAC condition, or empty for none.
+	ConditionVersion string // The version of the condition, or empty for none.
+}
+
+// VerifyRoleAssignment calls Factory.VerifyRoleAssignment using the default factory, see DefaultFactory.
+func VerifyRoleAssignment(ctx context.Context, t TestingT, want ExpectedRoleAssignment, opts *VerifyOptions) (*RoleAssignment, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyRoleAssignment(ctx, t, want, opts)
+}
+
+// VerifyRoleAssignment checks that the principal is assigned exactly the role at exactly the scope,
+// with the expected condition and condition version, and returns the assignment.
+// An assignment of the role inherited from a scope above does not satisfy it.
+// As role assignments replicate asynchronously, they are polled until found or the retries are exhausted.
+func (f *Factory) VerifyRoleAssignment(ctx context.Context, t TestingT, want ExpectedRoleAssignment, opts *VerifyOptions) (*RoleAssignment, error) {
+	roleID, err := f.ResolveRoleDefinitionID(ctx, want.Scope, want.RoleDefinition)
+	if err != nil {
+		return nil, err
+	}
+	var found *RoleAssignment
+	desc := fmt.Sprintf("verify role %s assigned to %s at %s", want.RoleDefinition, want.PrincipalID, want.Scope)
+	err = poll(ctx, t, desc, opts, func(ctx context.Context) error {
+		assignments, err := f.ListRoleAssignments(ctx, want.Scope, &ListRoleAssignmentsOptions{PrincipalID: want.PrincipalID})
+		if err != nil {
+			return err
+		}
+		others := make([]string, 0)
+		for i, r := range assignments {
+			if !strings.EqualFold(roleDefinitionGUID(r.RoleDefinitionID), roleDefinitionGUID(roleID)) {
+				others = append(others, roleDefinitionGUID(r.RoleDefinitionID))
+				continue
+			}
+			found = &assignments[i]
+			return compareCondition(r, want)
+		}
+		if len(others) > 0 {
+			return fmt.Errorf("principal has roles %s at the scope, but not %s", strings.Join(others, ", "), roleID)
+		}
+		return fmt.Errorf("principal has no role assignments at the scope")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("role %s assigned to %s at %s: %w", want.RoleDefinition, want.PrincipalID, want.Scope, err)
+	}
+	return found, nil
+}
+
+// compareCondition returns a retry.FatalError if the condition of the assignment is not the expected one.
+func compareCondition(r RoleAssignment, want ExpectedRoleAssignment) error {
+	var diffs []string
+	if r.Condition != want.Condition {
+		diffs = append(diffs, fmt.Sprintf("condition is %q, expected %q", r.Condition, want.Condition))
+	}
+	if r.ConditionVersion != want.ConditionVersion {
+		diffs = append(diffs, fmt.Sprintf("condition_version is %q, expected %q", r.ConditionVersion, want.ConditionVersion))
+	}
+	if len(diffs) > 0 {
+		return retry.FatalError{Underlying: errors.New(strings.Join(diffs, ", "))}
+	}
+	return nil
+}
+
+// roleDefinitionGUID returns the name (uuid) of the role definition id,
+// which identifies the role regardless of the scope in the id.
+func roleDefinitionGUID(id string) string {
+	return id[strings.LastIndex(id, "/")+1:]
+}
+
+// sameScope returns true if the scopes are the same, ignoring case and trailing slashes.
+func sameScope(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "/"), strings.TrimSuffix(b, "/"))
+}
@@ -0,0 +1,84 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRoleDefinitionID(t *testing.T) {
+	_, id := newArmFake(t)
+	scope := "/subscriptions/" + id.String()
+
+	rd, err := ResolveRoleDefinitionID(context.Background(), scope, "Storage Blob Data Contributor")
+	require.NoError(t, err)
+	assert.Equal(t, scope+"/providers/Microsoft.Authorization/roleDefinitions/ba92f5b4-2d11-453d-a403-e96b0029c9fe", rd)
+
+	rd, err = ResolveRoleDefinitionID(context.Background(), scope, "/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7")
+	require.NoError(t, err)
+	assert.Equal(t, "/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7", rd)
+
+	_, err = ResolveRoleDefinitionID(context.Background(), scope, "Not A Role")
+	assert.ErrorContains(t, err, `no role definition named "Not A Role"`)
+}
+
+func TestVerifyRoleAssignment(t *testing.T) {
+	srv, id := newArmFake(t)
+	sub := "/subscriptions/" + id.String()
+	rg := sub + "/resourceGroups/rg"
+	principal := "00000000-0000-0000-0000-000000000001"
+	srv.PutResource(rg, map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/providers/Microsoft.Authorization/roleAssignments/11111111-1111-1111-1111-111111111111", map[string]any{
+		"properties": map[string]any{
+			"principalId":      principal,
+			"roleDefinitionId": sub + "/providers/Microsoft.Authorization/roleDefinitions/b24988ac-6180-42a0-ab88-20f7382dd24c",
+		},
+	})
+	srv.PutResource(rg+"/providers/Microsoft.Authorization/roleAssignments/22222222-2222-2222-2222-222222222222", map[string]any{
+		"properties": map[string]any{
+			"principalId":      principal,
+			"roleDefinitionId": sub + "/providers/Microsoft.Authorization/roleDefinitions/ba92f5b4-2d11-453d-a403-e96b0029c9fe",
+			"condition":        "@Resource[Microsoft.Storage/storageAccounts/blobServices/containers:ContainerName] StringEqualsIgnoreCase 'blobs'",
+			"conditionVersion": "2.0",
+		},
+	})
+
+	ras, err := ListRoleAssignments(context.Background(), rg, nil)
+	require.NoError(t, err)
+	require.Len(t, ras, 1)
+	assert.Equal(t, rg, ras[0].Scope)
+	ras, err = ListRoleAssignments(context.Background(), rg, &ListRoleAssignmentsOptions{IncludeInherited: true, PrincipalID: principal})
+	require.NoError(t, err)
+	assert.Len(t, ras, 2)
+	ras, err = ListRoleAssignments(context.Background(), rg, &ListRoleAssignmentsOptions{IncludeInherited: true, PrincipalID: "00000000-0000-0000-0000-000000000002"})
+	require.NoError(t, err)
+	assert.Empty(t, ras)
+
+	want := ExpectedRoleAssignment{
+		PrincipalID:      principal,
+		RoleDefinition:   "Storage Blob Data Contributor",
+		Scope:            rg,
+		Condition:        "@Resource[Microsoft.Storage/storageAccounts/blobServices/containers:ContainerName] StringEqualsIgnoreCase 'blobs'",
+		ConditionVersion: "2.0",
+	}
+	ra, err := VerifyRoleAssignment(context.Background(), t, want, fastVerify)
+	require.NoError(t, err)
+	assert.Equal(t, "22222222-2222-2222-2222-222222222222", ra.Name)
+
+	// an inherited assignment is not at the exact scope
+	inherited := ExpectedRoleAssignment{PrincipalID: principal, RoleDefinition: "Contributor", Scope: rg}
+	_, err = VerifyRoleAssignment(context.Background(), t, inherited, fastVerify)
+	assert.ErrorContains(t, err, "principal has roles ba92f5b4-2d11-453d-a403-e96b0029c9fe at the scope, but not")
+	inherited.Scope = sub
+	_, err = VerifyRoleAssignment(context.Background(), t, inherited, fastVerify)
+	assert.NoError(t, err)
+
+	// a different condition does not converge, so it is reported without retrying
+	want.ConditionVersion = "1.0"
+	_, err = VerifyRoleAssignment(context.Background(), t, want, &VerifyOptions{Retry: setuptest.Retry{Max: 3, Wait: time.Hour}})
+	assert.ErrorContains(t, err, `condition_version is "2.0", expected "1.0"`)
+}
@@ -8,19 +8,27 @@ import (
 	"github.com/google/uuid"
 )
 
+// ListSubnets calls Factory.ListSubnets using the default factory, see DefaultFactory.
+func ListSubnets(ctx context.Context, rg, vnet string, subid uuid.UUID) ([]*armnetwork.Subnet, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListSubnets(ctx, rg, vnet, subid)
+}
+
 // ListSubnets lists all subnets in the given virtual network.
-func ListSubnets(rg, vnet string, subid uuid.UUID) ([]*armnetwork.Subnet, error) {
-	ctx := context.Background()
+func (f *Factory) ListSubnets(ctx context.Context, rg, vnet string, subid uuid.UUID) ([]*armnetwork.Subnet, error) {
 	subnets := make([]*armnetwork.Subnet, 0)
-	client, err := NewSubnetClient(subid)
+	client, err := f.SubnetsClient(subid)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create subnet client: %v", err)
+		return nil, err
 	}
 	pager := client.NewListPager(rg, vnet, nil)
 	for pager.More() {
 		pageResp, err := pager.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to list subnets: %v", err)
+			return nil, fmt.Errorf("failed to list subnets: %w", ClassifyError(err))
 		}
 		subnets = append(subnets, pageResp.SubnetListResult.Value...)
 	}
@@ -2,150 +2,214 @@ package azureutils
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
-	"testing"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/managementgroups/armmanagementgroups"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/subscription/armsubscription"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
 	"github.com/google/uuid"
-	"github.com/gruntwork-io/terratest/modules/retry"
-	"golang.org/x/sync/errgroup"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
 )
 
-// CancelSubscription cancels the supplied Azure subscription.
-// it retries a few times as the subscription api is eventually consistent.
-func CancelSubscription(t *testing.T, id *uuid.UUID) error {
-	t.Logf("cancelling subscription %s", id.String())
+// TestingT is the subset of *testing.T used by the helpers in this package.
+// It allows the helpers to be used outside of go test, e.g. by the sweeper command.
+type TestingT interface {
+	terratesting.TestingT
+	Logf(format string, args ...any)
+}
 
-	sub, err := GetSubscription(*id)
+// CancelSubscription calls Factory.CancelSubscription using the default factory, see DefaultFactory.
+func CancelSubscription(ctx context.Context, t TestingT, id *uuid.UUID) error {
+	f, err := DefaultFactory()
 	if err != nil {
-		return fmt.Errorf("subscription %s does not exist or cannot successfully check, %s", id, err)
+		return err
 	}
+	return f.CancelSubscription(ctx, t, id)
+}
+
+// CancelSubscription cancels the supplied Azure subscription, after removing its resources, see Cleanup.
+// As the subscription is cancelled, every management lock in it is removed, see TeardownOptions.AllLocks.
+// As it is deferred by the tests, it runs with a context from CleanupContext,
+// so the subscription is still cancelled if ctx is done, e.g. the test timed out.
+func (f *Factory) CancelSubscription(ctx context.Context, t TestingT, id *uuid.UUID) error {
+	ctx, cancel := CleanupContext(ctx)
+	defer cancel()
+	t.Logf("cancelling subscription %s", id.String())
+	return f.Cleanup(ctx, t, *id, &CleanupOptions{TeardownOptions: TeardownOptions{AllLocks: true}})
+}
 
-	client, err := NewSubscriptionClient()
+// cancelSubscription cancels the subscription.
+// it retries a few times as the subscription api is eventually consistent.
+func (f *Factory) cancelSubscription(ctx context.Context, t TestingT, id uuid.UUID) error {
+	client, err := f.SubscriptionClient()
 	if err != nil {
-		return fmt.Errorf("cannot create subscription client, %s", err)
+		return fmt.Errorf("cannot create subscription client, %w", err)
 	}
-	ctx := context.TODO()
-	g, ctx := errgroup.WithContext(ctx)
-	g.SetLimit(10)
 
-	rgs, err := ListResourceGroup(ctx, *id)
+	err = doWithRetry(ctx, t, "cancel subscription", setuptest.FastRetry, func() error {
+		_, err := client.Cancel(ctx, id.String(), nil)
+		if err = ClassifyError(err); errors.Is(err, ErrSubscriptionNotActive) {
+			return nil
+		}
+		return err
+	})
+
 	if err != nil {
-		return fmt.Errorf("cannot list resource groups for subscription %s, %v", id, err)
+		return fmt.Errorf("cannot cancel subscription %s, %w", id, err)
 	}
+	t.Logf("cancelled subscription %s", id.String())
+	return nil
+}
 
-	t.Logf("removing %d resource groups for subscription %s", len(rgs), id)
+// SubscriptionExists calls Factory.SubscriptionExists using the default factory, see DefaultFactory.
+func SubscriptionExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return false, err
+	}
+	return f.SubscriptionExists(ctx, id)
+}
 
-	for _, rg := range rgs {
-		rg := rg // https://golang.org/doc/faq#closures_and_goroutines
-		g.Go(func() error {
-			t.Logf("removing resource group %s for subscription %s", *rg.Name, id.String())
-			return DeleteResourceGroup(ctx, *rg.Name, *id)
-		})
+// SubscriptionExists checks if the supplied subscription exists
+func (f *Factory) SubscriptionExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	client, err := f.SubscriptionsClient()
+	if err != nil {
+		return false, fmt.Errorf("cannot create subscriptions client, %w", err)
 	}
-	if err := g.Wait(); err != nil {
-		return fmt.Errorf("cannot delete resource groups for subscription %s, %v", id, err)
+	if _, err := client.Get(ctx, id.String(), nil); err != nil {
+		return false, fmt.Errorf("cannot get subscription, %w", ClassifyError(err))
 	}
-	t.Logf("removed %d resource groups for subscription %s", len(rgs), id)
+	return true, nil
+}
 
-	// If the sub is already in warned or disabled state then do not try and cancel again.
-	if *sub.State == "Disabled" || *sub.State == "Warned" {
-		t.Logf("subscription %s is already cancelled", id.String())
-		return nil
+// ListSubscriptions calls Factory.ListSubscriptions using the default factory, see DefaultFactory.
+func ListSubscriptions(ctx context.Context) ([]*armsubscription.Subscription, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
 	}
+	return f.ListSubscriptions(ctx)
+}
 
-	ctx, cancel := context.WithCancel(context.TODO())
-	defer cancel()
-
-	_, err = retry.DoWithRetryE(t, "cancel subscription", setuptest.FastRetry.Max, setuptest.FastRetry.Wait, func() (string, error) {
-		_, err := client.Cancel(ctx, id.String(), nil)
+// ListSubscriptions returns all subscriptions that the credential has access to.
+func (f *Factory) ListSubscriptions(ctx context.Context) ([]*armsubscription.Subscription, error) {
+	client, err := f.SubscriptionsClient()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create subscriptions client, %w", err)
+	}
+	subs := make([]*armsubscription.Subscription, 0)
+	pager := client.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
-			if strings.Contains(err.Error(), "Subscription is not in active state") {
-				return "", nil
-			}
-			return "", err
+			return nil, fmt.Errorf("cannot list subscriptions, %w", ClassifyError(err))
 		}
-		return "", nil
-	})
+		subs = append(subs, page.Value...)
+	}
+	return subs, nil
+}
 
+// ListSubscriptionAliases calls Factory.ListSubscriptionAliases using the default factory, see DefaultFactory.
+func ListSubscriptionAliases(ctx context.Context) ([]*armsubscription.AliasResponse, error) {
+	f, err := DefaultFactory()
 	if err != nil {
-		return fmt.Errorf("cannot cancel subscription %s, %v", id, err)
+		return nil, err
 	}
-	t.Logf("cancelled subscription %s", id.String())
-	return nil
+	return f.ListSubscriptionAliases(ctx)
 }
 
-// SubscriptionExists checks if the supplied subscription exists
-func SubscriptionExists(id uuid.UUID) (bool, error) {
-	client, err := NewSubscriptionsClient()
+// ListSubscriptionAliases returns all subscription aliases that the credential has access to.
+func (f *Factory) ListSubscriptionAliases(ctx context.Context) ([]*armsubscription.AliasResponse, error) {
+	client, err := f.AliasClient()
 	if err != nil {
-		return false, fmt.Errorf("cannot create subscriptions client, %s", err)
+		return nil, fmt.Errorf("cannot create alias client, %w", err)
 	}
-	ctx := context.Background()
-	if _, err := client.Get(ctx, id.String(), nil); err != nil {
-		return false, fmt.Errorf("cannot get subscription, %s", err)
+	resp, err := client.List(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list subscription aliases, %w", ClassifyError(err))
 	}
-	return true, nil
+	return resp.Value, nil
+}
+
+// GetSubscription calls Factory.GetSubscription using the default factory, see DefaultFactory.
+func GetSubscription(ctx context.Context, id uuid.UUID) (armsubscription.SubscriptionsClientGetResponse, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return armsubscription.SubscriptionsClientGetResponse{}, err
+	}
+	return f.GetSubscription(ctx, id)
 }
 
 // GetSubscription checks if the supplied subscription exists and returns it
-func GetSubscription(id uuid.UUID) (armsubscription.SubscriptionsClientGetResponse, error) {
-	client, err := NewSubscriptionsClient()
+func (f *Factory) GetSubscription(ctx context.Context, id uuid.UUID) (armsubscription.SubscriptionsClientGetResponse, error) {
+	client, err := f.SubscriptionsClient()
 	var resp armsubscription.SubscriptionsClientGetResponse
 	if err != nil {
-		return resp, fmt.Errorf("cannot create subscriptions client, %s", err)
+		return resp, fmt.Errorf("cannot create subscriptions client, %w", err)
 	}
-	ctx := context.Background()
 	resp, err = client.Get(ctx, id.String(), nil)
 	if err != nil {
-		return resp, fmt.Errorf("cannot get subscription, %s", err)
+		return resp, fmt.Errorf("cannot get subscription, %w", ClassifyError(err))
 	}
 	return resp, nil
 }
 
+// IsSubscriptionInManagementGroup calls Factory.IsSubscriptionInManagementGroup using the default factory, see DefaultFactory.
+func IsSubscriptionInManagementGroup(ctx context.Context, t TestingT, id uuid.UUID, mg string) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.IsSubscriptionInManagementGroup(ctx, t, id, mg)
+}
+
 // IsSubscriptionInManagementGroup returns true if the subscription is a management group.
-func IsSubscriptionInManagementGroup(t *testing.T, id uuid.UUID, mg string) error {
-	if exists, err := SubscriptionExists(id); err != nil || !exists {
-		return fmt.Errorf("subscription %s does not exist, or could not successfully check, %s", id, err)
+func (f *Factory) IsSubscriptionInManagementGroup(ctx context.Context, t TestingT, id uuid.UUID, mg string) error {
+	if exists, err := f.SubscriptionExists(ctx, id); err != nil || !exists {
+		return fmt.Errorf("subscription %s does not exist, or could not successfully check, %w", id, err)
 	}
 
-	client, err := NewManagementGroupSubscriptionsClient()
+	client, err := f.ManagementGroupSubscriptionsClient()
 	if err != nil {
-		return fmt.Errorf("cannot create mg subscriptions client, %s", err)
+		return fmt.Errorf("cannot create mg subscriptions client, %w", err)
 	}
 
 	var mgopts armmanagementgroups.ManagementGroupSubscriptionsClientGetSubscriptionOptions
 	cc := "no-cache"
 	mgopts.CacheControl = &cc
 
-	_, err = retry.DoWithRetryE(t, "is subscription in management group", setuptest.FastRetry.Max, setuptest.FastRetry.Wait, func() (string, error) {
-		_, err := client.GetSubscription(context.Background(), mg, id.String(), &mgopts)
-		if err != nil {
-			return "", err
-		}
-		return "", nil
+	err = doWithRetry(ctx, t, "is subscription in management group", setuptest.FastRetry, func() error {
+		_, err := client.GetSubscription(ctx, mg, id.String(), &mgopts)
+		return ClassifyError(err)
 	})
 	if err != nil {
-		return fmt.Errorf("failed determine if subscription %s in management group %s: %v", id.String(), mg, err)
+		return fmt.Errorf("failed determine if subscription %s in management group %s: %w", id.String(), mg, err)
 	}
 	return nil
 }
 
+// SetSubscriptionManagementGroup calls Factory.SetSubscriptionManagementGroup using the default factory, see DefaultFactory.
+func SetSubscriptionManagementGroup(ctx context.Context, id uuid.UUID, mg string) error {
+	f, err := DefaultFactory()
+	if err != nil {
+		return err
+	}
+	return f.SetSubscriptionManagementGroup(ctx, id, mg)
+}
+
 // SetSubscriptionManagementGroup moves the subscription to the management group.
-func SetSubscriptionManagementGroup(id uuid.UUID, mg string) error {
-	client, err := NewManagementGroupSubscriptionsClient()
+func (f *Factory) SetSubscriptionManagementGroup(ctx context.Context, id uuid.UUID, mg string) error {
+	client, err := f.ManagementGroupSubscriptionsClient()
 	if err != nil {
-		return fmt.Errorf("cannot create mg subscriptions client, %s", err)
+		return fmt.Errorf("cannot create mg subscriptions client, %w", err)
 	}
 	cc := "no-cache"
 	opts := armmanagementgroups.ManagementGroupSubscriptionsClientCreateOptions{
 		CacheControl: &cc,
 	}
-	if _, err := client.Create(context.Background(), mg, id.String(), &opts); err != nil {
-		return fmt.Errorf("cannot create subscription %s in management group %s, %s", id.String(), mg, err)
+	if _, err := client.Create(ctx, mg, id.String(), &opts); err != nil {
+		return fmt.Errorf("cannot create subscription %s in management group %s, %w", id.String(), mg, ClassifyError(err))
 	}
 	return nil
 }
@@ -0,0 +1,82 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newArmFake starts a fake ARM server with a single subscription
+// and points the azureutils clients at it.
+// Tests using it cannot run in parallel as it sets environment variables.
+func newArmFake(t *testing.T) (*armfake.Server, uuid.UUID) {
+	srv := armfake.NewServer(t)
+	srv.Setenv(t)
+	sub := srv.AddSubscription("test")
+	return srv, uuid.MustParse(sub.ID)
+}
+
+func TestCancelSubscription(t *testing.T) {
+	srv, id := newArmFake(t)
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg2", map[string]any{"location": "westeurope"})
+
+	require.NoError(t, CancelSubscription(context.Background(), t, &id))
+	assert.Empty(t, srv.ResourceIDs("/subscriptions/"+id.String()+"/resourceGroups"))
+	sub, _ := srv.Subscription(id.String())
+	assert.Equal(t, "Warned", sub.State)
+
+	// Cancelling an already cancelled subscription is not an error.
+	require.NoError(t, CancelSubscription(context.Background(), t, &id))
+}
+
+func TestListAndDeleteResourceGroup(t *testing.T) {
+	srv, id := newArmFake(t)
+	srv.PageSize = 1
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg2", map[string]any{"location": "westeurope"})
+
+	rgs, err := ListResourceGroup(context.Background(), id)
+	require.NoError(t, err)
+	assert.Len(t, rgs, 2)
+
+	require.NoError(t, DeleteResourceGroup(context.Background(), "rg1", id))
+	rgs, err = ListResourceGroup(context.Background(), id)
+	require.NoError(t, err)
+	require.Len(t, rgs, 1)
+	assert.Equal(t, "rg2", *rgs[0].Name)
+}
+
+func TestListSubnets(t *testing.T) {
+	srv, id := newArmFake(t)
+	rg := "/subscriptions/" + id.String() + "/resourceGroups/rg"
+	srv.PutResource(rg, map[string]any{"location": "westeurope"})
+	srv.PutResource(rg+"/providers/Microsoft.Network/virtualNetworks/vnet", map[string]any{
+		"properties": map[string]any{
+			"subnets": []any{
+				map[string]any{"name": "a", "properties": map[string]any{"addressPrefix": "10.0.0.0/24"}},
+				map[string]any{"name": "b", "properties": map[string]any{"addressPrefix": "10.0.1.0/24"}},
+			},
+		},
+	})
+
+	subnets, err := ListSubnets(context.Background(), "rg", "vnet", id)
+	require.NoError(t, err)
+	require.Len(t, subnets, 2)
+	assert.Equal(t, "10.0.0.0/24", *subnets[0].Properties.AddressPrefix)
+}
+
+func TestSubscriptionManagementGroup(t *testing.T) {
+	_, id := newArmFake(t)
+
+	require.NoError(t, IsSubscriptionInManagementGroup(context.Background(), t, id, armfake.DefaultTenantID))
+	require.NoError(t, SetSubscriptionManagementGroup(context.Background(), id, armfake.DefaultTenantID))
+
+	exists, err := SubscriptionExists(context.Background(), uuid.New())
+	assert.Error(t, err)
+	assert.False(t, exists)
+}
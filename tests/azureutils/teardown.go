@@ -0,0 +1,157 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+	"github.com/gruntwork-io/terratest/modules/retry"
+	"golang.org/x/sync/errgroup"
+)
+
+// TeardownOptions are the options for removing the resource groups of a subscription.
+type TeardownOptions struct {
+	// AllLocks removes every management lock in the subscription, including those not created by the submodules.
+	// By default only the resource group locks with the default name used by the submodules, lock-<resource group name>,
+	// are removed. Any other lock is left in place, and will cause the deletion of the resources it protects to fail.
+	AllLocks bool
+
+	// ResourceGroups limits the teardown to the named resource groups, and to the locks in them.
+	// By default every resource group in the subscription is deleted.
+	ResourceGroups []string
+}
+
+// TeardownReport lists what was removed from a subscription by RemoveResourceGroups.
+type TeardownReport struct {
+	SubscriptionID uuid.UUID
+	Unlocked       []ManagementLock // The locks that were deleted.
+	Deleted        []string         // The names of the resource groups that were deleted.
+}
+
+// String returns a summary of the report, for logging.
+func (r *TeardownReport) String() string {
+	locks := make([]string, len(r.Unlocked))
+	for i, l := range r.Unlocked {
+		locks[i] = l.String()
+	}
+	return fmt.Sprintf("subscription %s: unlocked %d [%s], deleted %d resource groups [%s]",
+		r.SubscriptionID, len(r.Unlocked), strings.Join(locks, "; "), len(r.Deleted), strings.Join(r.Deleted, ", "))
+}
+
+// RemoveResourceGroups calls Factory.RemoveResourceGroups using the default factory, see DefaultFactory.
+func RemoveResourceGroups(ctx context.Context, t TestingT, id uuid.UUID, opts *TeardownOptions) (*TeardownReport, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.RemoveResourceGroups(ctx, t, id, opts)
+}
+
+// RemoveResourceGroups deletes all resource groups in the subscription, or those in TeardownOptions.ResourceGroups,
+// first removing the resource group locks created by the submodules, or every management lock if TeardownOptions.AllLocks is set.
+// The report lists what was unlocked and deleted, and is returned even if there is an error.
+func (f *Factory) RemoveResourceGroups(ctx context.Context, t TestingT, id uuid.UUID, opts *TeardownOptions) (*TeardownReport, error) {
+	if opts == nil {
+		opts = &TeardownOptions{}
+	}
+	report := &TeardownReport{SubscriptionID: id}
+
+	rgs, err := f.ListResourceGroup(ctx, id)
+	if err != nil {
+		return report, fmt.Errorf("cannot list resource groups for subscription %s, %w", id, err)
+	}
+	if opts.ResourceGroups != nil {
+		rgs = slices.DeleteFunc(rgs, func(rg *armresources.ResourceGroup) bool {
+			return !opts.includes(deref(rg.Name))
+		})
+	}
+
+	// The locks at subscription scope include those in every resource group.
+	locks, err := f.ListLocks(ctx, id, "")
+	if err != nil {
+		return report, err
+	}
+	// unlocked are the lower case names of the resource groups that had a lock removed, "" for the subscription.
+	unlocked := make(map[string]bool)
+	for _, l := range locks {
+		if opts.ResourceGroups != nil && !opts.includes(l.ResourceGroup()) {
+			continue
+		}
+		if !opts.AllLocks && !l.IsModuleLock() {
+			t.Logf("keeping %s for subscription %s", l, id)
+			continue
+		}
+		t.Logf("removing %s for subscription %s", l, id)
+		if err := f.DeleteLock(ctx, l.ID); err != nil {
+			return report, err
+		}
+		report.Unlocked = append(report.Unlocked, l)
+		unlocked[strings.ToLower(l.ResourceGroup())] = true
+	}
+
+	t.Logf("removing %d resource groups for subscription %s", len(rgs), id)
+
+	// A failure to delete one resource group does not stop the others, so that as much as possible is removed.
+	var mu sync.Mutex
+	var errs []error
+	var g errgroup.Group
+	g.SetLimit(10)
+	for _, rg := range rgs {
+		rg := rg // https://golang.org/doc/faq#closures_and_goroutines
+		g.Go(func() error {
+			t.Logf("removing resource group %s for subscription %s", *rg.Name, id.String())
+			var err error
+			if unlocked[""] || unlocked[strings.ToLower(*rg.Name)] {
+				err = f.deleteUnlockedResourceGroup(ctx, t, *rg.Name, id)
+			} else {
+				err = f.DeleteResourceGroup(ctx, *rg.Name, id)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cannot delete resource group %s, %w", *rg.Name, err))
+				return nil
+			}
+			report.Deleted = append(report.Deleted, *rg.Name)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	sort.Strings(report.Deleted)
+	if err := errors.Join(errs...); err != nil {
+		return report, fmt.Errorf("cannot delete resource groups for subscription %s, %w", id, err)
+	}
+	t.Logf("removed %d resource groups for subscription %s", len(rgs), id)
+	return report, nil
+}
+
+// deleteUnlockedResourceGroup deletes a resource group whose locks have just been removed.
+// The removal of a lock is eventually consistent, so the deletion is retried while it is still prevented by the lock.
+func (f *Factory) deleteUnlockedResourceGroup(ctx context.Context, t TestingT, rgname string, subId uuid.UUID) error {
+	var last error
+	err := doWithRetry(ctx, t, "delete unlocked resource group "+rgname, setuptest.FastRetry, func() error {
+		last = f.DeleteResourceGroup(ctx, rgname, subId)
+		if last != nil && !errors.Is(last, ErrResourceGroupLocked) {
+			return retry.FatalError{Underlying: last}
+		}
+		return last
+	})
+	if _, ok := err.(retry.FatalError); ok {
+		return last
+	}
+	return err
+}
+
+// includes returns true if the resource group is one of TeardownOptions.ResourceGroups, ignoring case as ARM does.
+func (o *TeardownOptions) includes(rg string) bool {
+	return slices.ContainsFunc(o.ResourceGroups, func(name string) bool {
+		return strings.EqualFold(name, rg)
+	})
+}
@@ -0,0 +1,128 @@
+package azureutils
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockBody is the body of a CanNotDelete management lock.
+var lockBody = map[string]any{"properties": map[string]any{"level": "CanNotDelete"}}
+
+func TestManagementLockIsModuleLock(t *testing.T) {
+	t.Parallel()
+
+	rg := "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg-vnet"
+	long := strings.Repeat("a", 90)
+	cases := []struct {
+		lock ManagementLock
+		want bool
+	}{
+		{ManagementLock{Name: "lock-rg-vnet", Scope: rg}, true},
+		{ManagementLock{Name: "LOCK-RG-VNET", Scope: rg}, true},
+		{ManagementLock{Name: "custom", Scope: rg}, false},
+		{ManagementLock{Name: "lock-rg-vnet", Scope: rg + "/providers/Microsoft.Network/virtualNetworks/vnet"}, false},
+		{ManagementLock{Name: "lock-rg-vnet", Scope: "/subscriptions/00000000-0000-0000-0000-000000000000"}, false},
+		{ManagementLock{Name: ("lock-" + long)[:90], Scope: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/" + long}, true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, c.lock.IsModuleLock(), c.lock.String())
+	}
+}
+
+func TestParseLockID(t *testing.T) {
+	t.Parallel()
+
+	sub := "/subscriptions/00000000-0000-0000-0000-000000000001"
+	cases := []struct {
+		id     string
+		rg     string
+		target *lockTarget
+	}{
+		{sub + "/providers/Microsoft.Authorization/locks/lock", "", nil},
+		{sub + "/resourceGroups/rg/providers/Microsoft.Authorization/locks/lock", "rg", nil},
+		{sub + "/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/providers/Microsoft.Authorization/locks/lock", "rg",
+			&lockTarget{namespace: "Microsoft.Network", resourceType: "virtualNetworks", name: "vnet"}},
+		{sub + "/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/sn/providers/Microsoft.Authorization/locks/lock", "rg",
+			&lockTarget{namespace: "Microsoft.Network", parentPath: "virtualNetworks/vnet", resourceType: "subnets", name: "sn"}},
+	}
+	for _, c := range cases {
+		subId, rg, target, name, err := parseLockID(c.id)
+		require.NoError(t, err, c.id)
+		assert.Equal(t, "00000000-0000-0000-0000-000000000001", subId.String(), c.id)
+		assert.Equal(t, c.rg, rg, c.id)
+		assert.Equal(t, c.target, target, c.id)
+		assert.Equal(t, "lock", name, c.id)
+	}
+
+	_, _, _, _, err := parseLockID(sub + "/resourceGroups/rg")
+	assert.Error(t, err, "not a lock")
+}
+
+func TestCancelSubscriptionRemovesLocks(t *testing.T) {
+	srv, id := newArmFake(t)
+	sub := "/subscriptions/" + id.String()
+	srv.PutResource(sub+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock-rg1", lockBody)
+	srv.PutResource(sub+"/resourceGroups/rg2", map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/providers/Microsoft.Authorization/locks/subscription-lock", lockBody)
+
+	require.NoError(t, CancelSubscription(context.Background(), t, &id))
+	assert.Empty(t, srv.ResourceIDs(sub+"/resourceGroups"))
+	assert.Empty(t, srv.ResourceIDs(sub+"/providers/Microsoft.Authorization/locks"))
+}
+
+func TestRemoveResourceGroupsModuleLocks(t *testing.T) {
+	srv, id := newArmFake(t)
+	sub := "/subscriptions/" + id.String()
+	srv.PutResource(sub+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock-rg1", lockBody)
+	srv.PutResource(sub+"/resourceGroups/rg2", map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/resourceGroups/rg2/providers/Microsoft.Authorization/locks/custom", lockBody)
+
+	locks, err := ListLocks(context.Background(), id, "rg1")
+	require.NoError(t, err)
+	require.Len(t, locks, 1)
+	assert.Equal(t, ManagementLock{
+		ID:    sub + "/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock-rg1",
+		Name:  "lock-rg1",
+		Level: "CanNotDelete",
+		Scope: sub + "/resourceGroups/rg1",
+	}, locks[0])
+
+	report, err := RemoveResourceGroups(context.Background(), t, id, nil)
+	require.Error(t, err, "the custom lock prevents rg2 from being deleted")
+	assert.Contains(t, err.Error(), "ScopeLocked")
+	require.Len(t, report.Unlocked, 1)
+	assert.Equal(t, "lock-rg1", report.Unlocked[0].Name)
+	assert.Equal(t, []string{"rg1"}, report.Deleted)
+	assert.Contains(t, report.String(), "unlocked 1 [CanNotDelete lock lock-rg1 on "+sub+"/resourceGroups/rg1]")
+
+	report, err = RemoveResourceGroups(context.Background(), t, id, &TeardownOptions{AllLocks: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rg2"}, report.Deleted)
+	assert.Empty(t, srv.ResourceIDs(sub+"/resourceGroups"))
+}
+
+func TestRemoveResourceGroupsSelected(t *testing.T) {
+	srv, id := newArmFake(t)
+	sub := "/subscriptions/" + id.String()
+	srv.PutResource(sub+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/resourceGroups/rg1/providers/Microsoft.Authorization/locks/lock-rg1", lockBody)
+	srv.PutResource(sub+"/resourceGroups/rg2", map[string]any{"location": "westeurope"})
+	srv.PutResource(sub+"/resourceGroups/rg2/providers/Microsoft.Authorization/locks/lock-rg2", lockBody)
+
+	report, err := RemoveResourceGroups(context.Background(), t, id, &TeardownOptions{ResourceGroups: []string{"RG1"}})
+	require.NoError(t, err)
+	require.Len(t, report.Unlocked, 1)
+	assert.Equal(t, "lock-rg1", report.Unlocked[0].Name)
+	assert.Equal(t, []string{"rg1"}, report.Deleted)
+	assert.Empty(t, srv.ResourceIDs(sub+"/resourceGroups/rg1"))
+	assert.ElementsMatch(t, []string{
+		sub + "/resourceGroups/rg2",
+		sub + "/resourceGroups/rg2/providers/Microsoft.Authorization/locks/lock-rg2",
+	}, srv.ResourceIDs(sub+"/resourceGroups"), "the other resource groups and their locks are kept")
+}
@@ -0,0 +1,316 @@
+package azureutils
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// throttleClass is the class of an operation, as the Azure Resource Manager rate limits count them.
+type throttleClass int
+
+const (
+	readClass throttleClass = iota
+	writeClass
+	deleteClass
+)
+
+// throttleClassOf returns the class of the operation of the HTTP method.
+func throttleClassOf(method string) throttleClass {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return readClass
+	case http.MethodDelete:
+		return deleteClass
+	default:
+		return writeClass
+	}
+}
+
+// remainingHeaders are the headers with the number of requests of each class remaining in the rate limit of the subscription,
+// or of the tenant for tenant level operations, indexed by class.
+var remainingHeaders = [...][]string{
+	readClass:   {"x-ms-ratelimit-remaining-subscription-reads", "x-ms-ratelimit-remaining-tenant-reads"},
+	writeClass:  {"x-ms-ratelimit-remaining-subscription-writes", "x-ms-ratelimit-remaining-tenant-writes"},
+	deleteClass: {"x-ms-ratelimit-remaining-subscription-deletes", "x-ms-ratelimit-remaining-tenant-deletes"},
+}
+
+// retryStatusCodes are the statuses retried by the retry policy of the clients, the default less 429 Too Many Requests,
+// which is retried by the throttling policy instead.
+var retryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Backoff is the exponential backoff between the retries of the throttled requests of a class of operation.
+type Backoff struct {
+	Base time.Duration // The delay before the first retry, doubled for each retry after it.
+	Max  time.Duration // The longest delay.
+}
+
+// delay returns the delay before the retry, from zero, with jitter in [0, 1) reducing it by up to a half.
+func (b Backoff) delay(retry int, jitter float64) time.Duration {
+	d := b.Max
+	if retry < 32 && b.Base<<retry > 0 && b.Base<<retry < b.Max {
+		d = b.Base << retry
+	}
+	return d - time.Duration(float64(d)*jitter/2)
+}
+
+// ThrottleOptions are the options of the throttling policy shared by the clients of a factory, see FactoryOptions.
+// Zero fields take the default.
+type ThrottleOptions struct {
+	// MaxRetries is the number of times a throttled request is retried, six by default.
+	MaxRetries int
+
+	// Read, Write and Delete are the backoffs for reads (GET), writes (PUT, PATCH and POST) and deletes,
+	// used when a throttled response has no Retry-After header.
+	Read, Write, Delete Backoff
+
+	// LowRemaining is the number of requests of a class remaining in the rate limit, from the x-ms-ratelimit-remaining headers,
+	// below which a request is delayed by the base of the backoff of the class before it is sent, ten by default.
+	LowRemaining int
+}
+
+// defaultThrottleOptions are the defaults of ThrottleOptions.
+var defaultThrottleOptions = ThrottleOptions{
+	MaxRetries:   6,
+	Read:         Backoff{Base: 2 * time.Second, Max: time.Minute},
+	Write:        Backoff{Base: 5 * time.Second, Max: 2 * time.Minute},
+	Delete:       Backoff{Base: 5 * time.Second, Max: 2 * time.Minute},
+	LowRemaining: 10,
+}
+
+// withDefaults returns the options with the defaults in place of the zero fields.
+func (o *ThrottleOptions) withDefaults() ThrottleOptions {
+	d := defaultThrottleOptions
+	if o == nil {
+		return d
+	}
+	r := *o
+	if r.MaxRetries == 0 {
+		r.MaxRetries = d.MaxRetries
+	}
+	for _, b := range []struct{ o, d *Backoff }{{&r.Read, &d.Read}, {&r.Write, &d.Write}, {&r.Delete, &d.Delete}} {
+		if b.o.Base == 0 {
+			b.o.Base = b.d.Base
+		}
+		if b.o.Max == 0 {
+			b.o.Max = max(b.d.Max, b.o.Base)
+		}
+	}
+	if r.LowRemaining == 0 {
+		r.LowRemaining = d.LowRemaining
+	}
+	return r
+}
+
+// ThrottleCounts are the counters of the throttling policy for a class of operation.
+type ThrottleCounts struct {
+	Requests  int64         `json:"requests"`  // The requests sent, including retries.
+	Throttled int64         `json:"throttled"` // The responses with status 429 Too Many Requests.
+	Retried   int64         `json:"retried"`   // The retries of throttled requests.
+	Delayed   int64         `json:"delayed"`   // The requests delayed as few requests remained in the rate limit.
+	Waited    time.Duration `json:"waited"`    // The time waited before retrying or sending delayed requests.
+}
+
+// add adds the counts.
+func (c *ThrottleCounts) add(o ThrottleCounts) {
+	c.Requests += o.Requests
+	c.Throttled += o.Throttled
+	c.Retried += o.Retried
+	c.Delayed += o.Delayed
+	c.Waited += o.Waited
+}
+
+// String returns the counts, for logging.
+func (c ThrottleCounts) String() string {
+	return fmt.Sprintf("%d requests, %d throttled, %d retried, %d delayed, waited %s",
+		c.Requests, c.Throttled, c.Retried, c.Delayed, c.Waited.Round(time.Millisecond))
+}
+
+// ThrottleStats are the counters of the throttling policy of a factory, by class of operation.
+type ThrottleStats struct {
+	Read   ThrottleCounts `json:"read"`
+	Write  ThrottleCounts `json:"write"`
+	Delete ThrottleCounts `json:"delete"`
+}
+
+// Total returns the sum of the counts of all the classes.
+func (s ThrottleStats) Total() ThrottleCounts {
+	var t ThrottleCounts
+	t.add(s.Read)
+	t.add(s.Write)
+	t.add(s.Delete)
+	return t
+}
+
+// String returns the counts of each class, for logging.
+func (s ThrottleStats) String() string {
+	return fmt.Sprintf("read: %s; write: %s; delete: %s", s.Read, s.Write, s.Delete)
+}
+
+// counts returns the counts of the class.
+func (s *ThrottleStats) counts(c throttleClass) *ThrottleCounts {
+	switch c {
+	case readClass:
+		return &s.Read
+	case deleteClass:
+		return &s.Delete
+	default:
+		return &s.Write
+	}
+}
+
+// throttlePolicy retries the requests throttled by Azure Resource Manager, after the delay in the Retry-After header of the response
+// or else a jittered exponential backoff for the class of the operation, and delays requests when few remain in the rate limit.
+// It is shared by all the clients of a factory, so that they slow down together.
+type throttlePolicy struct {
+	opts ThrottleOptions
+
+	// sleep waits for the duration or until the context is done, it is overridden in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+
+	// jitter returns a random number in [0, 1), it is overridden in tests.
+	jitter func() float64
+
+	mu        sync.Mutex
+	stats     ThrottleStats
+	remaining [3]int // The requests remaining in the rate limit by class from the last response, or -1 if unknown.
+}
+
+// newThrottlePolicy creates a throttling policy with the options.
+func newThrottlePolicy(opts *ThrottleOptions) *throttlePolicy {
+	return &throttlePolicy{
+		opts:      opts.withDefaults(),
+		sleep:     sleep,
+		jitter:    rand.Float64,
+		remaining: [3]int{-1, -1, -1},
+	}
+}
+
+// Do implements policy.Policy.
+func (p *throttlePolicy) Do(req *policy.Request) (*http.Response, error) {
+	ctx := req.Raw().Context()
+	class := throttleClassOf(req.Raw().Method)
+	backoff := p.backoff(class)
+
+	if p.low(class) {
+		d := backoff.delay(0, p.jitter())
+		p.count(class, func(c *ThrottleCounts) { c.Delayed++; c.Waited += d })
+		if err := p.sleep(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+
+	for retry := 0; ; retry++ {
+		p.count(class, func(c *ThrottleCounts) { c.Requests++ })
+		resp, err := req.Next()
+		if err != nil {
+			return resp, err
+		}
+		p.observe(class, resp)
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		p.count(class, func(c *ThrottleCounts) { c.Throttled++ })
+		if retry >= p.opts.MaxRetries || req.RewindBody() != nil {
+			return resp, nil
+		}
+		d := retryAfter(resp)
+		if d <= 0 {
+			d = backoff.delay(retry, p.jitter())
+		}
+		runtime.Drain(resp)
+		p.count(class, func(c *ThrottleCounts) { c.Retried++; c.Waited += d })
+		if err := p.sleep(ctx, d); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Stats returns the counters of the policy.
+func (p *throttlePolicy) Stats() ThrottleStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// backoff returns the backoff of the class.
+func (p *throttlePolicy) backoff(c throttleClass) Backoff {
+	switch c {
+	case readClass:
+		return p.opts.Read
+	case deleteClass:
+		return p.opts.Delete
+	default:
+		return p.opts.Write
+	}
+}
+
+// count updates the counts of the class.
+func (p *throttlePolicy) count(c throttleClass, update func(*ThrottleCounts)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	update(p.stats.counts(c))
+}
+
+// low returns true if few requests of the class remained in the rate limit after the last response.
+func (p *throttlePolicy) low(c throttleClass) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.remaining[c] >= 0 && p.remaining[c] < p.opts.LowRemaining
+}
+
+// observe records the requests of the class remaining in the rate limit, if the response has the header.
+func (p *throttlePolicy) observe(c throttleClass, resp *http.Response) {
+	for _, h := range remainingHeaders[c] {
+		if n, err := strconv.Atoi(resp.Header.Get(h)); err == nil {
+			p.mu.Lock()
+			p.remaining[c] = n
+			p.mu.Unlock()
+			return
+		}
+	}
+}
+
+// retryAfter returns the delay requested by the Retry-After header of the response, in seconds or a date,
+// or by the retry-after-ms or x-ms-retry-after-ms headers, or zero if there is none.
+func retryAfter(resp *http.Response) time.Duration {
+	for _, h := range []string{"retry-after-ms", "x-ms-retry-after-ms"} {
+		if ms, err := strconv.Atoi(resp.Header.Get(h)); err == nil && ms > 0 {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if s, err := strconv.Atoi(v); err == nil {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// sleep waits for the duration, or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
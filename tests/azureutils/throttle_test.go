@@ -0,0 +1,177 @@
+package azureutils
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// throttlingTransport throttles the first requests of each method sent to the fake, with the headers.
+type throttlingTransport struct {
+	client *http.Client
+	header http.Header
+
+	mu       sync.Mutex
+	throttle map[string]int // The number of requests to throttle by method.
+	sent     map[string]int // The number of requests sent to the fake by method.
+}
+
+func (c *throttlingTransport) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	throttle := c.throttle[req.Method] > 0
+	if throttle {
+		c.throttle[req.Method]--
+	} else {
+		c.sent[req.Method]++
+	}
+	c.mu.Unlock()
+	if !throttle {
+		return c.client.Do(req)
+	}
+	return &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     c.header.Clone(),
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// recordSleeps replaces the sleep of the throttling policy of the factory with one that records the delays and returns at once,
+// and removes the jitter.
+func recordSleeps(f *Factory) *[]time.Duration {
+	var delays []time.Duration
+	f.throttle.jitter = func() float64 { return 0 }
+	f.throttle.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return &delays
+}
+
+func TestThrottledRequestsAreRetried(t *testing.T) {
+	srv, id := newArmFake(t)
+	srv.PutResource("/subscriptions/"+id.String()+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	transport := &throttlingTransport{
+		client:   srv.Client(),
+		header:   http.Header{},
+		throttle: map[string]int{http.MethodGet: 3, http.MethodDelete: 1},
+		sent:     map[string]int{},
+	}
+	f, err := NewFactory(&FactoryOptions{
+		Credential: armfake.Credential{},
+		Transport:  transport,
+		Throttle: &ThrottleOptions{
+			Read:   Backoff{Base: time.Second, Max: 3 * time.Second},
+			Delete: Backoff{Base: 10 * time.Second},
+		},
+	})
+	require.NoError(t, err)
+	delays := recordSleeps(f)
+	ctx := context.Background()
+
+	rgs, err := f.ListResourceGroup(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, rgs, 1)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, *delays, "exponential backoff capped at the max")
+
+	*delays = nil
+	require.NoError(t, f.DeleteResourceGroup(ctx, "rg1", id))
+	assert.Equal(t, []time.Duration{10 * time.Second}, *delays, "the backoff of deletes")
+	assert.Empty(t, srv.ResourceIDs("/subscriptions/"+id.String()+"/resourceGroups"))
+
+	stats := f.Throttling()
+	assert.Equal(t, int64(3), stats.Read.Throttled)
+	assert.Equal(t, int64(3), stats.Read.Retried)
+	assert.Equal(t, int64(transport.sent[http.MethodGet]+3), stats.Read.Requests)
+	assert.Equal(t, 6*time.Second, stats.Read.Waited)
+	assert.Equal(t, int64(1), stats.Delete.Throttled)
+	assert.Equal(t, int64(1), stats.Delete.Retried)
+	assert.Zero(t, stats.Write)
+	assert.Equal(t, int64(4), stats.Total().Throttled)
+	assert.True(t, strings.HasPrefix(stats.String(), "read: "))
+}
+
+func TestThrottledRequestsHonourRetryAfter(t *testing.T) {
+	srv, id := newArmFake(t)
+	transport := &throttlingTransport{
+		client:   srv.Client(),
+		header:   http.Header{"Retry-After": {"17"}},
+		throttle: map[string]int{http.MethodGet: 2},
+		sent:     map[string]int{},
+	}
+	f, err := NewFactory(&FactoryOptions{Credential: armfake.Credential{}, Transport: transport})
+	require.NoError(t, err)
+	delays := recordSleeps(f)
+
+	_, err = f.GetSubscription(context.Background(), id)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{17 * time.Second, 17 * time.Second}, *delays)
+}
+
+func TestThrottledRequestsGiveUp(t *testing.T) {
+	srv, id := newArmFake(t)
+	transport := &throttlingTransport{
+		client:   srv.Client(),
+		header:   http.Header{"Retry-After-Ms": {"250"}},
+		throttle: map[string]int{http.MethodGet: 10},
+		sent:     map[string]int{},
+	}
+	f, err := NewFactory(&FactoryOptions{
+		Credential: armfake.Credential{},
+		Transport:  transport,
+		Throttle:   &ThrottleOptions{MaxRetries: 2},
+	})
+	require.NoError(t, err)
+	delays := recordSleeps(f)
+
+	_, err = f.GetSubscription(context.Background(), id)
+	assert.ErrorIs(t, err, ErrThrottled)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}, *delays)
+	assert.Equal(t, int64(3), f.Throttling().Read.Requests, "the retry policy of the client does not retry throttled requests too")
+	assert.Equal(t, int64(3), f.Throttling().Read.Throttled)
+	assert.Zero(t, transport.sent[http.MethodGet])
+}
+
+func TestRequestsAreDelayedWhenFewRemain(t *testing.T) {
+	srv, id := newArmFake(t)
+	transport := &throttlingTransport{
+		client:   srv.Client(),
+		header:   http.Header{"Retry-After": {"1"}, "X-Ms-Ratelimit-Remaining-Subscription-Reads": {"3"}},
+		throttle: map[string]int{http.MethodGet: 1},
+		sent:     map[string]int{},
+	}
+	f, err := NewFactory(&FactoryOptions{Credential: armfake.Credential{}, Transport: transport})
+	require.NoError(t, err)
+	delays := recordSleeps(f)
+	ctx := context.Background()
+
+	// the throttled response reports few reads remaining, so the next read is delayed by the base of the read backoff
+	_, err = f.GetSubscription(ctx, id)
+	require.NoError(t, err)
+	_, err = f.GetSubscription(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Second, defaultThrottleOptions.Read.Base}, *delays)
+	assert.Equal(t, int64(1), f.Throttling().Read.Delayed)
+}
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	b := Backoff{Base: 2 * time.Second, Max: time.Minute}
+	assert.Equal(t, 2*time.Second, b.delay(0, 0))
+	assert.Equal(t, 16*time.Second, b.delay(3, 0))
+	assert.Equal(t, 8*time.Second, b.delay(3, 1), "jitter reduces the delay by up to a half")
+	assert.Equal(t, time.Minute, b.delay(5, 0))
+	assert.Equal(t, time.Minute, b.delay(100, 0))
+
+	assert.Equal(t, readClass, throttleClassOf(http.MethodHead))
+	assert.Equal(t, writeClass, throttleClassOf(http.MethodPatch))
+	assert.Equal(t, deleteClass, throttleClassOf(http.MethodDelete))
+}
@@ -0,0 +1,316 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/google/uuid"
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+const (
+	// the issuers and audience of the federated credentials, as set by the usermanagedidentity submodule.
+	githubIssuer             = "https://token.actions.githubusercontent.com"
+	terraformCloudIssuer     = "https://app.terraform.io"
+	defaultFederatedAudience = "api://AzureADTokenExchange"
+)
+
+// UserAssignedIdentity is a user assigned managed identity.
+type UserAssignedIdentity struct {
+	ID          string // The resource id of the identity.
+	Name        string // The name of the identity.
+	PrincipalID string // The object id of the service principal of the identity.
+	ClientID    string // The client (application) id of the identity.
+	TenantID    string // The tenant of the identity.
+}
+
+// FederatedCredential is a federated identity credential of a user assigned managed identity.
+type FederatedCredential struct {
+	Name      string   // The name of the credential, the last segment of its resource id.
+	Issuer    string   // The URL of the token issuer.
+	Subject   string   // The subject of the token.
+	Audiences []string // The audiences of the token.
+}
+
+// ExpectedIdentity is a user assigned managed identity expected from the input variables.
+type ExpectedIdentity struct {
+	// ID is the resource id of the identity.
+	ID string
+
+	// PrincipalID is the expected object id, e.g. from the principal_id output. It is not checked if empty.
+	PrincipalID string
+
+	// FederatedCredentials are the exact federated credentials of the identity, see FederatedCredentials.
+	FederatedCredentials []FederatedCredential
+
+	// RoleAssignments are the elements of the umi_role_assignments input, which are assigned to the identity
+	// at the scopes relative to the subscription of the identity.
+	RoleAssignments map[string]*inputs.UmiRoleAssignment
+}
+
+// UserAssignedIdentityID returns the resource id of the user assigned managed identity.
+func UserAssignedIdentityID(subId uuid.UUID, resourceGroupName, name string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s", subId, resourceGroupName, name)
+}
+
+// ExpectedIdentityFromInputs returns the identity the root module creates in the subscription from the umi_* inputs.
+func ExpectedIdentityFromInputs(subId uuid.UUID, in *inputs.Inputs) ExpectedIdentity {
+	return ExpectedIdentity{
+		ID:                   UserAssignedIdentityID(subId, deref(in.UmiResourceGroupName), deref(in.UmiName)),
+		FederatedCredentials: FederatedCredentials(in.UmiFederatedCredentialsGithub, in.UmiFederatedCredentialsTerraformCloud, in.UmiFederatedCredentialsAdvanced),
+		RoleAssignments:      in.UmiRoleAssignments,
+	}
+}
+
+// FederatedCredentials returns the federated credentials created from the GitHub, Terraform Cloud and advanced inputs,
+// named and with the subjects as the usermanagedidentity submodule sets them, sorted by name.
+func FederatedCredentials(github map[string]*inputs.GithubCredential, tfc map[string]*inputs.TfcCredential, advanced map[string]*inputs.AdvancedCredential) []FederatedCredential {
+	creds := make([]FederatedCredential, 0, len(github)+len(tfc)+len(advanced))
+	for _, c := range github {
+		value := deref(c.Value)
+		repo := fmt.Sprintf("repo:%s/%s", c.Organization, c.Repository)
+		var suffix, subject string
+		switch c.Entity {
+		case "branch":
+			suffix, subject = "branch-"+value, repo+":ref:refs/heads/"+value
+		case "tag":
+			suffix, subject = "tag-"+value, repo+":ref:refs/tags/"+value
+		case "environment":
+			suffix, subject = "environment-"+value, repo+":environment:"+value
+		case "pull_request":
+			suffix, subject = "pull-request", repo+":pull_request"
+		}
+		creds = append(creds, FederatedCredential{
+			Name:      coalesce(deref(c.Name), fmt.Sprintf("github-%s-%s-%s", c.Organization, c.Repository, suffix)),
+			Issuer:    githubIssuer,
+			Subject:   subject,
+			Audiences: []string{defaultFederatedAudience},
+		})
+	}
+	for _, c := range tfc {
+		creds = append(creds, FederatedCredential{
+			Name:      coalesce(deref(c.Name), fmt.Sprintf("terraformcloud-%s-%s-%s-%s", c.Organization, c.Project, c.Workspace, c.RunPhase)),
+			Issuer:    terraformCloudIssuer,
+			Subject:   fmt.Sprintf("organization:%s:project:%s:workspace:%s:run_phase:%s", c.Organization, c.Project, c.Workspace, c.RunPhase),
+			Audiences: []string{defaultFederatedAudience},
+		})
+	}
+	for _, c := range advanced {
+		audiences := c.Audiences
+		if audiences == nil {
+			audiences = []string{defaultFederatedAudience}
+		}
+		creds = append(creds, FederatedCredential{
+			Name:      c.Name,
+			Issuer:    c.IssuerURL,
+			Subject:   c.SubjectIdentifier,
+			Audiences: audiences,
+		})
+	}
+	sort.Slice(creds, func(i, j int) bool { return creds[i].Name < creds[j].Name })
+	return creds
+}
+
+// coalesce returns the first non-empty string.
+func coalesce(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// GetUserAssignedIdentity calls Factory.GetUserAssignedIdentity using the default factory, see DefaultFactory.
+func GetUserAssignedIdentity(ctx context.Context, id string) (*UserAssignedIdentity, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.GetUserAssignedIdentity(ctx, id)
+}
+
+// GetUserAssignedIdentity returns the user assigned managed identity with the supplied resource id.
+func (f *Factory) GetUserAssignedIdentity(ctx context.Context, id string) (*UserAssignedIdentity, error) {
+	rid, subId, err := parseIdentityID(id)
+	if err != nil {
+		return nil, err
+	}
+	client, err := f.UserAssignedIdentitiesClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(ctx, rid.ResourceGroupName, rid.Name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get user assigned identity: %w", ClassifyError(err))
+	}
+	umi := &UserAssignedIdentity{
+		ID:   deref(resp.ID),
+		Name: deref(resp.Name),
+	}
+	if p := resp.Properties; p != nil {
+		umi.PrincipalID = deref(p.PrincipalID)
+		umi.ClientID = deref(p.ClientID)
+		umi.TenantID = deref(p.TenantID)
+	}
+	return umi, nil
+}
+
+// ListFederatedCredentials calls Factory.ListFederatedCredentials using the default factory, see DefaultFactory.
+func ListFederatedCredentials(ctx context.Context, id string) ([]FederatedCredential, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.ListFederatedCredentials(ctx, id)
+}
+
+// ListFederatedCredentials returns the federated identity credentials of the user assigned managed identity
+// with the supplied resource id, sorted by name.
+func (f *Factory) ListFederatedCredentials(ctx context.Context, id string) ([]FederatedCredential, error) {
+	rid, subId, err := parseIdentityID(id)
+	if err != nil {
+		return nil, err
+	}
+	client, err := f.FederatedIdentityCredentialsClient(subId)
+	if err != nil {
+		return nil, err
+	}
+	creds := make([]FederatedCredential, 0)
+	pager := client.NewListPager(rid.ResourceGroupName, rid.Name, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list federated credentials of %s: %w", id, ClassifyError(err))
+		}
+		for _, c := range page.Value {
+			fc := FederatedCredential{Name: deref(c.Name)}
+			if p := c.Properties; p != nil {
+				fc.Issuer = deref(p.Issuer)
+				fc.Subject = deref(p.Subject)
+				for _, a := range p.Audiences {
+					fc.Audiences = append(fc.Audiences, deref(a))
+				}
+			}
+			creds = append(creds, fc)
+		}
+	}
+	sort.Slice(creds, func(i, j int) bool { return creds[i].Name < creds[j].Name })
+	return creds, nil
+}
+
+// parseIdentityID parses the resource id of a user assigned managed identity.
+func parseIdentityID(id string) (*arm.ResourceID, uuid.UUID, error) {
+	rid, err := arm.ParseResourceID(id)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("cannot parse user assigned identity id %s: %w", id, err)
+	}
+	subId, err := uuid.Parse(rid.SubscriptionID)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("cannot parse subscription id of user assigned identity %s: %w", id, err)
+	}
+	return rid, subId, nil
+}
+
+// VerifyUserAssignedIdentity calls Factory.VerifyUserAssignedIdentity using the default factory, see DefaultFactory.
+func VerifyUserAssignedIdentity(ctx context.Context, t TestingT, want ExpectedIdentity, opts *VerifyOptions) (*UserAssignedIdentity, error) {
+	f, err := DefaultFactory()
+	if err != nil {
+		return nil, err
+	}
+	return f.VerifyUserAssignedIdentity(ctx, t, want, opts)
+}
+
+// VerifyUserAssignedIdentity checks that the user assigned managed identity exists with a principal,
+// the expected one if set, that it has exactly the expected federated credentials,
+// and that its principal is assigned the roles of the umi_role_assignments input, see VerifyRoleAssignment.
+// The identity is returned, with the principal id the role assignments are checked for.
+func (f *Factory) VerifyUserAssignedIdentity(ctx context.Context, t TestingT, want ExpectedIdentity, opts *VerifyOptions) (*UserAssignedIdentity, error) {
+	var umi *UserAssignedIdentity
+	err := poll(ctx, t, "verify user assigned identity "+want.ID, opts, func(ctx context.Context) error {
+		var err error
+		if umi, err = f.GetUserAssignedIdentity(ctx, want.ID); err != nil {
+			return err
+		}
+		switch {
+		case umi.PrincipalID == "":
+			return errors.New("identity has no principal")
+		case want.PrincipalID != "" && !strings.EqualFold(umi.PrincipalID, want.PrincipalID):
+			return retry.FatalError{Underlying: fmt.Errorf("principal id is %s, expected %s", umi.PrincipalID, want.PrincipalID)}
+		}
+		creds, err := f.ListFederatedCredentials(ctx, want.ID)
+		if err != nil {
+			return err
+		}
+		return compareFederatedCredentials(creds, want.FederatedCredentials)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("user assigned identity %s: %w", want.ID, err)
+	}
+
+	rid, _, _ := parseIdentityID(want.ID)
+	keys := make([]string, 0, len(want.RoleAssignments))
+	for k := range want.RoleAssignments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, k := range keys {
+		ra := want.RoleAssignments[k]
+		_, err := f.VerifyRoleAssignment(ctx, t, ExpectedRoleAssignment{
+			PrincipalID:      umi.PrincipalID,
+			RoleDefinition:   ra.Definition,
+			Scope:            "/subscriptions/" + rid.SubscriptionID + deref(ra.RelativeScope),
+			Condition:        deref(ra.Condition),
+			ConditionVersion: deref(ra.ConditionVersion),
+		}, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("umi role assignment %s: %w", k, err))
+		}
+	}
+	return umi, errors.Join(errs...)
+}
+
+// compareFederatedCredentials returns an error listing the differences between the federated credentials and the expected ones.
+// Missing credentials are retried, as they are created one at a time, and any other difference is a retry.FatalError.
+func compareFederatedCredentials(got, want []FederatedCredential) error {
+	byName := make(map[string]FederatedCredential, len(got))
+	for _, c := range got {
+		byName[strings.ToLower(c.Name)] = c
+	}
+	var missing, diffs []string
+	for _, w := range want {
+		c, ok := byName[strings.ToLower(w.Name)]
+		if !ok {
+			missing = append(missing, w.Name)
+			continue
+		}
+		delete(byName, strings.ToLower(w.Name))
+		if c.Issuer != w.Issuer {
+			diffs = append(diffs, fmt.Sprintf("federated credential %s issuer is %s, expected %s", w.Name, c.Issuer, w.Issuer))
+		}
+		if c.Subject != w.Subject {
+			diffs = append(diffs, fmt.Sprintf("federated credential %s subject is %s, expected %s", w.Name, c.Subject, w.Subject))
+		}
+		if !sameStrings(c.Audiences, w.Audiences) {
+			diffs = append(diffs, fmt.Sprintf("federated credential %s audiences are %v, expected %v", w.Name, c.Audiences, w.Audiences))
+		}
+	}
+	for _, c := range got {
+		if _, ok := byName[strings.ToLower(c.Name)]; ok {
+			diffs = append(diffs, fmt.Sprintf("federated credential %s is not expected", c.Name))
+		}
+	}
+	if len(diffs) > 0 {
+		return retry.FatalError{Underlying: errors.New(strings.Join(diffs, ", "))}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("federated credentials %s are missing", strings.Join(missing, ", "))
+	}
+	return nil
+}
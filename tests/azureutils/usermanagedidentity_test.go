@@ -0,0 +1,111 @@
+package azureutils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederatedCredentials(t *testing.T) {
+	creds := FederatedCredentials(
+		map[string]*inputs.GithubCredential{
+			"branch": inputs.NewGithubCredential("my-organization", "my-repository", "branch", "my-branch"),
+			"pr":     inputs.NewGithubCredential("my-organization", "my-repository", "pull_request", ""),
+			"env":    inputs.NewGithubCredential("my-organization", "my-repository", "environment", "prod").WithName("gh-prod"),
+		},
+		map[string]*inputs.TfcCredential{
+			"plan": inputs.NewTfcCredential("my-organization", "my-project", "my-workspace", "plan"),
+		},
+		map[string]*inputs.AdvancedCredential{
+			"adv": inputs.NewAdvancedCredential("advanced", "https://test", "field:value").WithAudiences("a", "b"),
+		},
+	)
+	assert.Equal(t, []FederatedCredential{
+		{Name: "advanced", Issuer: "https://test", Subject: "field:value", Audiences: []string{"a", "b"}},
+		{Name: "gh-prod", Issuer: githubIssuer, Subject: "repo:my-organization/my-repository:environment:prod", Audiences: []string{defaultFederatedAudience}},
+		{Name: "github-my-organization-my-repository-branch-my-branch", Issuer: githubIssuer, Subject: "repo:my-organization/my-repository:ref:refs/heads/my-branch", Audiences: []string{defaultFederatedAudience}},
+		{Name: "github-my-organization-my-repository-pull-request", Issuer: githubIssuer, Subject: "repo:my-organization/my-repository:pull_request", Audiences: []string{defaultFederatedAudience}},
+		{Name: "terraformcloud-my-organization-my-project-my-workspace-plan", Issuer: terraformCloudIssuer, Subject: "organization:my-organization:project:my-project:workspace:my-workspace:run_phase:plan", Audiences: []string{defaultFederatedAudience}},
+	}, creds)
+}
+
+func TestVerifyUserAssignedIdentity(t *testing.T) {
+	srv, id := newArmFake(t)
+	sub := "/subscriptions/" + id.String()
+	principal := "00000000-0000-0000-0000-000000000001"
+	srv.PutResource(sub+"/resourceGroups/rg-umi", map[string]any{"location": "westeurope"})
+
+	in := inputs.New().
+		WithUmiEnabled(true).
+		WithUmi("umi", "rg-umi").
+		WithUmiGithubCredential("gh", inputs.NewGithubCredential("my-organization", "my-repository", "branch", "main")).
+		WithUmiTfcCredential("tfc", inputs.NewTfcCredential("my-organization", "my-project", "my-workspace", "apply")).
+		WithUmiRoleAssignment("owner", inputs.NewUmiRoleAssignment("Owner"))
+	want := ExpectedIdentityFromInputs(id, in)
+	require.Equal(t, UserAssignedIdentityID(id, "rg-umi", "umi"), want.ID)
+
+	_, err := VerifyUserAssignedIdentity(context.Background(), t, want, fastVerify)
+	assert.ErrorContains(t, err, "ResourceNotFound")
+
+	srv.PutResource(want.ID, map[string]any{
+		"location":   "westeurope",
+		"properties": map[string]any{"principalId": principal, "clientId": "00000000-0000-0000-0000-000000000002"},
+	})
+	srv.PutResource(want.ID+"/federatedIdentityCredentials/github-my-organization-my-repository-branch-main", map[string]any{
+		"properties": map[string]any{
+			"issuer":    githubIssuer,
+			"subject":   "repo:my-organization/my-repository:ref:refs/heads/main",
+			"audiences": []any{defaultFederatedAudience},
+		},
+	})
+	_, err = VerifyUserAssignedIdentity(context.Background(), t, want, fastVerify)
+	assert.ErrorContains(t, err, "federated credentials terraformcloud-my-organization-my-project-my-workspace-apply are missing")
+
+	srv.PutResource(want.ID+"/federatedIdentityCredentials/terraformcloud-my-organization-my-project-my-workspace-apply", map[string]any{
+		"properties": map[string]any{
+			"issuer":    terraformCloudIssuer,
+			"subject":   "organization:my-organization:project:my-project:workspace:my-workspace:run_phase:plan",
+			"audiences": []any{defaultFederatedAudience},
+		},
+	})
+	creds, err := ListFederatedCredentials(context.Background(), want.ID)
+	require.NoError(t, err)
+	assert.Len(t, creds, 2)
+
+	// the differences do not converge, so they are reported without retrying
+	slow := &VerifyOptions{Retry: setuptest.Retry{Max: 3, Wait: time.Hour}}
+	_, err = VerifyUserAssignedIdentity(context.Background(), t, want, slow)
+	assert.ErrorContains(t, err, "federated credential terraformcloud-my-organization-my-project-my-workspace-apply subject is "+
+		"organization:my-organization:project:my-project:workspace:my-workspace:run_phase:plan, expected "+
+		"organization:my-organization:project:my-project:workspace:my-workspace:run_phase:apply")
+	wrongPrincipal := want
+	wrongPrincipal.PrincipalID = "00000000-0000-0000-0000-000000000003"
+	_, err = VerifyUserAssignedIdentity(context.Background(), t, wrongPrincipal, slow)
+	assert.ErrorContains(t, err, "principal id is "+principal+", expected 00000000-0000-0000-0000-000000000003")
+
+	srv.PutResource(want.ID+"/federatedIdentityCredentials/terraformcloud-my-organization-my-project-my-workspace-apply", map[string]any{
+		"properties": map[string]any{
+			"issuer":    terraformCloudIssuer,
+			"subject":   "organization:my-organization:project:my-project:workspace:my-workspace:run_phase:apply",
+			"audiences": []any{defaultFederatedAudience},
+		},
+	})
+	_, err = VerifyUserAssignedIdentity(context.Background(), t, want, fastVerify)
+	assert.ErrorContains(t, err, "umi role assignment owner: role Owner assigned to "+principal)
+
+	srv.PutResource(sub+"/providers/Microsoft.Authorization/roleAssignments/11111111-1111-1111-1111-111111111111", map[string]any{
+		"properties": map[string]any{
+			"principalId":      principal,
+			"roleDefinitionId": sub + "/providers/Microsoft.Authorization/roleDefinitions/8e3af657-a8ff-443c-a75c-2fe8c4bcb635",
+		},
+	})
+	umi, err := VerifyUserAssignedIdentity(context.Background(), t, want, fastVerify)
+	require.NoError(t, err)
+	assert.Equal(t, principal, umi.PrincipalID)
+	assert.Equal(t, "00000000-0000-0000-0000-000000000002", umi.ClientID)
+}
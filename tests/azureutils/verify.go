@@ -0,0 +1,73 @@
+package azureutils
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/gruntwork-io/terratest/modules/retry"
+)
+
+// VerifyOptions are the options for the functions that verify the state of deployed resources.
+type VerifyOptions struct {
+	// Retry bounds the polling for resources whose state converges asynchronously.
+	// If zero, setuptest.FastRetry is used.
+	Retry setuptest.Retry
+}
+
+// retry returns the retry configuration of the options, or the default.
+func (o *VerifyOptions) retry() setuptest.Retry {
+	if o == nil || o.Retry.Max == 0 {
+		return setuptest.FastRetry
+	}
+	return o.Retry
+}
+
+// poll calls check until it succeeds, the retries of the options are exhausted or the context is done, returning its last error.
+// Each call of check has a context bounded by requestTimeout.
+// Differences that will not converge should be returned as a retry.FatalError, so they are not polled.
+func poll(ctx context.Context, t TestingT, desc string, opts *VerifyOptions, check func(ctx context.Context) error) error {
+	var last error
+	err := doWithRetry(ctx, t, desc, opts.retry(), func() error {
+		ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+		last = check(ctx)
+		return last
+	})
+	if err == nil {
+		return nil
+	}
+	var fatal retry.FatalError
+	if errors.As(last, &fatal) {
+		return fatal.Underlying
+	}
+	if ctx.Err() != nil {
+		return err
+	}
+	return last
+}
+
+// valueOr returns the value of the optional input, or the default if it is not set.
+func valueOr[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// coalesceList returns the list input, or the default if it is empty, as with coalescelist in the submodules.
+func coalesceList(l, def []string) []string {
+	if len(l) == 0 {
+		return def
+	}
+	return l
+}
+
+// deref returns the value of the pointer, or the zero value if it is nil.
+func deref[T any](p *T) T {
+	var v T
+	if p != nil {
+		v = *p
+	}
+	return v
+}
@@ -0,0 +1,54 @@
+package budget
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/Azure/terratest-terraform-fluent/check"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeployBudgetScopeSubscription tests the deployment of a budget in the test subscription,
+// and that the budget in Azure matches the budgets input, including its notifications.
+func TestDeployBudgetScopeSubscription(t *testing.T) {
+	t.Parallel()
+
+	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	subId, err := uuid.Parse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+	require.NoErrorf(t, err, "could not parse AZURE_SUBSCRIPTION_ID")
+	hex, err := utils.RandomHex(4)
+	require.NoErrorf(t, err, "could not generate random hex")
+	name := "budget-" + hex
+
+	b := inputs.NewBudget(1000, "Monthly",
+		time.Now().Format("2006-01")+"-01T00:00:00Z",
+		time.Now().AddDate(1, 0, 0).Format("2006-01")+"-01T00:00:00Z").
+		WithNotification("actual", inputs.NewBudgetNotification("GreaterThanOrEqualTo", 80).
+			WithContactEmails("email1@example.com", "email2@example.com")).
+		WithNotification("forecasted", inputs.NewBudgetNotification("GreaterThan", 100).
+			WithThresholdType("Forecasted").
+			WithContactRoles("Owner"))
+
+	v := b.ModuleVars(name, subId.String())
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.NoError(t, err)
+	defer test.Cleanup()
+
+	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(1).ErrorIsNilFatal(t)
+	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
+	test.ApplyIdempotent().ErrorIsNil(t)
+
+	assert.NoError(t, azureutils.VerifyBudgets(ctx, t, subId, map[string]*inputs.Budget{name: b}, nil))
+}
@@ -0,0 +1,132 @@
+// Package cassette records the requests made to Azure Resource Manager and their responses to cassette files,
+// and replays them, so that the azureutils helpers can be tested deterministically without Azure.
+//
+// A Recorder is a policy.Transporter that sends the requests with another transporter and writes each request and response,
+// an interaction, to the cassette named after the test that made it, see WithName.
+// Authentication headers are never recorded, and subscription and tenant ids are replaced by placeholders, see Recorder.
+//
+// A Player is a policy.Transporter that answers each request with the response of the first unused interaction
+// with the same method and URL, so repeated requests, e.g. polling a long running operation, get the responses in the order recorded.
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// EnvDir is the environment variable that holds the directory to record cassettes to.
+// When set, the clients of the azureutils factories record the requests they make, e.g. during a deployment test run.
+const EnvDir = "AZUREUTILS_CASSETTE_DIR"
+
+// defaultName is the name of the cassette of the requests made with a context without a name.
+const defaultName = "default"
+
+// Cassette is the interactions recorded, in the order they happened.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request. Its headers are not recorded.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   *Body  `json:"body,omitempty"`
+}
+
+// Response is a recorded response. Only the headers the clients act on are recorded, see recordedHeaders.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       *Body       `json:"body,omitempty"`
+}
+
+// Body is the body of a request or response, kept as is if it is JSON so that the cassettes are readable,
+// or as text otherwise.
+type Body struct {
+	JSON json.RawMessage `json:"json,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// newBody returns the body with the content, or nil if it is empty.
+func newBody(b []byte) *Body {
+	if len(b) == 0 {
+		return nil
+	}
+	if json.Valid(b) {
+		return &Body{JSON: json.RawMessage(b)}
+	}
+	return &Body{Text: string(b)}
+}
+
+// bytes returns the content of the body.
+func (b *Body) bytes() []byte {
+	switch {
+	case b == nil:
+		return nil
+	case b.JSON != nil:
+		return b.JSON
+	default:
+		return []byte(b.Text)
+	}
+}
+
+// Load reads the cassette from the file.
+func Load(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cannot parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to the file.
+func (c *Cassette) Save(path string) error {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return fmt.Errorf("cannot marshal cassette: %w", err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("cannot write cassette: %w", err)
+	}
+	return nil
+}
+
+// nameKey is the context key of the name of the cassette.
+type nameKey struct{}
+
+// WithName returns a context for the requests to record to the cassette with the name, e.g. the name of the test making them.
+// The requests made with a context without a name are recorded to the default cassette.
+func WithName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nameKey{}, name)
+}
+
+// Name returns the name of the cassette of the requests made with the context.
+func Name(ctx context.Context) string {
+	if n, ok := ctx.Value(nameKey{}).(string); ok && n != "" {
+		return n
+	}
+	return defaultName
+}
+
+// fileName returns the name of the file of the cassette, with the separators of subtest names replaced.
+func fileName(name string) string {
+	return strings.NewReplacer("/", "_", `\`, "_", " ", "_").Replace(name) + ".json"
+}
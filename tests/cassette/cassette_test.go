@@ -0,0 +1,141 @@
+package cassette
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	subID    = "7a2b5d3e-91c4-4f0a-8e3b-2f6d1c9a4b70"
+	tenantID = "c3f1e2a4-5b6d-4e7f-8a9b-0c1d2e3f4a5b"
+)
+
+// newARM starts a server answering like Azure Resource Manager, with a long running operation
+// and a response with the subscription and tenant ids.
+func newARM(t *testing.T) *httptest.Server {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ms-correlation-request-id", "secret-correlation")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodDelete:
+			w.Header().Set("Location", "https://"+r.Host+"/subscriptions/"+subID+"/operationresults/op1")
+			w.Header().Set("Retry-After", "15")
+			w.WriteHeader(http.StatusAccepted)
+		case strings.HasSuffix(r.URL.Path, "/operationresults/op1"):
+			polls++
+			if polls == 1 {
+				w.Header().Set("Retry-After", "15")
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Write([]byte(`{"subscriptionId":"` + strings.ToUpper(subID) + `","tenantId":"` + tenantID + `","displayName":"test"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// send sends the request with the transporter, and returns the status and body of the response.
+func send(ctx context.Context, t *testing.T, tr policy.Transporter, method, url string) (*http.Response, string) {
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(`{"properties":{}}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	resp, err := tr.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(b)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+	srv := newARM(t)
+	dir := t.TempDir()
+	rec, err := NewRecorder(srv.Client(), dir)
+	require.NoError(t, err)
+	ctx := WithName(context.Background(), "TestDeploy/sub")
+
+	_, body := send(ctx, t, rec, http.MethodGet, srv.URL+"/subscriptions/"+subID+"?api-version=2016-06-01")
+	assert.Contains(t, body, tenantID, "the response is returned as is")
+	send(ctx, t, rec, http.MethodDelete, srv.URL+"/subscriptions/"+subID+"/resourcegroups/rg1?api-version=2021-04-01")
+	send(ctx, t, rec, http.MethodGet, srv.URL+"/subscriptions/"+subID+"/operationresults/op1")
+	send(ctx, t, rec, http.MethodGet, srv.URL+"/subscriptions/"+subID+"/operationresults/op1")
+	send(context.Background(), t, rec, http.MethodGet, srv.URL+"/providers?api-version=2021-04-01")
+
+	b, err := os.ReadFile(filepath.Join(dir, "TestDeploy_sub.json"))
+	require.NoError(t, err)
+	text := string(b)
+	for _, secret := range []string{subID, strings.ToUpper(subID), tenantID, "secret-token", "secret-correlation", "Authorization"} {
+		assert.NotContains(t, text, secret)
+	}
+	assert.Contains(t, text, `"subscriptionId": "00000000-0000-0000-0000-000000000001"`)
+	assert.Contains(t, text, `"tenantId": "00000000-0000-0000-0000-000000000002"`)
+	assert.FileExists(t, filepath.Join(dir, "default.json"))
+
+	c, err := Load(filepath.Join(dir, "TestDeploy_sub.json"))
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 4)
+	assert.JSONEq(t, `{"properties":{}}`, string(c.Interactions[0].Request.Body.JSON))
+	assert.True(t, strings.HasSuffix(c.Interactions[1].Response.Header.Get("Location"),
+		"/subscriptions/00000000-0000-0000-0000-000000000001/operationresults/op1"))
+
+	// the player ignores the host, and answers repeated requests in the order recorded
+	player := NewPlayer(c)
+	base := "https://management.azure.com/subscriptions/00000000-0000-0000-0000-000000000001"
+	resp, body := send(context.Background(), t, player, http.MethodGet, base+"?api-version=2016-06-01")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{
+		"subscriptionId": "00000000-0000-0000-0000-000000000001",
+		"tenantId": "00000000-0000-0000-0000-000000000002",
+		"displayName": "test"
+	}`, body)
+	resp, _ = send(context.Background(), t, player, http.MethodDelete, base+"/resourceGroups/rg1?api-version=2021-04-01")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, "1", resp.Header.Get("Retry-After-Ms"), "polling does not wait")
+	resp, _ = send(context.Background(), t, player, http.MethodGet, base+"/operationresults/op1")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Len(t, player.Unused(), 1)
+	resp, _ = send(context.Background(), t, player, http.MethodGet, base+"/operationresults/op1")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, player.Unused())
+
+	req, err := http.NewRequest(http.MethodGet, base+"/operationresults/op1", nil)
+	require.NoError(t, err)
+	_, err = player.Do(req)
+	assert.ErrorContains(t, err, "no interaction in the cassette for GET")
+}
+
+func TestName(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "default", Name(context.Background()))
+	assert.Equal(t, "TestX", Name(WithName(context.Background(), "TestX")))
+	assert.Equal(t, "TestX_sub_case.json", fileName("TestX/sub case"))
+}
+
+func TestScrub(t *testing.T) {
+	t.Parallel()
+	var s scrubber
+	body := `{"properties":{"tenant":"` + tenantID + `","principalId":"9b1f2c3d-4e5f-4a6b-8c7d-0e1f2a3b4c5d","clientId":"` + strings.ToUpper(tenantID) + `"}}`
+	assert.Equal(t, "/subscriptions/00000000-0000-0000-0000-000000000001", s.scrub("/subscriptions/"+subID))
+	assert.JSONEq(t, `{"properties":{
+		"tenant": "00000000-0000-0000-0000-000000000002",
+		"principalId": "00000000-0000-0000-0000-000000000003",
+		"clientId": "00000000-0000-0000-0000-000000000002"
+	}}`, s.scrub(body))
+	assert.Equal(t, "00000000-0000-0000-0000-000000000001", s.scrub("00000000-0000-0000-0000-000000000001"), "placeholders are kept")
+}
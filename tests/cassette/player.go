@@ -0,0 +1,99 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// retryAfterHeaders are the headers with the delay before polling a long running operation again, or retrying a request.
+var retryAfterHeaders = []string{"Retry-After", "Retry-After-Ms", "X-Ms-Retry-After-Ms"}
+
+// Player is a policy.Transporter that replays the interactions of a cassette.
+// A request gets the response of the first unused interaction with the same method and URL, ignoring the host,
+// the case of the path and the order of the query parameters, so the hosts of the responses need not match the cloud of the clients.
+// Request bodies are not compared. A request with no such interaction fails.
+// The delays in the Retry-After headers of the responses are replaced by a millisecond, so polling does not wait.
+// A Player is safe for concurrent use.
+type Player struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewPlayer creates a player of the cassette.
+func NewPlayer(c *Cassette) *Player {
+	return &Player{
+		interactions: c.Interactions,
+		used:         make([]bool, len(c.Interactions)),
+	}
+}
+
+// Do implements policy.Transporter.
+func (p *Player) Do(req *http.Request) (*http.Response, error) {
+	i, ok := p.next(req.Method, req.URL)
+	if !ok {
+		return nil, fmt.Errorf("no interaction in the cassette for %s %s", req.Method, req.URL)
+	}
+	resp := &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+		StatusCode:    i.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(i.Response.Body.bytes())),
+		ContentLength: int64(len(i.Response.Body.bytes())),
+		Request:       req,
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	for _, h := range retryAfterHeaders {
+		if resp.Header.Get(h) != "" {
+			resp.Header.Del(h)
+			resp.Header.Set("Retry-After-Ms", "1")
+		}
+	}
+	return resp, nil
+}
+
+// Unused returns the interactions no request has used yet, e.g. to check that a test made all the requests recorded.
+func (p *Player) Unused() []Interaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var unused []Interaction
+	for n, i := range p.interactions {
+		if !p.used[n] {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+// next returns the first unused interaction matching the request and marks it used.
+func (p *Player) next(method string, u *url.URL) (Interaction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n, i := range p.interactions {
+		if p.used[n] || i.Request.Method != method {
+			continue
+		}
+		if r, err := url.Parse(i.Request.URL); err == nil && sameURL(r, u) {
+			p.used[n] = true
+			return i, true
+		}
+	}
+	return Interaction{}, false
+}
+
+// sameURL returns true if the URLs have the same path, ignoring case, and the same query parameters, in any order.
+func sameURL(a, b *url.URL) bool {
+	return strings.EqualFold(strings.TrimSuffix(a.Path, "/"), strings.TrimSuffix(b.Path, "/")) &&
+		reflect.DeepEqual(a.Query(), b.Query())
+}
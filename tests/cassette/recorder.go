@@ -0,0 +1,187 @@
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// recordedHeaders are the response headers recorded, those the clients act on when polling long running operations,
+// paging and retrying. The others, e.g. the correlation ids, are left out of the cassettes.
+var recordedHeaders = []string{
+	"Content-Type",
+	"Location",
+	"Azure-AsyncOperation",
+	"Retry-After",
+	"Retry-After-Ms",
+	"X-Ms-Retry-After-Ms",
+	"X-Ms-Ratelimit-Remaining-Subscription-Reads",
+	"X-Ms-Ratelimit-Remaining-Subscription-Writes",
+	"X-Ms-Ratelimit-Remaining-Subscription-Deletes",
+	"X-Ms-Ratelimit-Remaining-Tenant-Reads",
+	"X-Ms-Ratelimit-Remaining-Tenant-Writes",
+	"X-Ms-Ratelimit-Remaining-Tenant-Deletes",
+}
+
+// uuidPattern matches any uuid, e.g. the subscription, tenant, principal and client ids.
+var uuidPattern = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// placeholderPrefix is the prefix of the placeholders that replace the uuids, which are not replaced again.
+const placeholderPrefix = "00000000-0000-0000-0000-"
+
+// scrubber replaces every uuid with a placeholder, numbered in the order they are first seen,
+// e.g. 00000000-0000-0000-0000-000000000001, so the same id has the same placeholder in all the cassettes of a run.
+// As well as the subscription and tenant ids, this covers the principal, client and object ids of identities
+// and role assignments, and the ids of long running operations.
+type scrubber struct {
+	mu  sync.Mutex
+	ids map[string]string
+}
+
+// scrub returns the text with the ids replaced.
+func (s *scrubber) scrub(text string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ids == nil {
+		s.ids = make(map[string]string)
+	}
+	return uuidPattern.ReplaceAllStringFunc(text, func(id string) string {
+		if strings.HasPrefix(id, placeholderPrefix) {
+			return id
+		}
+		p, ok := s.ids[strings.ToLower(id)]
+		if !ok {
+			p = fmt.Sprintf("%s%012d", placeholderPrefix, len(s.ids)+1)
+			s.ids[strings.ToLower(id)] = p
+		}
+		return p
+	})
+}
+
+// Recorder is a policy.Transporter that records the requests sent by another transporter, and their responses,
+// to cassette files in a directory, one per name, see WithName.
+// The cassette of a name is written again after each of its interactions, so it is complete even if the test times out.
+// The headers of the requests, including the Authorization header, are not recorded, nor are most of the headers of the responses,
+// and the uuids in the URLs, bodies and headers, such as the subscription and tenant ids, are replaced by placeholders.
+// Requests that fail without a response are not recorded. A Recorder is safe for concurrent use.
+type Recorder struct {
+	next policy.Transporter
+	dir  string
+
+	scrubber scrubber
+
+	mu        sync.Mutex
+	cassettes map[string]*Cassette
+}
+
+// NewRecorder creates a recorder that sends the requests with next and records them to the directory, which is created if needed.
+// next may be nil if the recorder is only used as a pipeline policy, see Policy.
+func NewRecorder(next policy.Transporter, dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create cassette directory: %w", err)
+	}
+	return &Recorder{
+		next:      next,
+		dir:       dir,
+		cassettes: make(map[string]*Cassette),
+	}, nil
+}
+
+// Do implements policy.Transporter.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	return r.do(req, r.next.Do)
+}
+
+// Policy returns a policy that records the requests sent by the rest of the pipeline, and their responses.
+// Added as the last per-retry policy of a client, it records each try of a request sent by the transport of the client,
+// which need not be set, so that the pipeline keeps its default transport.
+func (r *Recorder) Policy() policy.Policy {
+	return recorderPolicy{r}
+}
+
+// recorderPolicy is the policy returned by Recorder.Policy.
+type recorderPolicy struct {
+	r *Recorder
+}
+
+// Do implements policy.Policy.
+func (p recorderPolicy) Do(req *policy.Request) (*http.Response, error) {
+	return p.r.do(req.Raw(), func(*http.Request) (*http.Response, error) {
+		return req.Next()
+	})
+}
+
+// do sends the request with send and records it and its response.
+func (r *Recorder) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read request body: %w", err)
+	}
+	resp, err := send(req)
+	if err != nil {
+		return resp, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response body: %w", err)
+	}
+
+	i := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    r.scrubber.scrub(req.URL.String()),
+			Body:   newBody([]byte(r.scrubber.scrub(string(reqBody)))),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Body:       newBody([]byte(r.scrubber.scrub(string(respBody)))),
+		},
+	}
+	for _, h := range recordedHeaders {
+		for _, v := range resp.Header.Values(h) {
+			if i.Response.Header == nil {
+				i.Response.Header = make(http.Header)
+			}
+			i.Response.Header.Add(h, r.scrubber.scrub(v))
+		}
+	}
+	if err := r.record(Name(req.Context()), i); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// record appends the interaction to the cassette with the name and writes it.
+func (r *Recorder) record(name string, i Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.cassettes[name]
+	if !ok {
+		c = &Cassette{}
+		r.cassettes[name] = c
+	}
+	c.Interactions = append(c.Interactions, i)
+	return c.Save(filepath.Join(r.dir, fileName(name)))
+}
+
+// readBody reads the body and replaces it with a reader of the content read, so it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
@@ -0,0 +1,65 @@
+// Command armfake runs the fake Azure Resource Manager until interrupted.
+//
+// It prints the environment variables required to run the deployment tests against it,
+// e.g.:
+//
+//	go run ./cmd/armfake -env-file armfake.env &
+//	source armfake.env && make testdeploy
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+)
+
+func main() {
+	envFile := flag.String("env-file", "", "write the environment variables to this file as well as stdout")
+	flag.Parse()
+
+	dir, err := os.MkdirTemp("", "armfake")
+	if err != nil {
+		log.Fatalf("cannot create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv, err := armfake.New(dir)
+	if err != nil {
+		log.Fatalf("cannot start fake ARM server: %v", err)
+	}
+	defer srv.Close()
+
+	sub := srv.AddSubscription("armfake")
+	env := srv.Env()
+	env["AZURE_BILLING_SCOPE"] = armfake.DefaultBillingScope
+	env["AZURE_SUBSCRIPTION_ID"] = sub.ID
+	env["AZURE_TENANT_ID"] = srv.TenantID
+	env["TERRATEST_DEPLOY"] = "1"
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "export %s=%q\n", k, env[k])
+	}
+	fmt.Print(sb.String())
+	if *envFile != "" {
+		if err := os.WriteFile(*envFile, []byte(sb.String()), 0600); err != nil {
+			log.Fatalf("cannot write env file: %v", err)
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+}
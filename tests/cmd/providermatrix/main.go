@@ -0,0 +1,85 @@
+// Command providermatrix runs the module tests against each provider version combination
+// in a provider matrix file and writes a compatibility table.
+//
+// The tests are run with `go test -json` and the PROVIDER_MATRIX environment variable set,
+// so that tests using utils.RunProviderMatrix run a subtest for each combination.
+// The output of failed subtests is written to stderr.
+//
+//	go run ./cmd/providermatrix -config provider-matrix.json -run ^TestIntegration ./integration
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+)
+
+func main() {
+	config := flag.String("config", "provider-matrix.json", "provider matrix file listing the provider version combinations to test")
+	run := flag.String("run", "^Test", "only run tests matching this regular expression, passed to go test -run")
+	timeout := flag.String("timeout", "60m", "timeout passed to go test -timeout")
+	out := flag.String("out", "", "write the markdown compatibility table to this file instead of stdout")
+	strict := flag.Bool("strict", false, "exit non-zero if any test fails on any combination")
+	flag.Parse()
+
+	pkgs := flag.Args()
+	if len(pkgs) == 0 {
+		pkgs = []string{"./integration"}
+	}
+
+	path, err := filepath.Abs(*config)
+	if err != nil {
+		log.Fatalf("cannot resolve provider matrix path: %v", err)
+	}
+	m, err := utils.LoadProviderMatrix(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	args := append([]string{"test", "-json", "-run", *run, "-timeout", *timeout}, pkgs...)
+	cmd := exec.Command("go", args...)
+	cmd.Env = append(os.Environ(), utils.EnvProviderMatrix+"="+path)
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Fatalf("cannot run go test: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		log.Fatalf("cannot run go test: %v", err)
+	}
+	res, err := readResults(stdout, m.Combinations)
+	if err != nil {
+		log.Fatalf("cannot read go test output: %v", err)
+	}
+	// go test exits non-zero when a test fails, which is reported in the table.
+	_ = cmd.Wait()
+
+	for _, f := range res.failures() {
+		fmt.Fprintf(os.Stderr, "=== FAIL %s\n%s", f.name, f.output)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("cannot create output file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := res.writeTable(w); err != nil {
+		log.Fatalf("cannot write compatibility table: %v", err)
+	}
+
+	if len(res.tests) == 0 {
+		log.Fatal("no tests ran with the provider matrix, check -run and that the tests use utils.RunProviderMatrix")
+	}
+	if *strict && len(res.failures()) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+)
+
+const (
+	resultPass = "pass"
+	resultFail = "fail"
+	resultSkip = "skip"
+)
+
+// testEvent is an event written by `go test -json`, see `go doc test2json`.
+type testEvent struct {
+	Action  string `json:"Action"`
+	Package string `json:"Package"`
+	Test    string `json:"Test"`
+	Output  string `json:"Output"`
+}
+
+// failure is the output of a test that failed on a provider version combination.
+type failure struct {
+	name   string
+	output string
+}
+
+// results holds the outcome of each test on each provider version combination.
+type results struct {
+	// combinations are the names of the provider version combinations, in the order of the matrix file.
+	combinations []string
+
+	// tests maps the test name, e.g. integration.TestIntegrationVwan, to the result on each combination.
+	tests map[string]map[string]string
+
+	// output holds the output of each subtest, keyed by the package and full test name.
+	output map[string]*strings.Builder
+
+	// failed lists the failed subtests in the order they completed.
+	failed []string
+}
+
+// readResults reads the output of `go test -json` and records the result of the subtests
+// named by the supplied provider version combinations.
+func readResults(r io.Reader, combinations []utils.ProviderVersions) (*results, error) {
+	res := &results{
+		combinations: make([]string, len(combinations)),
+		tests:        make(map[string]map[string]string),
+		output:       make(map[string]*strings.Builder),
+	}
+	names := make(map[string]bool, len(combinations))
+	for i, pv := range combinations {
+		res.combinations[i] = pv.Name()
+		names[pv.Name()] = true
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var ev testEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			// go test writes build failures as plain text.
+			continue
+		}
+		parent, combination, ok := strings.Cut(ev.Test, "/")
+		if !ok {
+			continue
+		}
+		// Nested subtests of a combination are reported under the combination.
+		combination, _, _ = strings.Cut(combination, "/")
+		if !names[combination] {
+			continue
+		}
+		key := ev.Package + "." + parent + "/" + combination
+		switch ev.Action {
+		case "output":
+			if res.output[key] == nil {
+				res.output[key] = new(strings.Builder)
+			}
+			res.output[key].WriteString(ev.Output)
+		case resultPass, resultFail, resultSkip:
+			if ev.Test != parent+"/"+combination {
+				continue
+			}
+			name := path.Base(ev.Package) + "." + parent
+			if res.tests[name] == nil {
+				res.tests[name] = make(map[string]string)
+			}
+			res.tests[name][combination] = ev.Action
+			if ev.Action == resultFail {
+				res.failed = append(res.failed, key)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// failures returns the output of each failed subtest.
+func (res *results) failures() []failure {
+	out := make([]failure, 0, len(res.failed))
+	for _, key := range res.failed {
+		f := failure{name: key}
+		if b := res.output[key]; b != nil {
+			f.output = b.String()
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// writeTable writes a markdown table with a row per test and a column per provider version combination.
+// Tests that did not run on a combination are shown as "-".
+// The final row counts the tests that passed on each combination.
+func (res *results) writeTable(w io.Writer) error {
+	tests := make([]string, 0, len(res.tests))
+	for name := range res.tests {
+		tests = append(tests, name)
+	}
+	sort.Strings(tests)
+
+	var b strings.Builder
+	b.WriteString("| Test |")
+	for _, c := range res.combinations {
+		fmt.Fprintf(&b, " %s |", c)
+	}
+	b.WriteString("\n|---|")
+	for range res.combinations {
+		b.WriteString("---|")
+	}
+	b.WriteString("\n")
+
+	passed := make([]int, len(res.combinations))
+	for _, name := range tests {
+		fmt.Fprintf(&b, "| %s |", name)
+		for i, c := range res.combinations {
+			r, ok := res.tests[name][c]
+			if !ok {
+				r = "-"
+			}
+			if r == resultPass {
+				passed[i]++
+			}
+			fmt.Fprintf(&b, " %s |", r)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("| **passed** |")
+	for i := range res.combinations {
+		fmt.Fprintf(&b, " %d/%d |", passed[i], len(tests))
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const goTestJSON = `{"Action":"run","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationVwan"}
+{"Action":"run","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationVwan/azapi=1.12.1,azurerm=3.100.0"}
+{"Action":"output","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationVwan/azapi=1.12.1,azurerm=3.100.0","Output":"    integration_test.go:66: unsupported argument\n"}
+{"Action":"fail","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationVwan/azapi=1.12.1,azurerm=3.100.0"}
+{"Action":"pass","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationVwan/azapi=latest,azurerm=latest"}
+{"Action":"fail","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationVwan"}
+{"Action":"pass","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationHubAndSpoke/azapi=latest,azurerm=latest"}
+{"Action":"pass","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationHubAndSpoke/azapi=latest,azurerm=latest/nested"}
+{"Action":"pass","Package":"github.com/Azure/terraform-azurerm-lz-vending/tests/integration","Test":"TestIntegrationOther/notacombination"}
+# github.com/Azure/terraform-azurerm-lz-vending/tests/broken [build failed]
+`
+
+func TestReadResults(t *testing.T) {
+	combinations := []utils.ProviderVersions{
+		{AzAPI: "1.12.1", AzureRM: "3.100.0"},
+		{AzAPI: "latest", AzureRM: ""},
+	}
+	res, err := readResults(strings.NewReader(goTestJSON), combinations)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]string{
+		"integration.TestIntegrationVwan": {
+			"azapi=1.12.1,azurerm=3.100.0": resultFail,
+			"azapi=latest,azurerm=latest":  resultPass,
+		},
+		"integration.TestIntegrationHubAndSpoke": {
+			"azapi=latest,azurerm=latest": resultPass,
+		},
+	}, res.tests)
+
+	failures := res.failures()
+	require.Len(t, failures, 1)
+	assert.Equal(t, "github.com/Azure/terraform-azurerm-lz-vending/tests/integration.TestIntegrationVwan/azapi=1.12.1,azurerm=3.100.0", failures[0].name)
+	assert.Contains(t, failures[0].output, "unsupported argument")
+
+	var b strings.Builder
+	require.NoError(t, res.writeTable(&b))
+	assert.Equal(t, `| Test | azapi=1.12.1,azurerm=3.100.0 | azapi=latest,azurerm=latest |
+|---|---|---|
+| integration.TestIntegrationHubAndSpoke | - | pass |
+| integration.TestIntegrationVwan | fail | pass |
+| **passed** | 0/2 | 2/2 |
+`, b.String())
+}
@@ -0,0 +1,72 @@
+// Command providermirror populates a Terraform provider filesystem mirror from dependency lock files,
+// so that the plan tests can run without access to the public registry.
+//
+// Each provider version in the lock files is downloaded for each platform and checked against the
+// hashes in the lock file. Providers that are already in the mirror are not downloaded again.
+//
+//	terraform providers lock -platform=linux_amd64 -platform=darwin_arm64
+//	go run ./cmd/providermirror -lock ../.terraform.lock.hcl -dir ~/.terraform.d/mirror
+//	export TF_PROVIDER_MIRROR=~/.terraform.d/mirror
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// stringsFlag is a flag that can be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func main() {
+	var locks, platforms stringsFlag
+	flag.Var(&locks, "lock", "dependency lock file listing the provider versions to mirror, may be repeated")
+	flag.Var(&platforms, "platform", "platform to mirror the providers for, e.g. linux_amd64, may be repeated (default the current platform)")
+	dir := flag.String("dir", "", "directory of the filesystem mirror, set TF_PROVIDER_MIRROR to this when running the tests")
+	timeout := flag.Duration("timeout", 30*time.Minute, "timeout for populating the mirror")
+	flag.Parse()
+
+	if len(locks) == 0 || *dir == "" {
+		flag.Usage()
+		log.Fatal("-lock and -dir are required")
+	}
+	if len(platforms) == 0 {
+		platforms = stringsFlag{runtime.GOOS + "_" + runtime.GOARCH}
+	}
+
+	providers := make([]lockedProvider, 0)
+	for _, fn := range locks {
+		p, err := readLockFile(fn)
+		if err != nil {
+			log.Fatal(err)
+		}
+		providers = append(providers, p...)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	m := &mirror{
+		dir:    *dir,
+		client: http.DefaultClient,
+		logf:   log.Printf,
+	}
+	if err := m.populate(ctx, providers, platforms); err != nil {
+		log.Fatalf("cannot populate provider mirror: %v", err)
+	}
+	fmt.Printf("export TF_PROVIDER_MIRROR=%q\n", *dir)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// defaultRegistryHost is the registry host of providers whose source address has no hostname.
+const defaultRegistryHost = "registry.terraform.io"
+
+// lockFile is the part of a dependency lock file used to populate the mirror.
+type lockFile struct {
+	Providers []lockedProvider `hcl:"provider,block"`
+}
+
+// lockedProvider is a provider block of a dependency lock file.
+type lockedProvider struct {
+	Source      string   `hcl:"source,label"`
+	Version     string   `hcl:"version"`
+	Constraints *string  `hcl:"constraints,optional"`
+	Hashes      []string `hcl:"hashes,optional"`
+}
+
+// address returns the hostname, namespace and type of the provider source address.
+func (p lockedProvider) address() (host, namespace, typ string, err error) {
+	parts := strings.Split(strings.ToLower(p.Source), "/")
+	switch len(parts) {
+	case 2:
+		return defaultRegistryHost, parts[0], parts[1], nil
+	case 3:
+		return parts[0], parts[1], parts[2], nil
+	}
+	return "", "", "", fmt.Errorf("invalid provider source address %q", p.Source)
+}
+
+// zipHashes returns the SHA256 hashes of the provider packages from the zh: hashes in the lock file.
+func (p lockedProvider) zipHashes() map[string]bool {
+	out := make(map[string]bool)
+	for _, h := range p.Hashes {
+		if v, ok := strings.CutPrefix(h, "zh:"); ok {
+			out[strings.ToLower(v)] = true
+		}
+	}
+	return out
+}
+
+// readLockFile returns the providers in a dependency lock file, e.g. .terraform.lock.hcl.
+func readLockFile(fn string) ([]lockedProvider, error) {
+	f, diags := hclparse.NewParser().ParseHCLFile(fn)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("cannot parse lock file %s: %s", fn, diags.Error())
+	}
+	var lf lockFile
+	if diags := gohcl.DecodeBody(f.Body, nil, &lf); diags.HasErrors() {
+		return nil, fmt.Errorf("cannot read lock file %s: %s", fn, diags.Error())
+	}
+	return lf.Providers, nil
+}
+
+// mirror populates a provider filesystem mirror, using the packed layout:
+// HOSTNAME/NAMESPACE/TYPE/terraform-provider-TYPE_VERSION_TARGET.zip
+type mirror struct {
+	dir    string
+	client *http.Client
+	logf   func(format string, args ...any)
+}
+
+// downloadResponse is the part of the provider registry download response used to fetch the package.
+type downloadResponse struct {
+	Filename    string `json:"filename"`
+	DownloadURL string `json:"download_url"`
+	Shasum      string `json:"shasum"`
+}
+
+// populate downloads each provider version for each platform, skipping packages already in the mirror.
+func (m *mirror) populate(ctx context.Context, providers []lockedProvider, platforms []string) error {
+	// Remove duplicates, as the same provider version may be in several lock files.
+	seen := make(map[string]lockedProvider)
+	for _, p := range providers {
+		key := strings.ToLower(p.Source) + " " + p.Version
+		if prev, ok := seen[key]; ok {
+			p.Hashes = append(prev.Hashes, p.Hashes...)
+		}
+		seen[key] = p
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	discovered := make(map[string]*url.URL)
+	for _, k := range keys {
+		p := seen[k]
+		host, namespace, typ, err := p.address()
+		if err != nil {
+			return err
+		}
+		base, ok := discovered[host]
+		if !ok {
+			if base, err = m.discover(ctx, host); err != nil {
+				return err
+			}
+			discovered[host] = base
+		}
+		for _, platform := range platforms {
+			if err := m.download(ctx, base, p, host, namespace, typ, platform); err != nil {
+				return fmt.Errorf("%s %s %s: %v", p.Source, p.Version, platform, err)
+			}
+		}
+	}
+	return nil
+}
+
+// discover returns the base URL of the providers API of the registry host, using the service discovery protocol.
+func (m *mirror) discover(ctx context.Context, host string) (*url.URL, error) {
+	wellKnown := &url.URL{Scheme: "https", Host: host, Path: "/.well-known/terraform.json"}
+	var services map[string]any
+	if err := m.getJSON(ctx, wellKnown.String(), &services); err != nil {
+		return nil, fmt.Errorf("cannot discover services of registry %s: %v", host, err)
+	}
+	v, ok := services["providers.v1"].(string)
+	if !ok {
+		return nil, fmt.Errorf("registry %s does not support the providers.v1 protocol", host)
+	}
+	ref, err := url.Parse(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid providers.v1 URL of registry %s: %v", host, err)
+	}
+	return wellKnown.ResolveReference(ref), nil
+}
+
+// download fetches the provider package for the platform into the mirror, verifying it against the
+// checksum returned by the registry and the hashes in the lock file.
+func (m *mirror) download(ctx context.Context, base *url.URL, p lockedProvider, host, namespace, typ, platform string) error {
+	goos, goarch, ok := strings.Cut(platform, "_")
+	if !ok {
+		return fmt.Errorf("invalid platform %q, must be of the form os_arch", platform)
+	}
+	fn := filepath.Join(m.dir, host, namespace, typ, fmt.Sprintf("terraform-provider-%s_%s_%s.zip", typ, p.Version, platform))
+	zh := p.zipHashes()
+
+	if sum, err := fileSHA256(fn); err == nil && (len(zh) == 0 || zh[sum]) {
+		m.logf("%s %s %s: already mirrored", p.Source, p.Version, platform)
+		return nil
+	}
+
+	ref := &url.URL{Path: strings.Join([]string{namespace, typ, p.Version, "download", goos, goarch}, "/")}
+	var dl downloadResponse
+	if err := m.getJSON(ctx, base.ResolveReference(ref).String(), &dl); err != nil {
+		return err
+	}
+	pkg, err := url.Parse(dl.DownloadURL)
+	if err != nil {
+		return fmt.Errorf("invalid download URL: %v", err)
+	}
+	sum, err := m.fetch(ctx, base.ResolveReference(pkg).String(), fn)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !strings.EqualFold(sum, dl.Shasum):
+		err = fmt.Errorf("checksum %s of downloaded package does not match registry checksum %s", sum, dl.Shasum)
+	case len(zh) > 0 && !zh[sum]:
+		err = fmt.Errorf("checksum %s of downloaded package is not in the lock file hashes", sum)
+	}
+	if err != nil {
+		_ = removeFile(fn)
+		return err
+	}
+	m.logf("%s %s %s: mirrored %s", p.Source, p.Version, platform, fn)
+	return nil
+}
+
+// getJSON decodes the JSON response of a GET request.
+func (m *mirror) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// fetch downloads the URL to the file, via a temporary file so that an interrupted download
+// is not mistaken for a mirrored package, and returns the SHA256 of the content.
+func (m *mirror) fetch(ctx context.Context, u, fn string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: unexpected status %s", u, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fn), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer removeFile(tmp.Name())
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("cannot download %s: %v", u, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), fn); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileSHA256 returns the SHA256 of the file content.
+func fileSHA256(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// removeFile removes the file, ignoring a file that does not exist.
+func removeFile(fn string) error {
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRegistry returns a provider registry serving a single package for every provider version and platform,
+// and a counter of the packages downloaded.
+func newRegistry(t *testing.T, pkg []byte) (*httptest.Server, *atomic.Int32) {
+	sum := sha256.Sum256(pkg)
+	downloads := new(atomic.Int32)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"providers.v1": "/v1/providers/"})
+	})
+	mux.HandleFunc("/v1/providers/", func(w http.ResponseWriter, r *http.Request) {
+		// /v1/providers/{namespace}/{type}/{version}/download/{os}/{arch}
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/providers/"), "/")
+		if len(parts) != 6 || parts[3] != "download" {
+			http.NotFound(w, r)
+			return
+		}
+		fn := fmt.Sprintf("terraform-provider-%s_%s_%s_%s.zip", parts[1], parts[2], parts[4], parts[5])
+		_ = json.NewEncoder(w).Encode(downloadResponse{
+			Filename:    fn,
+			DownloadURL: "/files/" + fn,
+			Shasum:      hex.EncodeToString(sum[:]),
+		})
+	})
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write(pkg)
+	})
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, downloads
+}
+
+func writeLockFile(t *testing.T, host string, hashes ...string) string {
+	quoted := make([]string, len(hashes))
+	for i, h := range hashes {
+		quoted[i] = fmt.Sprintf("%q", h)
+	}
+	lock := fmt.Sprintf(`# This file is maintained automatically by "terraform init".
+provider "%[1]s/azure/azapi" {
+  version     = "1.13.1"
+  constraints = "~> 1.4"
+  hashes = [%[2]s]
+}
+
+provider "%[1]s/hashicorp/azurerm" {
+  version = "3.107.0"
+  hashes = [%[2]s]
+}
+`, host, strings.Join(quoted, ", "))
+	fn := filepath.Join(t.TempDir(), ".terraform.lock.hcl")
+	require.NoError(t, os.WriteFile(fn, []byte(lock), 0644))
+	return fn
+}
+
+func TestPopulate(t *testing.T) {
+	t.Parallel()
+
+	pkg := []byte("provider package")
+	sum := sha256.Sum256(pkg)
+	srv, downloads := newRegistry(t, pkg)
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	providers, err := readLockFile(writeLockFile(t, host, "h1:ignored=", "zh:"+hex.EncodeToString(sum[:])))
+	require.NoError(t, err)
+	require.Len(t, providers, 2)
+	assert.Equal(t, "1.13.1", providers[0].Version)
+
+	m := &mirror{dir: t.TempDir(), client: srv.Client(), logf: t.Logf}
+	platforms := []string{"linux_amd64", "darwin_arm64"}
+	require.NoError(t, m.populate(context.Background(), providers, platforms))
+	assert.Equal(t, int32(4), downloads.Load())
+
+	for _, fn := range []string{
+		filepath.Join(host, "azure", "azapi", "terraform-provider-azapi_1.13.1_linux_amd64.zip"),
+		filepath.Join(host, "azure", "azapi", "terraform-provider-azapi_1.13.1_darwin_arm64.zip"),
+		filepath.Join(host, "hashicorp", "azurerm", "terraform-provider-azurerm_3.107.0_linux_amd64.zip"),
+		filepath.Join(host, "hashicorp", "azurerm", "terraform-provider-azurerm_3.107.0_darwin_arm64.zip"),
+	} {
+		b, err := os.ReadFile(filepath.Join(m.dir, fn))
+		require.NoError(t, err)
+		assert.Equal(t, pkg, b)
+	}
+
+	// Packages already in the mirror are not downloaded again.
+	require.NoError(t, m.populate(context.Background(), providers, platforms))
+	assert.Equal(t, int32(4), downloads.Load())
+}
+
+func TestPopulateHashMismatch(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newRegistry(t, []byte("tampered package"))
+	host := strings.TrimPrefix(srv.URL, "https://")
+	providers, err := readLockFile(writeLockFile(t, host, "zh:0000000000000000000000000000000000000000000000000000000000000000"))
+	require.NoError(t, err)
+
+	m := &mirror{dir: t.TempDir(), client: srv.Client(), logf: t.Logf}
+	err = m.populate(context.Background(), providers, []string{"linux_amd64"})
+	assert.ErrorContains(t, err, "is not in the lock file hashes")
+	_, err = os.Stat(filepath.Join(m.dir, host, "azure", "azapi", "terraform-provider-azapi_1.13.1_linux_amd64.zip"))
+	assert.True(t, os.IsNotExist(err), "package with an unexpected hash should be removed")
+}
@@ -0,0 +1,105 @@
+// Command sweeper cancels subscriptions leaked by the deployment tests.
+//
+// The deployment tests create subscriptions named testdeploy-<hex> and cancel them when the test completes.
+// If a test panics or times out the subscription is left behind.
+// The sweeper finds these subscriptions, deletes their resource groups and cancels them.
+// Subscriptions tagged with the provenance of the test that created them are cancelled once they have expired,
+// others once they are older than the minimum age.
+// With -resource-groups, the expired tagged resource groups in the subscription in AZURE_SUBSCRIPTION_ID are also deleted.
+//
+// Authentication uses the same environment variables as the tests, see azureutils.
+// An interrupt aborts the calls in flight, except the cleanup of a subscription that has started,
+// and the subscriptions not yet swept are reported as failed.
+//
+//	go run ./cmd/sweeper -min-age 6h -dry-run
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run sweeps the subscriptions and writes the report, and returns the exit code.
+// It returns rather than exiting so that the report file is closed before the process exits.
+func run() int {
+	pattern := flag.String("pattern", `^testdeploy-[0-9a-f]+$`, "regular expression matching the display name of subscriptions to cancel")
+	minAge := flag.Duration("min-age", 6*time.Hour, "only cancel subscriptions older than this, so that running tests are not affected")
+	dryRun := flag.Bool("dry-run", false, "report the subscriptions that would be cancelled without making any changes")
+	reportFile := flag.String("report", "", "write the JSON report to this file instead of stdout")
+	resourceGroups := flag.Bool("resource-groups", false, "also delete the expired tagged resource groups in the subscription in AZURE_SUBSCRIPTION_ID")
+	rgPattern := flag.String("resource-group-pattern", `^(testdeploy-[0-9a-f]+(-2)?|rg-umi-[0-9a-f]+)$`, "regular expression matching the name of resource groups to delete")
+	flag.Parse()
+
+	re, err := regexp.Compile(*pattern)
+	if err != nil {
+		log.Printf("invalid pattern: %v", err)
+		return 2
+	}
+	rgRe, err := regexp.Compile(*rgPattern)
+	if err != nil {
+		log.Printf("invalid resource group pattern: %v", err)
+		return 2
+	}
+	var rgSub *uuid.UUID
+	if *resourceGroups {
+		id, err := uuid.Parse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+		if err != nil {
+			log.Printf("cannot parse AZURE_SUBSCRIPTION_ID: %v", err)
+			return 2
+		}
+		rgSub = &id
+	}
+
+	s := &sweeper{
+		pattern: re,
+		minAge:  *minAge,
+		dryRun:  *dryRun,
+		now:     time.Now,
+		t:       logT{},
+
+		resourceGroupsSubscription: rgSub,
+		resourceGroupPattern:       rgRe,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	rpt, err := s.sweep(ctx)
+	if err != nil {
+		log.Printf("cannot sweep subscriptions: %v", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if *reportFile != "" {
+		f, err := os.Create(*reportFile)
+		if err != nil {
+			log.Printf("cannot create report file: %v", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(rpt); err != nil {
+		log.Printf("cannot write report: %v", err)
+		return 1
+	}
+
+	if rpt.failed() {
+		log.Print("one or more subscriptions could not be cancelled, or resource groups deleted")
+		return 1
+	}
+	return 0
+}
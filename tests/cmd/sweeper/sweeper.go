@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/google/uuid"
+)
+
+const (
+	actionCancelled   = "cancelled"
+	actionWouldCancel = "wouldCancel"
+	actionDeleted     = "deleted"
+	actionWouldDelete = "wouldDelete"
+	actionSkipped     = "skipped"
+	actionFailed      = "failed"
+)
+
+// sweeper finds and cancels subscriptions left behind by the deployment tests, those whose display name matches the pattern.
+// Subscriptions with the provenance tags of the tests, see azureutils.ParseProvenance, are cancelled once they have expired,
+// whatever their age. Subscriptions without an expiry tag, created before the tests were tagged, are cancelled once older than the minimum age.
+type sweeper struct {
+	// pattern matches the display names of the subscriptions created by the deployment tests.
+	// It is required of tagged subscriptions too, so that a subscription with a copied or mistyped tag is not cancelled.
+	pattern *regexp.Regexp
+
+	// minAge is the minimum age of an untagged subscription before it is cancelled,
+	// so that subscriptions belonging to running tests are left alone.
+	minAge time.Duration
+
+	// resourceGroupsSubscription is the subscription whose expired resource groups with provenance tags are deleted,
+	// the deployment tests that do not vend a subscription create their resource groups there.
+	// If it is nil, resource groups are not swept.
+	resourceGroupsSubscription *uuid.UUID
+
+	// resourceGroupPattern matches the names of the resource groups created by the deployment tests.
+	// As with subscriptions, it is required of tagged resource groups, as the subscription is shared.
+	resourceGroupPattern *regexp.Regexp
+
+	// dryRun reports what would be cancelled without making any changes.
+	dryRun bool
+
+	// now returns the current time, it is overridden in tests.
+	now func() time.Time
+
+	// t is used for logging by the azureutils helpers.
+	t azureutils.TestingT
+}
+
+// report is the JSON document written by the sweeper.
+type report struct {
+	StartTime     time.Time            `json:"startTime"`
+	DryRun        bool                 `json:"dryRun"`
+	Pattern       string               `json:"pattern"`
+	MinAge        string               `json:"minAge"`
+	Subscriptions []subscriptionResult `json:"subscriptions"`
+
+	// ResourceGroupPattern and ResourceGroups are the pattern and the matching tagged resource groups
+	// in the resource groups subscription, if swept.
+	ResourceGroupPattern string                `json:"resourceGroupPattern,omitempty"`
+	ResourceGroups       []resourceGroupResult `json:"resourceGroups,omitempty"`
+
+	// Throttling counts the requests throttled by Azure Resource Manager during the sweep.
+	Throttling *azureutils.ThrottleStats `json:"throttling,omitempty"`
+}
+
+// subscriptionResult is the outcome for a single subscription that matched the pattern.
+type subscriptionResult struct {
+	ID          string     `json:"id"`
+	DisplayName string     `json:"displayName"`
+	State       string     `json:"state"`
+	CreatedTime *time.Time `json:"createdTime,omitempty"`
+	provenanceResult
+	Action         string   `json:"action"`
+	Reason         string   `json:"reason,omitempty"`
+	ResourceGroups []string `json:"resourceGroups,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// resourceGroupResult is the outcome for a single resource group with provenance tags.
+type resourceGroupResult struct {
+	Name string `json:"name"`
+	provenanceResult
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// provenanceResult is the test and run that created a resource, from its provenance tags.
+type provenanceResult struct {
+	Test    string     `json:"test,omitempty"`
+	RunID   string     `json:"runId,omitempty"`
+	Commit  string     `json:"commit,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// newProvenanceResult returns the provenance of a resource from its tags.
+func newProvenanceResult(tags map[string]string) provenanceResult {
+	p, ok := azureutils.ParseProvenance(tags)
+	if !ok {
+		return provenanceResult{}
+	}
+	res := provenanceResult{Test: p.Test, RunID: p.RunID, Commit: p.Commit}
+	if !p.Expires.IsZero() {
+		res.Expires = &p.Expires
+	}
+	return res
+}
+
+// failed returns true if any subscription could not be cancelled, or resource group deleted.
+func (r report) failed() bool {
+	for _, s := range r.Subscriptions {
+		if s.Action == actionFailed {
+			return true
+		}
+	}
+	for _, rg := range r.ResourceGroups {
+		if rg.Action == actionFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep cancels the subscriptions whose display name matches the pattern and that have expired, if they have provenance tags,
+// or else that are older than the minimum age.
+// It then deletes the expired resource groups in the resource groups subscription, if set.
+// An error is only returned if the subscriptions cannot be enumerated,
+// failures for individual subscriptions are recorded in the report.
+func (s *sweeper) sweep(ctx context.Context) (report, error) {
+	rpt := report{
+		StartTime:     s.now().UTC(),
+		DryRun:        s.dryRun,
+		Pattern:       s.pattern.String(),
+		MinAge:        s.minAge.String(),
+		Subscriptions: make([]subscriptionResult, 0),
+	}
+
+	subs, err := azureutils.ListSubscriptions(ctx)
+	if err != nil {
+		return rpt, err
+	}
+	created, err := s.creationTimes(ctx)
+	if err != nil {
+		return rpt, err
+	}
+	tagged, err := azureutils.ListSubscriptionsByTag(ctx, azureutils.ProvenanceFilter())
+	if err != nil {
+		return rpt, err
+	}
+	provenance := make(map[string]provenanceResult, len(tagged))
+	for _, sub := range tagged {
+		provenance[strings.ToLower(sub.ID)] = newProvenanceResult(sub.Tags)
+	}
+
+	for _, sub := range subs {
+		if sub.DisplayName == nil || sub.SubscriptionID == nil || !s.pattern.MatchString(*sub.DisplayName) {
+			continue
+		}
+		res := subscriptionResult{
+			ID:               *sub.SubscriptionID,
+			DisplayName:      *sub.DisplayName,
+			provenanceResult: provenance[strings.ToLower(*sub.SubscriptionID)],
+		}
+		if sub.State != nil {
+			res.State = string(*sub.State)
+		}
+		if ct, ok := created[strings.ToLower(res.ID)]; ok {
+			res.CreatedTime = &ct
+		}
+		s.sweepSubscription(ctx, &res)
+		rpt.Subscriptions = append(rpt.Subscriptions, res)
+	}
+
+	sort.Slice(rpt.Subscriptions, func(i, j int) bool {
+		return rpt.Subscriptions[i].DisplayName < rpt.Subscriptions[j].DisplayName
+	})
+
+	if s.resourceGroupsSubscription != nil {
+		rpt.ResourceGroupPattern = s.resourceGroupPattern.String()
+		if rpt.ResourceGroups, err = s.sweepResourceGroups(ctx, *s.resourceGroupsSubscription); err != nil {
+			return rpt, err
+		}
+	}
+	if stats, err := azureutils.Throttling(); err == nil {
+		rpt.Throttling = &stats
+	}
+	return rpt, nil
+}
+
+// sweepSubscription decides whether the subscription should be cancelled and, unless in dry-run mode,
+// deletes its resource groups and cancels it.
+func (s *sweeper) sweepSubscription(ctx context.Context, res *subscriptionResult) {
+	switch {
+	case res.State == "Warned" || res.State == "Disabled" || res.State == "Deleted":
+		res.Action = actionSkipped
+		res.Reason = "subscription is already cancelled"
+		return
+	case res.Expires != nil && !s.now().After(*res.Expires):
+		res.Action = actionSkipped
+		res.Reason = fmt.Sprintf("subscription expires at %s", res.Expires.Format(time.RFC3339))
+		return
+	case res.Expires != nil:
+		// tagged and expired, whatever its age
+	case res.CreatedTime == nil:
+		res.Action = actionSkipped
+		res.Reason = "creation time is unknown, no subscription alias found"
+		return
+	case s.now().Sub(*res.CreatedTime) < s.minAge:
+		res.Action = actionSkipped
+		res.Reason = fmt.Sprintf("subscription is younger than %s", s.minAge)
+		return
+	}
+
+	id, err := uuid.Parse(res.ID)
+	if err != nil {
+		res.Action = actionFailed
+		res.Error = fmt.Sprintf("cannot parse subscription id, %v", err)
+		return
+	}
+
+	rgs, err := azureutils.ListResourceGroup(ctx, id)
+	if err != nil {
+		res.Action = actionFailed
+		res.Error = fmt.Sprintf("cannot list resource groups, %v", err)
+		return
+	}
+	for _, rg := range rgs {
+		res.ResourceGroups = append(res.ResourceGroups, *rg.Name)
+	}
+	sort.Strings(res.ResourceGroups)
+
+	if s.dryRun {
+		res.Action = actionWouldCancel
+		return
+	}
+
+	if err := azureutils.CancelSubscription(ctx, s.t, &id); err != nil {
+		res.Action = actionFailed
+		res.Error = err.Error()
+		return
+	}
+	res.Action = actionCancelled
+}
+
+// sweepResourceGroups deletes the resource groups in the subscription whose name matches the resource group pattern
+// and whose provenance tags have expired, unless in dry-run mode.
+// They are deleted with azureutils.RemoveResourceGroups, so that the locks created by the submodules are removed first.
+// An error is only returned if the resource groups cannot be enumerated.
+func (s *sweeper) sweepResourceGroups(ctx context.Context, subId uuid.UUID) ([]resourceGroupResult, error) {
+	rgs, err := azureutils.ListResourceGroupsByTag(ctx, subId, azureutils.ProvenanceFilter())
+	if err != nil {
+		return nil, err
+	}
+	results := make([]resourceGroupResult, 0, len(rgs))
+	var expired []string
+	for _, rg := range rgs {
+		if rg.Name == nil || !s.resourceGroupPattern.MatchString(*rg.Name) {
+			continue
+		}
+		res := resourceGroupResult{
+			Name:             *rg.Name,
+			provenanceResult: newProvenanceResult(azureutils.ResourceTags(rg.Tags)),
+		}
+		switch {
+		case res.Expires == nil:
+			res.Action = actionSkipped
+			res.Reason = "expiry time is unknown"
+		case !s.now().After(*res.Expires):
+			res.Action = actionSkipped
+			res.Reason = fmt.Sprintf("resource group expires at %s", res.Expires.Format(time.RFC3339))
+		case s.dryRun:
+			res.Action = actionWouldDelete
+		default:
+			expired = append(expired, res.Name)
+		}
+		results = append(results, res)
+	}
+
+	if len(expired) > 0 {
+		teardown, err := azureutils.RemoveResourceGroups(ctx, s.t, subId, &azureutils.TeardownOptions{ResourceGroups: expired})
+		// The report is nil if the default factory cannot be created.
+		deleted := make(map[string]bool, len(expired))
+		if teardown != nil {
+			for _, name := range teardown.Deleted {
+				deleted[strings.ToLower(name)] = true
+			}
+		}
+		for i := range results {
+			res := &results[i]
+			if res.Action != "" {
+				continue
+			}
+			switch {
+			case deleted[strings.ToLower(res.Name)]:
+				res.Action = actionDeleted
+			case err != nil:
+				res.Action = actionFailed
+				res.Error = err.Error()
+			default:
+				res.Action = actionFailed
+				res.Error = "resource group was not deleted"
+			}
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
+
+// creationTimes returns the creation time of each subscription that was created via an alias, keyed by lower case subscription id.
+// Subscriptions do not expose their creation time, so the alias is used instead.
+func (s *sweeper) creationTimes(ctx context.Context) (map[string]time.Time, error) {
+	aliases, err := azureutils.ListSubscriptionAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	created := make(map[string]time.Time, len(aliases))
+	for _, a := range aliases {
+		if a.Properties == nil || a.Properties.SubscriptionID == nil || a.Properties.CreatedTime == nil {
+			continue
+		}
+		ct, err := time.Parse(time.RFC3339, *a.Properties.CreatedTime)
+		if err != nil {
+			s.t.Logf("cannot parse created time %q of subscription %s, %v", *a.Properties.CreatedTime, *a.Properties.SubscriptionID, err)
+			continue
+		}
+		created[strings.ToLower(*a.Properties.SubscriptionID)] = ct
+	}
+	return created, nil
+}
+
+// logT implements azureutils.TestingT by writing to the standard logger.
+type logT struct{}
+
+var _ azureutils.TestingT = logT{}
+
+func (logT) Fail()                             {}
+func (logT) FailNow()                          { log.Fatal("sweeper failed") }
+func (logT) Fatal(args ...any)                 { log.Fatal(args...) }
+func (logT) Fatalf(format string, args ...any) { log.Fatalf(format, args...) }
+func (logT) Error(args ...any)                 { log.Print(args...) }
+func (logT) Errorf(format string, args ...any) { log.Printf(format, args...) }
+func (logT) Logf(format string, args ...any)   { log.Printf(format, args...) }
+func (logT) Name() string                      { return "sweeper" }
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweep(t *testing.T) {
+	srv := armfake.NewServer(t)
+	srv.Setenv(t)
+	now := time.Now()
+
+	ids := make(map[string]string)
+	for name, age := range map[string]time.Duration{
+		"testdeploy-0000000a": 48 * time.Hour,
+		"testdeploy-0000000b": 10 * time.Minute,
+		"production":          48 * time.Hour,
+	} {
+		sub := srv.AddSubscriptionAlias(name)
+		srv.SetSubscriptionCreatedTime(sub.ID, now.Add(-age))
+		ids[name] = sub.ID
+	}
+	ids["testdeploy-0000000c"] = srv.AddSubscription("testdeploy-0000000c").ID
+	srv.PutResource("/subscriptions/"+ids["testdeploy-0000000a"]+"/resourceGroups/rg1", map[string]any{"location": "westeurope"})
+	srv.PutResource("/subscriptions/"+ids["testdeploy-0000000a"]+"/resourceGroups/rg2", map[string]any{"location": "westeurope"})
+
+	s := &sweeper{
+		pattern: regexp.MustCompile(`^testdeploy-[0-9a-f]+$`),
+		minAge:  6 * time.Hour,
+		dryRun:  true,
+		now:     func() time.Time { return now },
+		t:       t,
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		rpt, err := s.sweep(context.Background())
+		require.NoError(t, err)
+		require.Len(t, rpt.Subscriptions, 3)
+		assert.False(t, rpt.failed())
+		require.NotNil(t, rpt.Throttling)
+		assert.Positive(t, rpt.Throttling.Read.Requests)
+		assert.Zero(t, rpt.Throttling.Total().Throttled)
+
+		a := rpt.Subscriptions[0]
+		assert.Equal(t, ids["testdeploy-0000000a"], a.ID)
+		assert.Equal(t, actionWouldCancel, a.Action)
+		assert.Equal(t, []string{"rg1", "rg2"}, a.ResourceGroups)
+
+		b := rpt.Subscriptions[1]
+		assert.Equal(t, actionSkipped, b.Action)
+		assert.Contains(t, b.Reason, "younger than")
+
+		c := rpt.Subscriptions[2]
+		assert.Equal(t, actionSkipped, c.Action)
+		assert.Contains(t, c.Reason, "creation time is unknown")
+
+		sub, _ := srv.Subscription(ids["testdeploy-0000000a"])
+		assert.Equal(t, "Enabled", sub.State)
+		assert.Len(t, srv.ResourceIDs("/subscriptions/"+ids["testdeploy-0000000a"]+"/resourceGroups"), 2)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		s.dryRun = false
+		rpt, err := s.sweep(context.Background())
+		require.NoError(t, err)
+		require.Len(t, rpt.Subscriptions, 3)
+		assert.Equal(t, actionCancelled, rpt.Subscriptions[0].Action)
+
+		sub, _ := srv.Subscription(ids["testdeploy-0000000a"])
+		assert.Equal(t, "Warned", sub.State)
+		assert.Empty(t, srv.ResourceIDs("/subscriptions/"+ids["testdeploy-0000000a"]+"/resourceGroups"))
+		for _, name := range []string{"testdeploy-0000000b", "testdeploy-0000000c", "production"} {
+			sub, _ := srv.Subscription(ids[name])
+			assert.Equal(t, "Enabled", sub.State, name)
+		}
+	})
+
+	t.Run("AlreadyCancelled", func(t *testing.T) {
+		rpt, err := s.sweep(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, actionSkipped, rpt.Subscriptions[0].Action)
+		assert.Equal(t, "Warned", rpt.Subscriptions[0].State)
+	})
+}
+
+func TestSweepTagged(t *testing.T) {
+	srv := armfake.NewServer(t)
+	srv.Setenv(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	tags := func(test string, expires time.Time) map[string]string {
+		return azureutils.Provenance{Test: test, RunID: "42-1", Commit: "abc", Created: now.Add(-time.Hour), Expires: expires}.Tags()
+	}
+
+	expired := srv.AddSubscriptionAlias("testdeploy-0000000b")
+	srv.SetSubscriptionCreatedTime(expired.ID, now.Add(-time.Hour))
+	srv.SetSubscriptionTags(expired.ID, tags("TestDeployExpired", now.Add(-time.Minute)))
+	running := srv.AddSubscriptionAlias("testdeploy-0000000a")
+	srv.SetSubscriptionCreatedTime(running.ID, now.Add(-48*time.Hour))
+	srv.SetSubscriptionTags(running.ID, tags("TestDeployRunning", now.Add(time.Hour)))
+	production := srv.AddSubscriptionAlias("production")
+	srv.SetSubscriptionCreatedTime(production.ID, now.Add(-48*time.Hour))
+	srv.SetSubscriptionTags(production.ID, tags("TestDeployCopied", now.Add(-time.Minute)))
+
+	rgSub := srv.AddSubscription("rg-sub")
+	rgs := "/subscriptions/" + rgSub.ID + "/resourceGroups/"
+	srv.PutResource(rgs+"testdeploy-0000000d", map[string]any{"location": "westeurope", "tags": tags("TestDeployRg", now.Add(-time.Minute))})
+	srv.PutResource(rgs+"testdeploy-0000000d/providers/Microsoft.Authorization/locks/lock-testdeploy-0000000d", map[string]any{"properties": map[string]any{"level": "CanNotDelete"}})
+	srv.PutResource(rgs+"testdeploy-0000000e", map[string]any{"location": "westeurope", "tags": tags("TestDeployRg", now.Add(time.Hour))})
+	srv.PutResource(rgs+"testdeploy-0000000f", map[string]any{"location": "westeurope"})
+	srv.PutResource(rgs+"production", map[string]any{"location": "westeurope", "tags": tags("TestDeployCopied", now.Add(-time.Minute))})
+	rgSubID := uuid.MustParse(rgSub.ID)
+
+	s := &sweeper{
+		pattern: regexp.MustCompile(`^testdeploy-[0-9a-f]+$`),
+		minAge:  6 * time.Hour,
+		now:     func() time.Time { return now },
+		t:       t,
+
+		resourceGroupsSubscription: &rgSubID,
+		resourceGroupPattern:       regexp.MustCompile(`^testdeploy-[0-9a-f]+$`),
+	}
+	rpt, err := s.sweep(context.Background())
+	require.NoError(t, err)
+	assert.False(t, rpt.failed())
+
+	require.Len(t, rpt.Subscriptions, 2)
+	a := rpt.Subscriptions[0]
+	assert.Equal(t, running.ID, a.ID)
+	assert.Equal(t, actionSkipped, a.Action, "tagged subscriptions are kept until they expire whatever their age")
+	assert.Contains(t, a.Reason, "expires at")
+	b := rpt.Subscriptions[1]
+	assert.Equal(t, expired.ID, b.ID)
+	assert.Equal(t, actionCancelled, b.Action, "tagged subscriptions are cancelled once expired whatever their age")
+	assert.Equal(t, "TestDeployExpired", b.Test)
+	assert.Equal(t, "42-1", b.RunID)
+
+	sub, _ := srv.Subscription(expired.ID)
+	assert.Equal(t, "Warned", sub.State)
+	sub, _ = srv.Subscription(running.ID)
+	assert.Equal(t, "Enabled", sub.State)
+	sub, _ = srv.Subscription(production.ID)
+	assert.Equal(t, "Enabled", sub.State, "tagged subscriptions must match the pattern")
+
+	assert.Equal(t, "^testdeploy-[0-9a-f]+$", rpt.ResourceGroupPattern)
+	require.Len(t, rpt.ResourceGroups, 2)
+	assert.Equal(t, "testdeploy-0000000d", rpt.ResourceGroups[0].Name)
+	assert.Equal(t, actionDeleted, rpt.ResourceGroups[0].Action, "the lock created by the submodules is removed first")
+	assert.Equal(t, "testdeploy-0000000e", rpt.ResourceGroups[1].Name)
+	assert.Equal(t, actionSkipped, rpt.ResourceGroups[1].Action)
+	assert.ElementsMatch(t, []string{rgs + "testdeploy-0000000e", rgs + "testdeploy-0000000f", rgs + "production"}, srv.ResourceIDs(rgs),
+		"tagged resource groups must match the pattern")
+}
+
+func TestReportJSON(t *testing.T) {
+	t.Parallel()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rpt := report{
+		Subscriptions: []subscriptionResult{
+			{ID: "id", DisplayName: "testdeploy-00", State: "Enabled", CreatedTime: &created, Action: actionFailed, Error: "boom"},
+		},
+	}
+	assert.True(t, rpt.failed())
+
+	b, err := json.Marshal(rpt)
+	require.NoError(t, err)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(b, &got))
+	sub := got["subscriptions"].([]any)[0].(map[string]any)
+	assert.Equal(t, "failed", sub["action"])
+	assert.Equal(t, "2024-01-02T03:04:05Z", sub["createdTime"])
+	assert.NotContains(t, sub, "resourceGroups")
+}
@@ -0,0 +1,114 @@
+package inputs
+
+import "reflect"
+
+// Budget mirrors an element of the budgets variable.
+type Budget struct {
+	Amount          float64                        `tf:"amount"`
+	TimeGrain       string                         `tf:"time_grain"`
+	TimePeriodStart string                         `tf:"time_period_start"`
+	TimePeriodEnd   string                         `tf:"time_period_end"`
+	RelativeScope   *string                        `tf:"relative_scope"`
+	Notifications   map[string]*BudgetNotification `tf:"notifications"`
+}
+
+// BudgetNotification mirrors an element of the notifications attribute of a budget.
+type BudgetNotification struct {
+	Enabled       bool     `tf:"enabled"`
+	Operator      string   `tf:"operator"`
+	Threshold     float64  `tf:"threshold"`
+	ThresholdType *string  `tf:"threshold_type"`
+	ContactEmails []string `tf:"contact_emails"`
+	ContactRoles  []string `tf:"contact_roles"`
+	ContactGroups []string `tf:"contact_groups"`
+	Locale        *string  `tf:"locale"`
+}
+
+// NewBudget returns a budget of the supplied amount, time grain and time period.
+// The time period is in RFC3339 format.
+func NewBudget(amount float64, timeGrain, start, end string) *Budget {
+	return &Budget{
+		Amount:          amount,
+		TimeGrain:       timeGrain,
+		TimePeriodStart: start,
+		TimePeriodEnd:   end,
+	}
+}
+
+// WithRelativeScope sets the scope of the budget, relative to the subscription.
+func (b *Budget) WithRelativeScope(scope string) *Budget {
+	b.RelativeScope = &scope
+	return b
+}
+
+// WithNotification adds a notification to the budget with the supplied key.
+func (b *Budget) WithNotification(key string, n *BudgetNotification) *Budget {
+	if b.Notifications == nil {
+		b.Notifications = make(map[string]*BudgetNotification)
+	}
+	b.Notifications[key] = n
+	return b
+}
+
+// NewBudgetNotification returns an enabled notification for the supplied operator and threshold.
+func NewBudgetNotification(operator string, threshold float64) *BudgetNotification {
+	return &BudgetNotification{
+		Enabled:   true,
+		Operator:  operator,
+		Threshold: threshold,
+	}
+}
+
+// WithThresholdType sets the threshold type of the notification, Actual or Forecasted.
+func (n *BudgetNotification) WithThresholdType(thresholdType string) *BudgetNotification {
+	n.ThresholdType = &thresholdType
+	return n
+}
+
+// WithContactEmails sets the email addresses notified.
+func (n *BudgetNotification) WithContactEmails(emails ...string) *BudgetNotification {
+	n.ContactEmails = emails
+	return n
+}
+
+// WithContactRoles sets the roles notified.
+func (n *BudgetNotification) WithContactRoles(roles ...string) *BudgetNotification {
+	n.ContactRoles = roles
+	return n
+}
+
+// WithContactGroups sets the action groups notified.
+func (n *BudgetNotification) WithContactGroups(groups ...string) *BudgetNotification {
+	n.ContactGroups = groups
+	return n
+}
+
+// WithLocale sets the locale of the notification.
+func (n *BudgetNotification) WithLocale(locale string) *BudgetNotification {
+	n.Locale = &locale
+	return n
+}
+
+// ModuleVars returns the variables of the budget submodule for the budget with the supplied name,
+// as the root module sets them for a budget in the subscription.
+func (b *Budget) ModuleVars(name, subscriptionID string) map[string]any {
+	scope := "/subscriptions/" + subscriptionID
+	if b.RelativeScope != nil {
+		scope += *b.RelativeScope
+	}
+	notifications, ok := toValue(reflect.ValueOf(b.Notifications))
+	if !ok {
+		notifications = map[string]any{}
+	}
+	return map[string]any{
+		"budget_name":          name,
+		"budget_scope":         scope,
+		"budget_amount":        b.Amount,
+		"budget_time_grain":    b.TimeGrain,
+		"budget_notifications": notifications,
+		"budget_time_period": map[string]any{
+			"start_date": b.TimePeriodStart,
+			"end_date":   b.TimePeriodEnd,
+		},
+	}
+}
@@ -0,0 +1,181 @@
+package inputs
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// variablesSchema is the part of a Terraform file that contains variable blocks.
+var variablesSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+	},
+}
+
+// variableSchema is the part of a variable block that contains the type constraint.
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "type"},
+	},
+}
+
+// CheckVariables checks that every field of the supplied struct, e.g. Inputs, has a matching
+// variable block in the variables*.tf files of the module in dir.
+// Fields of nested object types are checked against the attributes of the variable type constraint,
+// so that a misspelled attribute is not silently ignored by Terraform.
+func CheckVariables(dir string, v any) error {
+	vars, err := ModuleVariables(dir)
+	if err != nil {
+		return err
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot check variables of %s, must be a struct", t)
+	}
+
+	errs := make([]error, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := tfName(f)
+		if name == "" {
+			continue
+		}
+		ty, ok := vars[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s.%s: no variable %q in %s", t.Name(), f.Name, name, filepath.Join(dir, "variables*.tf")))
+			continue
+		}
+		errs = append(errs, checkType(name, f.Type, ty)...)
+	}
+	return errors.Join(errs...)
+}
+
+// ModuleVariables returns the type constraint of each variable declared in the variables*.tf files in dir.
+// Variables without a type constraint have the type cty.DynamicPseudoType.
+func ModuleVariables(dir string) (map[string]cty.Type, error) {
+	return variables(dir, "variables*.tf")
+}
+
+// DeclaredVariables returns the type constraint of each variable declared in any of the .tf files in dir,
+// e.g. a test harness that declares its variables alongside its resources.
+func DeclaredVariables(dir string) (map[string]cty.Type, error) {
+	return variables(dir, "*.tf")
+}
+
+// variables returns the type constraint of each variable declared in the files matching the pattern in dir.
+func variables(dir, pattern string) (map[string]cty.Type, error) {
+	files, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files found in %s", pattern, dir)
+	}
+	sort.Strings(files)
+
+	parser := hclparse.NewParser()
+	vars := make(map[string]cty.Type)
+	for _, fn := range files {
+		f, diags := parser.ParseHCLFile(fn)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("cannot parse %s: %s", fn, diags.Error())
+		}
+		content, _, diags := f.Body.PartialContent(variablesSchema)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("cannot read %s: %s", fn, diags.Error())
+		}
+		for _, b := range content.Blocks {
+			vc, _, diags := b.Body.PartialContent(variableSchema)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("cannot read variable %q in %s: %s", b.Labels[0], fn, diags.Error())
+			}
+			ty := cty.DynamicPseudoType
+			if attr, ok := vc.Attributes["type"]; ok {
+				ty, _, diags = typeexpr.TypeConstraintWithDefaults(attr.Expr)
+				if diags.HasErrors() {
+					return nil, fmt.Errorf("cannot read type of variable %q in %s: %s", b.Labels[0], fn, diags.Error())
+				}
+			}
+			vars[b.Labels[0]] = ty
+		}
+	}
+	return vars, nil
+}
+
+// checkType checks that the Go type is compatible with the Terraform type constraint,
+// returning an error for each mismatch.
+func checkType(path string, t reflect.Type, ty cty.Type) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if ty == cty.DynamicPseudoType {
+		return nil
+	}
+
+	mismatch := func() []error {
+		return []error{fmt.Errorf("%s: Go type %s does not match Terraform type %s", path, t, typeexpr.TypeString(ty))}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if !ty.IsObjectType() {
+			return mismatch()
+		}
+		errs := make([]error, 0)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := tfName(f)
+			if name == "" {
+				continue
+			}
+			if !ty.HasAttribute(name) {
+				errs = append(errs, fmt.Errorf("%s: %s.%s has no matching attribute %q", path, t.Name(), f.Name, name))
+				continue
+			}
+			errs = append(errs, checkType(path+"."+name, f.Type, ty.AttributeType(name))...)
+		}
+		return errs
+
+	case reflect.Map:
+		if !ty.IsMapType() {
+			return mismatch()
+		}
+		return checkType(path+`["*"]`, t.Elem(), ty.ElementType())
+
+	case reflect.Slice:
+		if !ty.IsListType() && !ty.IsSetType() {
+			return mismatch()
+		}
+		return checkType(path+"[*]", t.Elem(), ty.ElementType())
+
+	case reflect.String:
+		if ty != cty.String {
+			return mismatch()
+		}
+
+	case reflect.Bool:
+		if ty != cty.Bool {
+			return mismatch()
+		}
+
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		if ty != cty.Number {
+			return mismatch()
+		}
+
+	default:
+		return mismatch()
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+// Package inputs provides typed input variables for the root module.
+//
+// Each field of Inputs, and of the nested object types, carries a `tf` struct tag
+// naming the Terraform variable or object attribute that it maps to.
+// Nil pointers, maps and slices are omitted from ToVars, so that the module default is used.
+// The With* methods only set the variables they name, the *_enabled variables are set with
+// the corresponding With*Enabled method.
+// CheckVariables verifies that every tag has a matching variable or attribute in the module.
+package inputs
+
+// Inputs mirrors the input variables of the root module.
+// Use New and the With* methods to build it.
+type Inputs struct {
+	Location         *string `tf:"location"`
+	DisableTelemetry *bool   `tf:"disable_telemetry"`
+
+	// subscription variables
+	SubscriptionAliasEnabled                         *bool                          `tf:"subscription_alias_enabled"`
+	SubscriptionAliasName                            *string                        `tf:"subscription_alias_name"`
+	SubscriptionDisplayName                          *string                        `tf:"subscription_display_name"`
+	SubscriptionBillingScope                         *string                        `tf:"subscription_billing_scope"`
+	SubscriptionWorkload                             *string                        `tf:"subscription_workload"`
+	SubscriptionManagementGroupID                    *string                        `tf:"subscription_management_group_id"`
+	SubscriptionManagementGroupAssociationEnabled    *bool                          `tf:"subscription_management_group_association_enabled"`
+	SubscriptionID                                   *string                        `tf:"subscription_id"`
+	SubscriptionTags                                 map[string]string              `tf:"subscription_tags"`
+	SubscriptionUseAzapi                             *bool                          `tf:"subscription_use_azapi"`
+	SubscriptionUpdateExisting                       *bool                          `tf:"subscription_update_existing"`
+	WaitForSubscriptionBeforeSubscriptionOperations  *WaitForSubscription           `tf:"wait_for_subscription_before_subscription_operations"`
+	SubscriptionRegisterResourceProvidersEnabled     *bool                          `tf:"subscription_register_resource_providers_enabled"`
+	SubscriptionRegisterResourceProvidersAndFeatures map[string][]string            `tf:"subscription_register_resource_providers_and_features"`
+	NetworkWatcherResourceGroupEnabled               *bool                          `tf:"network_watcher_resource_group_enabled"`
+	ResourceGroupCreationEnabled                     *bool                          `tf:"resource_group_creation_enabled"`
+	ResourceGroups                                   map[string]*ResourceGroup      `tf:"resource_groups"`
+	RoleAssignmentEnabled                            *bool                          `tf:"role_assignment_enabled"`
+	RoleAssignments                                  map[string]*RoleAssignment     `tf:"role_assignments"`
+	BudgetEnabled                                    *bool                          `tf:"budget_enabled"`
+	Budgets                                          map[string]*Budget             `tf:"budgets"`
+	VirtualNetworkEnabled                            *bool                          `tf:"virtual_network_enabled"`
+	VirtualNetworks                                  map[string]*VirtualNetwork     `tf:"virtual_networks"`
+	UmiEnabled                                       *bool                          `tf:"umi_enabled"`
+	UmiName                                          *string                        `tf:"umi_name"`
+	UmiTags                                          map[string]string              `tf:"umi_tags"`
+	UmiResourceGroupCreationEnabled                  *bool                          `tf:"umi_resource_group_creation_enabled"`
+	UmiResourceGroupName                             *string                        `tf:"umi_resource_group_name"`
+	UmiResourceGroupTags                             map[string]string              `tf:"umi_resource_group_tags"`
+	UmiResourceGroupLockEnabled                      *bool                          `tf:"umi_resource_group_lock_enabled"`
+	UmiResourceGroupLockName                         *string                        `tf:"umi_resource_group_lock_name"`
+	UmiFederatedCredentialsGithub                    map[string]*GithubCredential   `tf:"umi_federated_credentials_github"`
+	UmiFederatedCredentialsTerraformCloud            map[string]*TfcCredential      `tf:"umi_federated_credentials_terraform_cloud"`
+	UmiFederatedCredentialsAdvanced                  map[string]*AdvancedCredential `tf:"umi_federated_credentials_advanced"`
+	UmiRoleAssignments                               map[string]*UmiRoleAssignment  `tf:"umi_role_assignments"`
+}
+
+// WaitForSubscription mirrors the wait_for_subscription_before_subscription_operations variable.
+type WaitForSubscription struct {
+	Create  *string `tf:"create"`
+	Destroy *string `tf:"destroy"`
+}
+
+// New returns an empty set of inputs, all module defaults are used.
+func New() *Inputs {
+	return &Inputs{}
+}
+
+// ToVars returns the inputs in the form expected by setuptest.WithVars.
+func (i *Inputs) ToVars() map[string]any {
+	return toVars(i)
+}
+
+// WithLocation sets the location variable.
+func (i *Inputs) WithLocation(location string) *Inputs {
+	i.Location = &location
+	return i
+}
+
+// WithDisableTelemetry sets the disable_telemetry variable.
+func (i *Inputs) WithDisableTelemetry(disable bool) *Inputs {
+	i.DisableTelemetry = &disable
+	return i
+}
+
+// WithSubscriptionAlias sets the name, display name, billing scope and workload of the subscription alias.
+func (i *Inputs) WithSubscriptionAlias(name, displayName, billingScope, workload string) *Inputs {
+	i.SubscriptionAliasName = &name
+	i.SubscriptionDisplayName = &displayName
+	i.SubscriptionBillingScope = &billingScope
+	i.SubscriptionWorkload = &workload
+	return i
+}
+
+// WithSubscriptionAliasEnabled sets the subscription_alias_enabled variable.
+func (i *Inputs) WithSubscriptionAliasEnabled(enabled bool) *Inputs {
+	i.SubscriptionAliasEnabled = &enabled
+	return i
+}
+
+// WithSubscriptionID sets the subscription_id variable, used when supplying an existing subscription.
+func (i *Inputs) WithSubscriptionID(id string) *Inputs {
+	i.SubscriptionID = &id
+	return i
+}
+
+// WithSubscriptionManagementGroup sets the subscription_management_group_id variable.
+func (i *Inputs) WithSubscriptionManagementGroup(id string) *Inputs {
+	i.SubscriptionManagementGroupID = &id
+	return i
+}
+
+// WithSubscriptionManagementGroupAssociationEnabled sets the subscription_management_group_association_enabled variable.
+func (i *Inputs) WithSubscriptionManagementGroupAssociationEnabled(enabled bool) *Inputs {
+	i.SubscriptionManagementGroupAssociationEnabled = &enabled
+	return i
+}
+
+// WithSubscriptionTag adds a tag to the subscription_tags variable.
+func (i *Inputs) WithSubscriptionTag(key, value string) *Inputs {
+	if i.SubscriptionTags == nil {
+		i.SubscriptionTags = make(map[string]string)
+	}
+	i.SubscriptionTags[key] = value
+	return i
+}
+
+// WithSubscriptionUseAzapi sets the subscription_use_azapi variable.
+func (i *Inputs) WithSubscriptionUseAzapi(use bool) *Inputs {
+	i.SubscriptionUseAzapi = &use
+	return i
+}
+
+// WithSubscriptionUpdateExisting sets the subscription_update_existing variable.
+func (i *Inputs) WithSubscriptionUpdateExisting(update bool) *Inputs {
+	i.SubscriptionUpdateExisting = &update
+	return i
+}
+
+// WithWaitForSubscription sets the wait_for_subscription_before_subscription_operations variable.
+func (i *Inputs) WithWaitForSubscription(create, destroy string) *Inputs {
+	i.WaitForSubscriptionBeforeSubscriptionOperations = &WaitForSubscription{
+		Create:  &create,
+		Destroy: &destroy,
+	}
+	return i
+}
+
+// WithResourceProvidersEnabled sets the subscription_register_resource_providers_enabled variable.
+func (i *Inputs) WithResourceProvidersEnabled(enabled bool) *Inputs {
+	i.SubscriptionRegisterResourceProvidersEnabled = &enabled
+	return i
+}
+
+// WithResourceProviders sets the subscription_register_resource_providers_and_features variable
+// to the supplied map of resource providers to features.
+// A nil map uses the module default list.
+func (i *Inputs) WithResourceProviders(rps map[string][]string) *Inputs {
+	i.SubscriptionRegisterResourceProvidersAndFeatures = rps
+	return i
+}
+
+// WithNetworkWatcherResourceGroupEnabled sets the network_watcher_resource_group_enabled variable.
+func (i *Inputs) WithNetworkWatcherResourceGroupEnabled(enabled bool) *Inputs {
+	i.NetworkWatcherResourceGroupEnabled = &enabled
+	return i
+}
+
+// WithResourceGroupCreationEnabled sets the resource_group_creation_enabled variable.
+func (i *Inputs) WithResourceGroupCreationEnabled(enabled bool) *Inputs {
+	i.ResourceGroupCreationEnabled = &enabled
+	return i
+}
+
+// WithResourceGroup adds the resource group with the supplied key.
+func (i *Inputs) WithResourceGroup(key string, rg *ResourceGroup) *Inputs {
+	if i.ResourceGroups == nil {
+		i.ResourceGroups = make(map[string]*ResourceGroup)
+	}
+	i.ResourceGroups[key] = rg
+	return i
+}
+
+// WithRoleAssignmentEnabled sets the role_assignment_enabled variable.
+func (i *Inputs) WithRoleAssignmentEnabled(enabled bool) *Inputs {
+	i.RoleAssignmentEnabled = &enabled
+	return i
+}
+
+// WithRoleAssignment adds the role assignment with the supplied key.
+func (i *Inputs) WithRoleAssignment(key string, ra *RoleAssignment) *Inputs {
+	if i.RoleAssignments == nil {
+		i.RoleAssignments = make(map[string]*RoleAssignment)
+	}
+	i.RoleAssignments[key] = ra
+	return i
+}
+
+// WithBudgetEnabled sets the budget_enabled variable.
+func (i *Inputs) WithBudgetEnabled(enabled bool) *Inputs {
+	i.BudgetEnabled = &enabled
+	return i
+}
+
+// WithBudget adds the budget with the supplied key.
+func (i *Inputs) WithBudget(key string, b *Budget) *Inputs {
+	if i.Budgets == nil {
+		i.Budgets = make(map[string]*Budget)
+	}
+	i.Budgets[key] = b
+	return i
+}
+
+// WithVirtualNetwork adds the virtual network with the supplied key.
+func (i *Inputs) WithVirtualNetwork(key string, vnet *VirtualNetwork) *Inputs {
+	if i.VirtualNetworks == nil {
+		i.VirtualNetworks = make(map[string]*VirtualNetwork)
+	}
+	i.VirtualNetworks[key] = vnet
+	return i
+}
+
+// WithVirtualNetworkEnabled sets the virtual_network_enabled variable.
+func (i *Inputs) WithVirtualNetworkEnabled(enabled bool) *Inputs {
+	i.VirtualNetworkEnabled = &enabled
+	return i
+}
+
+// WithUmiEnabled sets the umi_enabled variable.
+func (i *Inputs) WithUmiEnabled(enabled bool) *Inputs {
+	i.UmiEnabled = &enabled
+	return i
+}
+
+// WithUmi sets the name and resource group of the user assigned managed identity.
+func (i *Inputs) WithUmi(name, resourceGroupName string) *Inputs {
+	i.UmiName = &name
+	i.UmiResourceGroupName = &resourceGroupName
+	return i
+}
+
+// WithUmiResourceGroupLock sets whether the user assigned managed identity resource group is locked, and the name of the lock.
+// An empty name uses the module default.
+func (i *Inputs) WithUmiResourceGroupLock(enabled bool, name string) *Inputs {
+	i.UmiResourceGroupLockEnabled = &enabled
+	if name != "" {
+		i.UmiResourceGroupLockName = &name
+	}
+	return i
+}
+
+// WithUmiGithubCredential adds a GitHub federated credential with the supplied key.
+func (i *Inputs) WithUmiGithubCredential(key string, c *GithubCredential) *Inputs {
+	if i.UmiFederatedCredentialsGithub == nil {
+		i.UmiFederatedCredentialsGithub = make(map[string]*GithubCredential)
+	}
+	i.UmiFederatedCredentialsGithub[key] = c
+	return i
+}
+
+// WithUmiTfcCredential adds a Terraform Cloud federated credential with the supplied key.
+func (i *Inputs) WithUmiTfcCredential(key string, c *TfcCredential) *Inputs {
+	if i.UmiFederatedCredentialsTerraformCloud == nil {
+		i.UmiFederatedCredentialsTerraformCloud = make(map[string]*TfcCredential)
+	}
+	i.UmiFederatedCredentialsTerraformCloud[key] = c
+	return i
+}
+
+// WithUmiAdvancedCredential adds an advanced federated credential with the supplied key.
+func (i *Inputs) WithUmiAdvancedCredential(key string, c *AdvancedCredential) *Inputs {
+	if i.UmiFederatedCredentialsAdvanced == nil {
+		i.UmiFederatedCredentialsAdvanced = make(map[string]*AdvancedCredential)
+	}
+	i.UmiFederatedCredentialsAdvanced[key] = c
+	return i
+}
+
+// WithUmiRoleAssignment adds a role assignment for the user assigned managed identity with the supplied key.
+func (i *Inputs) WithUmiRoleAssignment(key string, ra *UmiRoleAssignment) *Inputs {
+	if i.UmiRoleAssignments == nil {
+		i.UmiRoleAssignments = make(map[string]*UmiRoleAssignment)
+	}
+	i.UmiRoleAssignments[key] = ra
+	return i
+}
@@ -0,0 +1,172 @@
+package inputs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const moduleDir = "../../"
+
+// TestCheckVariables tests that every field of Inputs, including nested object attributes,
+// has a matching variable in the root module.
+func TestCheckVariables(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, CheckVariables(moduleDir, New()))
+}
+
+// TestCheckVariablesMismatch tests that misspelled variables and attributes, and type mismatches, are reported.
+func TestCheckVariablesMismatch(t *testing.T) {
+	t.Parallel()
+
+	type vnet struct {
+		Name           string `tf:"name"`
+		HubPeeringOops *bool  `tf:"hub_peering_enabeld"`
+	}
+	type bad struct {
+		Location        *string         `tf:"location"`
+		Typo            *string         `tf:"subscription_alais_name"`
+		VirtualNetworks map[string]vnet `tf:"virtual_networks"`
+		WrongType       *bool           `tf:"subscription_id"`
+	}
+
+	err := CheckVariables(moduleDir, bad{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no variable "subscription_alais_name"`)
+	assert.Contains(t, err.Error(), `virtual_networks["*"]: vnet.HubPeeringOops has no matching attribute "hub_peering_enabeld"`)
+	assert.Contains(t, err.Error(), `subscription_id: Go type bool does not match Terraform type string`)
+	assert.NotContains(t, err.Error(), "location")
+}
+
+// TestToVars tests that only the supplied inputs are included in the variables,
+// so that the module defaults are used for the rest.
+func TestToVars(t *testing.T) {
+	t.Parallel()
+
+	v := New().
+		WithLocation("northeurope").
+		WithSubscriptionAlias("alias", "display", "/billing", "Production").
+		WithSubscriptionAliasEnabled(true).
+		WithResourceProviders(map[string][]string{}).
+		WithVirtualNetwork("primary", NewVirtualNetwork("primary-vnet", "primary-rg", "192.168.0.0/24").
+			WithResourceGroupLock(false, "").
+			WithHubPeering("/hub")).
+		WithVirtualNetworkEnabled(true).
+		WithRoleAssignment("ra", NewRoleAssignment("00000000-0000-0000-0000-000000000000", "Owner")).
+		ToVars()
+
+	assert.Equal(t, map[string]any{
+		"location":                                              "northeurope",
+		"subscription_alias_enabled":                            true,
+		"subscription_alias_name":                               "alias",
+		"subscription_display_name":                             "display",
+		"subscription_billing_scope":                            "/billing",
+		"subscription_workload":                                 "Production",
+		"subscription_register_resource_providers_and_features": map[string]any{},
+		"virtual_network_enabled":                               true,
+		"virtual_networks": map[string]any{
+			"primary": map[string]any{
+				"name":                        "primary-vnet",
+				"address_space":               []any{"192.168.0.0/24"},
+				"resource_group_name":         "primary-rg",
+				"resource_group_lock_enabled": false,
+				"hub_peering_enabled":         true,
+				"hub_network_resource_id":     "/hub",
+			},
+		},
+		"role_assignments": map[string]any{
+			"ra": map[string]any{
+				"principal_id": "00000000-0000-0000-0000-000000000000",
+				"definition":   "Owner",
+			},
+		},
+	}, v)
+}
+
+// TestBudgetModuleVars tests that a budget is converted to the variables of the budget submodule.
+func TestBudgetModuleVars(t *testing.T) {
+	t.Parallel()
+
+	b := NewBudget(100, "Monthly", "2024-01-01T00:00:00Z", "2025-01-01T00:00:00Z").
+		WithRelativeScope("/resourceGroups/rg").
+		WithNotification("actual", NewBudgetNotification("GreaterThan", 80).WithContactRoles("Owner"))
+	v := b.ModuleVars("budget", "00000000-0000-0000-0000-000000000000")
+
+	assert.Equal(t, "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg", v["budget_scope"])
+	assert.Equal(t, map[string]any{
+		"actual": map[string]any{
+			"enabled":       true,
+			"operator":      "GreaterThan",
+			"threshold":     float64(80),
+			"contact_roles": []any{"Owner"},
+		},
+	}, v["budget_notifications"])
+
+	vars, err := ModuleVariables(moduleDir + "modules/budget")
+	require.NoError(t, err)
+	for k := range v {
+		assert.Contains(t, vars, k)
+	}
+}
+
+func TestUmiModuleVars(t *testing.T) {
+	t.Parallel()
+
+	in := New().
+		WithLocation("westeurope").
+		WithUmiEnabled(true).
+		WithUmi("umi", "rg-umi").
+		WithUmiGithubCredential("gh", NewGithubCredential("my-organization", "my-repository", "branch", "main")).
+		WithUmiRoleAssignment("ra", NewUmiRoleAssignment("Owner"))
+	v := in.UmiModuleVars("00000000-0000-0000-0000-000000000000")
+
+	assert.Equal(t, map[string]any{
+		"subscription_id":     "00000000-0000-0000-0000-000000000000",
+		"location":            "westeurope",
+		"name":                "umi",
+		"resource_group_name": "rg-umi",
+		"federated_credentials_github": map[string]any{
+			"gh": map[string]any{
+				"organization": "my-organization",
+				"repository":   "my-repository",
+				"entity":       "branch",
+				"value":        "main",
+			},
+		},
+	}, v)
+
+	vars, err := ModuleVariables(moduleDir + "modules/usermanagedidentity")
+	require.NoError(t, err)
+	for k := range v {
+		assert.Contains(t, vars, k)
+	}
+}
+
+func TestVirtualNetworkModuleVars(t *testing.T) {
+	t.Parallel()
+
+	in := New().
+		WithVirtualNetwork("primary", NewVirtualNetwork("vnet", "rg", "10.0.0.0/24").WithMeshPeering(true))
+	v := in.VirtualNetworkModuleVars("00000000-0000-0000-0000-000000000000")
+
+	assert.Equal(t, map[string]any{
+		"subscription_id": "00000000-0000-0000-0000-000000000000",
+		"virtual_networks": map[string]map[string]any{
+			"primary": {
+				"name":                                 "vnet",
+				"resource_group_name":                  "rg",
+				"address_space":                        []any{"10.0.0.0/24"},
+				"mesh_peering_enabled":                 true,
+				"mesh_peering_allow_forwarded_traffic": true,
+			},
+		},
+	}, v)
+
+	vars, err := ModuleVariables(moduleDir + "modules/virtualnetwork")
+	require.NoError(t, err)
+	for k := range v {
+		assert.Contains(t, vars, k)
+	}
+}
@@ -0,0 +1,25 @@
+package inputs
+
+// ResourceGroup mirrors an element of the resource_groups variable.
+type ResourceGroup struct {
+	Name     string            `tf:"name"`
+	Location string            `tf:"location"`
+	Tags     map[string]string `tf:"tags"`
+}
+
+// NewResourceGroup returns a resource group with the supplied name and location.
+func NewResourceGroup(name, location string) *ResourceGroup {
+	return &ResourceGroup{
+		Name:     name,
+		Location: location,
+	}
+}
+
+// WithTag adds a tag to the resource group.
+func (r *ResourceGroup) WithTag(key, value string) *ResourceGroup {
+	if r.Tags == nil {
+		r.Tags = make(map[string]string)
+	}
+	r.Tags[key] = value
+	return r
+}
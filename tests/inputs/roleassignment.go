@@ -0,0 +1,31 @@
+package inputs
+
+// RoleAssignment mirrors an element of the role_assignments variable.
+type RoleAssignment struct {
+	PrincipalID      string  `tf:"principal_id"`
+	Definition       string  `tf:"definition"`
+	RelativeScope    *string `tf:"relative_scope"`
+	Condition        *string `tf:"condition"`
+	ConditionVersion *string `tf:"condition_version"`
+}
+
+// NewRoleAssignment returns a role assignment of the supplied role definition name or id to the principal.
+func NewRoleAssignment(principalID, definition string) *RoleAssignment {
+	return &RoleAssignment{
+		PrincipalID: principalID,
+		Definition:  definition,
+	}
+}
+
+// WithRelativeScope sets the scope of the role assignment, relative to the subscription.
+func (r *RoleAssignment) WithRelativeScope(scope string) *RoleAssignment {
+	r.RelativeScope = &scope
+	return r
+}
+
+// WithCondition sets the condition and condition version of the role assignment.
+func (r *RoleAssignment) WithCondition(condition, version string) *RoleAssignment {
+	r.Condition = &condition
+	r.ConditionVersion = &version
+	return r
+}
@@ -0,0 +1,127 @@
+package inputs
+
+import "strings"
+
+// GithubCredential mirrors an element of the umi_federated_credentials_github variable.
+type GithubCredential struct {
+	Name         *string `tf:"name"`
+	Organization string  `tf:"organization"`
+	Repository   string  `tf:"repository"`
+	Entity       string  `tf:"entity"`
+	Value        *string `tf:"value"`
+}
+
+// TfcCredential mirrors an element of the umi_federated_credentials_terraform_cloud variable.
+type TfcCredential struct {
+	Name         *string `tf:"name"`
+	Organization string  `tf:"organization"`
+	Project      string  `tf:"project"`
+	Workspace    string  `tf:"workspace"`
+	RunPhase     string  `tf:"run_phase"`
+}
+
+// AdvancedCredential mirrors an element of the umi_federated_credentials_advanced variable.
+type AdvancedCredential struct {
+	Name              string   `tf:"name"`
+	SubjectIdentifier string   `tf:"subject_identifier"`
+	IssuerURL         string   `tf:"issuer_url"`
+	Audiences         []string `tf:"audiences"`
+}
+
+// UmiRoleAssignment mirrors an element of the umi_role_assignments variable.
+type UmiRoleAssignment struct {
+	Definition       string  `tf:"definition"`
+	RelativeScope    *string `tf:"relative_scope"`
+	Condition        *string `tf:"condition"`
+	ConditionVersion *string `tf:"condition_version"`
+}
+
+// NewGithubCredential returns a GitHub federated credential for the supplied repository.
+// Entity is one of environment, branch, tag or pull_request, and value is required unless entity is pull_request.
+func NewGithubCredential(organization, repository, entity, value string) *GithubCredential {
+	c := &GithubCredential{
+		Organization: organization,
+		Repository:   repository,
+		Entity:       entity,
+	}
+	if value != "" {
+		c.Value = &value
+	}
+	return c
+}
+
+// WithName sets the name of the federated credential.
+func (c *GithubCredential) WithName(name string) *GithubCredential {
+	c.Name = &name
+	return c
+}
+
+// NewTfcCredential returns a Terraform Cloud federated credential for the supplied workspace and run phase.
+func NewTfcCredential(organization, project, workspace, runPhase string) *TfcCredential {
+	return &TfcCredential{
+		Organization: organization,
+		Project:      project,
+		Workspace:    workspace,
+		RunPhase:     runPhase,
+	}
+}
+
+// WithName sets the name of the federated credential.
+func (c *TfcCredential) WithName(name string) *TfcCredential {
+	c.Name = &name
+	return c
+}
+
+// NewAdvancedCredential returns a federated credential for the supplied issuer and subject.
+func NewAdvancedCredential(name, issuerURL, subjectIdentifier string) *AdvancedCredential {
+	return &AdvancedCredential{
+		Name:              name,
+		IssuerURL:         issuerURL,
+		SubjectIdentifier: subjectIdentifier,
+	}
+}
+
+// WithAudiences sets the audiences of the federated credential.
+func (c *AdvancedCredential) WithAudiences(audiences ...string) *AdvancedCredential {
+	c.Audiences = audiences
+	return c
+}
+
+// NewUmiRoleAssignment returns a role assignment of the supplied role definition name or id to the user assigned managed identity.
+func NewUmiRoleAssignment(definition string) *UmiRoleAssignment {
+	return &UmiRoleAssignment{
+		Definition: definition,
+	}
+}
+
+// WithRelativeScope sets the scope of the role assignment, relative to the subscription.
+func (r *UmiRoleAssignment) WithRelativeScope(scope string) *UmiRoleAssignment {
+	r.RelativeScope = &scope
+	return r
+}
+
+// WithCondition sets the condition and condition version of the role assignment.
+func (r *UmiRoleAssignment) WithCondition(condition, version string) *UmiRoleAssignment {
+	r.Condition = &condition
+	r.ConditionVersion = &version
+	return r
+}
+
+// UmiModuleVars returns the variables of the usermanagedidentity submodule, as the root module sets them
+// from the location and umi_* variables for a user assigned managed identity in the subscription.
+func (i *Inputs) UmiModuleVars(subscriptionID string) map[string]any {
+	vars := map[string]any{
+		"subscription_id": subscriptionID,
+	}
+	for k, v := range i.ToVars() {
+		switch {
+		case k == "location":
+			vars[k] = v
+		case k == "umi_enabled", k == "umi_role_assignments":
+			// used by the root module only
+		case strings.HasPrefix(k, "umi_"):
+			vars[strings.TrimPrefix(k, "umi_")] = v
+		}
+	}
+	return vars
+}
@@ -0,0 +1,84 @@
+package inputs
+
+import (
+	"reflect"
+	"strings"
+)
+
+// tagName is the struct tag that holds the Terraform variable or attribute name.
+const tagName = "tf"
+
+// ptr returns a pointer to the supplied value.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// toVars converts the supplied struct into a map keyed by the tf struct tags.
+func toVars(v any) map[string]any {
+	out, _ := toValue(reflect.ValueOf(v))
+	m, _ := out.(map[string]any)
+	if m == nil {
+		m = make(map[string]any)
+	}
+	return m
+}
+
+// toValue converts the supplied value into the types expected by setuptest.WithVars.
+// Structs become map[string]any, slices become []any and maps become map[string]any.
+// It returns false if the value is a nil pointer, map or slice and should be omitted.
+func toValue(v reflect.Value) (any, bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		return toValue(v.Elem())
+
+	case reflect.Struct:
+		out := make(map[string]any)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := tfName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			if fv, ok := toValue(v.Field(i)); ok {
+				out[name] = fv
+			}
+		}
+		return out, true
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if ev, ok := toValue(iter.Value()); ok {
+				out[iter.Key().String()] = ev
+			}
+		}
+		return out, true
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, false
+		}
+		out := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if ev, ok := toValue(v.Index(i)); ok {
+				out = append(out, ev)
+			}
+		}
+		return out, true
+	}
+	return v.Interface(), true
+}
+
+// tfName returns the Terraform name from the tf struct tag of the field,
+// or an empty string if the field has no tag.
+func tfName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get(tagName), ",")
+	return name
+}
@@ -0,0 +1,190 @@
+package inputs
+
+// VirtualNetwork mirrors an element of the virtual_networks variable.
+type VirtualNetwork struct {
+	Name              string   `tf:"name"`
+	AddressSpace      []string `tf:"address_space"`
+	ResourceGroupName string   `tf:"resource_group_name"`
+
+	Location   *string  `tf:"location"`
+	DnsServers []string `tf:"dns_servers"`
+
+	DdosProtectionEnabled *bool   `tf:"ddos_protection_enabled"`
+	DdosProtectionPlanID  *string `tf:"ddos_protection_plan_id"`
+
+	HubNetworkResourceID        *string `tf:"hub_network_resource_id"`
+	HubPeeringEnabled           *bool   `tf:"hub_peering_enabled"`
+	HubPeeringDirection         *string `tf:"hub_peering_direction"`
+	HubPeeringNameToHub         *string `tf:"hub_peering_name_tohub"`
+	HubPeeringNameFromHub       *string `tf:"hub_peering_name_fromhub"`
+	HubPeeringUseRemoteGateways *bool   `tf:"hub_peering_use_remote_gateways"`
+
+	MeshPeeringEnabled               *bool `tf:"mesh_peering_enabled"`
+	MeshPeeringAllowForwardedTraffic *bool `tf:"mesh_peering_allow_forwarded_traffic"`
+
+	ResourceGroupCreationEnabled *bool             `tf:"resource_group_creation_enabled"`
+	ResourceGroupLockEnabled     *bool             `tf:"resource_group_lock_enabled"`
+	ResourceGroupLockName        *string           `tf:"resource_group_lock_name"`
+	ResourceGroupTags            map[string]string `tf:"resource_group_tags"`
+
+	VwanAssociatedRoutetableResourceID   *string                    `tf:"vwan_associated_routetable_resource_id"`
+	VwanConnectionEnabled                *bool                      `tf:"vwan_connection_enabled"`
+	VwanConnectionName                   *string                    `tf:"vwan_connection_name"`
+	VwanHubResourceID                    *string                    `tf:"vwan_hub_resource_id"`
+	VwanPropagatedRoutetablesLabels      []string                   `tf:"vwan_propagated_routetables_labels"`
+	VwanPropagatedRoutetablesResourceIDs []string                   `tf:"vwan_propagated_routetables_resource_ids"`
+	VwanSecurityConfiguration            *VwanSecurityConfiguration `tf:"vwan_security_configuration"`
+
+	Tags map[string]string `tf:"tags"`
+}
+
+// VwanSecurityConfiguration mirrors the vwan_security_configuration attribute of a virtual network.
+type VwanSecurityConfiguration struct {
+	SecureInternetTraffic *bool `tf:"secure_internet_traffic"`
+	SecurePrivateTraffic  *bool `tf:"secure_private_traffic"`
+	RoutingIntentEnabled  *bool `tf:"routing_intent_enabled"`
+}
+
+// NewVirtualNetwork returns a virtual network with the required attributes set.
+func NewVirtualNetwork(name, resourceGroupName string, addressSpace ...string) *VirtualNetwork {
+	return &VirtualNetwork{
+		Name:              name,
+		AddressSpace:      addressSpace,
+		ResourceGroupName: resourceGroupName,
+	}
+}
+
+// WithLocation sets the location of the virtual network.
+func (v *VirtualNetwork) WithLocation(location string) *VirtualNetwork {
+	v.Location = &location
+	return v
+}
+
+// WithDnsServers sets the DNS servers of the virtual network.
+func (v *VirtualNetwork) WithDnsServers(servers ...string) *VirtualNetwork {
+	v.DnsServers = servers
+	return v
+}
+
+// WithDdosProtection enables DDoS protection using the supplied plan.
+func (v *VirtualNetwork) WithDdosProtection(planID string) *VirtualNetwork {
+	v.DdosProtectionEnabled = ptr(true)
+	v.DdosProtectionPlanID = &planID
+	return v
+}
+
+// WithHubPeering enables peering to the supplied hub virtual network.
+func (v *VirtualNetwork) WithHubPeering(hubID string) *VirtualNetwork {
+	v.HubPeeringEnabled = ptr(true)
+	v.HubNetworkResourceID = &hubID
+	return v
+}
+
+// WithHubPeeringDirection sets the direction of the hub peering, one of both, tohub or fromhub.
+func (v *VirtualNetwork) WithHubPeeringDirection(direction string) *VirtualNetwork {
+	v.HubPeeringDirection = &direction
+	return v
+}
+
+// WithHubPeeringNames sets the names of the peerings to and from the hub.
+func (v *VirtualNetwork) WithHubPeeringNames(toHub, fromHub string) *VirtualNetwork {
+	v.HubPeeringNameToHub = &toHub
+	v.HubPeeringNameFromHub = &fromHub
+	return v
+}
+
+// WithHubPeeringUseRemoteGateways sets whether the peering to the hub uses the remote gateways.
+func (v *VirtualNetwork) WithHubPeeringUseRemoteGateways(use bool) *VirtualNetwork {
+	v.HubPeeringUseRemoteGateways = &use
+	return v
+}
+
+// WithMeshPeering enables mesh peering and sets whether forwarded traffic is allowed.
+func (v *VirtualNetwork) WithMeshPeering(allowForwardedTraffic bool) *VirtualNetwork {
+	v.MeshPeeringEnabled = ptr(true)
+	v.MeshPeeringAllowForwardedTraffic = &allowForwardedTraffic
+	return v
+}
+
+// WithResourceGroupCreationEnabled sets whether the resource group of the virtual network is created.
+func (v *VirtualNetwork) WithResourceGroupCreationEnabled(enabled bool) *VirtualNetwork {
+	v.ResourceGroupCreationEnabled = &enabled
+	return v
+}
+
+// WithResourceGroupLock sets whether the resource group is locked, and the name of the lock.
+// An empty name uses the module default.
+func (v *VirtualNetwork) WithResourceGroupLock(enabled bool, name string) *VirtualNetwork {
+	v.ResourceGroupLockEnabled = &enabled
+	if name != "" {
+		v.ResourceGroupLockName = &name
+	}
+	return v
+}
+
+// WithResourceGroupTag adds a tag to the resource group of the virtual network.
+func (v *VirtualNetwork) WithResourceGroupTag(key, value string) *VirtualNetwork {
+	if v.ResourceGroupTags == nil {
+		v.ResourceGroupTags = make(map[string]string)
+	}
+	v.ResourceGroupTags[key] = value
+	return v
+}
+
+// WithVwanConnection enables the connection to the supplied virtual hub.
+func (v *VirtualNetwork) WithVwanConnection(hubID string) *VirtualNetwork {
+	v.VwanConnectionEnabled = ptr(true)
+	v.VwanHubResourceID = &hubID
+	return v
+}
+
+// WithVwanConnectionName sets the name of the virtual hub connection.
+func (v *VirtualNetwork) WithVwanConnectionName(name string) *VirtualNetwork {
+	v.VwanConnectionName = &name
+	return v
+}
+
+// WithVwanRouting sets the associated and propagated route tables of the virtual hub connection.
+func (v *VirtualNetwork) WithVwanRouting(associatedID string, propagatedIDs, propagatedLabels []string) *VirtualNetwork {
+	v.VwanAssociatedRoutetableResourceID = &associatedID
+	v.VwanPropagatedRoutetablesResourceIDs = propagatedIDs
+	v.VwanPropagatedRoutetablesLabels = propagatedLabels
+	return v
+}
+
+// WithVwanSecurityConfiguration sets the security configuration of the virtual hub connection.
+func (v *VirtualNetwork) WithVwanSecurityConfiguration(secureInternet, securePrivate, routingIntent bool) *VirtualNetwork {
+	v.VwanSecurityConfiguration = &VwanSecurityConfiguration{
+		SecureInternetTraffic: &secureInternet,
+		SecurePrivateTraffic:  &securePrivate,
+		RoutingIntentEnabled:  &routingIntent,
+	}
+	return v
+}
+
+// WithTag adds a tag to the virtual network.
+func (v *VirtualNetwork) WithTag(key, value string) *VirtualNetwork {
+	if v.Tags == nil {
+		v.Tags = make(map[string]string)
+	}
+	v.Tags[key] = value
+	return v
+}
+
+// VirtualNetworkModuleVars returns the variables of the virtualnetwork submodule, as the root module sets them
+// from the location and virtual_networks variables for the virtual networks in the subscription.
+// Each virtual network is a map[string]any, so that a test can change its attributes in the returned variables.
+func (i *Inputs) VirtualNetworkModuleVars(subscriptionID string) map[string]any {
+	vnets := make(map[string]map[string]any, len(i.VirtualNetworks))
+	for k, v := range i.VirtualNetworks {
+		vnets[k] = toVars(v)
+	}
+	vars := map[string]any{
+		"subscription_id":  subscriptionID,
+		"virtual_networks": vnets,
+	}
+	if i.Location != nil {
+		vars["location"] = *i.Location
+	}
+	return vars
+}
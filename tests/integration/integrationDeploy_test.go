@@ -20,9 +20,14 @@ func TestDeployIntegrationHubAndSpoke(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	testDir := "testdata/" + t.Name()
 	v, err := getValidInputVariables()
 	require.NoErrorf(t, err, "could not generate valid input variables")
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -66,7 +71,7 @@ func TestDeployIntegrationHubAndSpoke(t *testing.T) {
 	// update it after the apply.
 	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
+		err := azureutils.CancelSubscription(ctx, t, &u)
 		if err != nil {
 			t.Logf("failed to cancel subscription: %v", err)
 		}
@@ -87,12 +92,19 @@ func TestDeployIntegrationHubAndSpoke(t *testing.T) {
 	assert.Truef(t, ok, "failed to cast subscription id output to string")
 	u, err = uuid.Parse(ids)
 	assert.NoErrorf(t, err, "cannot parse subscription id as uuid: %s", id)
+
+	// the default resource providers are registered in the vended subscription
+	assert.NoError(t, azureutils.VerifyRegistrations(ctx, t, u, azureutils.ResourceProvidersInPlan(test.PlanStruct), nil))
 }
 
 func TestDeployIntegrationResourceGroupsRpRegUmiAndRoleAssignments(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	testDir := "testdata/" + t.Name()
 	r, err := utils.RandomHex(4)
 	require.NoError(t, err)
@@ -100,12 +112,17 @@ func TestDeployIntegrationResourceGroupsRpRegUmiAndRoleAssignments(t *testing.T)
 		"random_hex":      r,
 		"subscription_id": os.Getenv("AZURE_SUBSCRIPTION_ID"),
 	}
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShow(t)
 	require.NoError(t, err)
 	defer test.Cleanup()
 
 	defer test.DestroyRetry(setuptest.FastRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	subId, err := uuid.Parse(v["subscription_id"].(string))
+	require.NoError(t, err)
+	assert.NoError(t, azureutils.VerifyRegistrations(ctx, t, subId, azureutils.ResourceProvidersInPlan(test.PlanStruct), nil))
 }
 
 func getValidInputVariables() (map[string]any, error) {
@@ -43,6 +43,7 @@ func TestDeployNetworkWatcherRg(t *testing.T) {
 		}
 	}
 
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
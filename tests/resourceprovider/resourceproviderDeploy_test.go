@@ -4,9 +4,12 @@ import (
 	"os"
 	"testing"
 
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,11 +18,15 @@ func TestDeploySubscriptionDeployExistingWithRpFeatureRegistration(t *testing.T)
 
 	utils.PreCheckDeployTests(t)
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	v := make(map[string]any)
 	v["subscription_id"] = os.Getenv("AZURE_SUBSCRIPTION_ID")
 	v["resource_provider"] = "Microsoft.PowerBI"
 	v["features"] = []string{"DailyPrivateLinkServicesForPowerBI"}
 
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -28,4 +35,9 @@ func TestDeploySubscriptionDeployExistingWithRpFeatureRegistration(t *testing.T)
 	test.ApplyIdempotent().ErrorIsNil(t)
 
 	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(2).ErrorIsNil(t)
+
+	subId, err := uuid.Parse(v["subscription_id"].(string))
+	require.NoError(t, err)
+	rps := map[string][]string{v["resource_provider"].(string): v["features"].([]string)}
+	assert.NoError(t, azureutils.VerifyRegistrations(ctx, t, subId, rps, nil))
 }
@@ -1,14 +1,18 @@
 package roleassignment
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -18,6 +22,10 @@ func TestDeployRoleAssignmentDefinitionName(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	name, err := utils.RandomHex(4)
 	require.NoErrorf(t, err, "could not generate random hex")
 
@@ -26,6 +34,7 @@ func TestDeployRoleAssignmentDefinitionName(t *testing.T) {
 		"role_definition": "Storage Blob Data Contributor",
 	}
 	testDir := filepath.Join("testdata", t.Name())
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -33,6 +42,8 @@ func TestDeployRoleAssignmentDefinitionName(t *testing.T) {
 	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(2).ErrorIsNil(t)
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	verifyRoleAssignment(ctx, t, test, v["role_definition"].(string))
 }
 
 // TestDeployRoleAssignmentDefinitionId tests the deployment of a role assignment
@@ -41,6 +52,10 @@ func TestDeployRoleAssignmentDefinitionId(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	name, err := utils.RandomHex(4)
 	require.NoErrorf(t, err, "could not generate random hex")
 
@@ -51,6 +66,7 @@ func TestDeployRoleAssignmentDefinitionId(t *testing.T) {
 	}
 
 	testDir := filepath.Join("testdata/", t.Name())
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	defer test.Cleanup()
 	require.NoError(t, err)
@@ -59,4 +75,21 @@ func TestDeployRoleAssignmentDefinitionId(t *testing.T) {
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	verifyRoleAssignment(ctx, t, test, rd)
+}
+
+// verifyRoleAssignment checks Azure has the role assigned to the principal at exactly the resource group
+// created by the test data, without a condition.
+func verifyRoleAssignment(ctx context.Context, t *testing.T, test setuptest.Response, definition string) {
+	principal, err := terraform.OutputE(t, test.Options, "principal_id")
+	require.NoError(t, err)
+	scope, err := terraform.OutputE(t, test.Options, "scope")
+	require.NoError(t, err)
+	_, err = azureutils.VerifyRoleAssignment(ctx, t, azureutils.ExpectedRoleAssignment{
+		PrincipalID:    principal,
+		RoleDefinition: definition,
+		Scope:          scope,
+	}, nil)
+	assert.NoError(t, err)
 }
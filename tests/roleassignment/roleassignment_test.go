@@ -6,7 +6,6 @@ import (
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
-	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -59,16 +58,18 @@ func TestRoleAssignmentInvalidScopes(t *testing.T) {
 		v := v
 		v["role_assignment_scope"] = "/"
 		test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+		require.Error(t, err)
 		defer test.Cleanup()
-		assert.Contains(t, utils.SanitiseErrorMessage(err), errString)
+		utils.AssertInvalidVariable(t, test.Options, "role_assignment_scope", errString)
 	})
 
 	t.Run("managementGroup", func(t *testing.T) {
 		v := v
 		v["role_assignment_scope"] = "/providers/Microsoft.Management/managementGroups/myMg"
 		test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+		require.Error(t, err)
 		defer test.Cleanup()
-		assert.Contains(t, utils.SanitiseErrorMessage(err), errString)
+		utils.AssertInvalidVariable(t, test.Options, "role_assignment_scope", errString)
 	})
 }
 
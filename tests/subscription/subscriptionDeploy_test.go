@@ -27,8 +27,12 @@ func TestDeploySubscriptionAliasValid(t *testing.T) {
 
 	utils.PreCheckDeployTests(t)
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	v, err := getValidInputVariables(billingScope)
 	require.NoError(t, err)
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -41,7 +45,7 @@ func TestDeploySubscriptionAliasValid(t *testing.T) {
 	// update it after the apply.
 	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
+		err := azureutils.CancelSubscription(ctx, t, &u)
 		t.Logf("cannot cancel subscription: %v", err)
 	}()
 
@@ -65,9 +69,13 @@ func TestDeploySubscriptionAliasValidAzApi(t *testing.T) {
 
 	utils.PreCheckDeployTests(t)
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	v, err := getValidInputVariables(billingScope)
 	v["subscription_use_azapi"] = true
 	require.NoError(t, err)
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -80,7 +88,7 @@ func TestDeploySubscriptionAliasValidAzApi(t *testing.T) {
 	// update it after the apply.
 	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
+		err := azureutils.CancelSubscription(ctx, t, &u)
 		if err != nil {
 			t.Logf("cannot cancel subscription: %v", err)
 		}
@@ -103,6 +111,9 @@ func TestDeploySubscriptionAliasManagementGroupValid(t *testing.T) {
 	t.Parallel()
 	utils.PreCheckDeployTests(t)
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	v, err := getValidInputVariables(billingScope)
 	require.NoError(t, err)
 	v["subscription_billing_scope"] = billingScope
@@ -110,6 +121,7 @@ func TestDeploySubscriptionAliasManagementGroupValid(t *testing.T) {
 	v["subscription_management_group_association_enabled"] = true
 
 	testDir := filepath.Join("testdata", t.Name())
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -121,7 +133,7 @@ func TestDeploySubscriptionAliasManagementGroupValid(t *testing.T) {
 	// update it after the apply.
 	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
+		err := azureutils.CancelSubscription(ctx, t, &u)
 		if err != nil {
 			t.Logf("cannot cancel subscription: %v", err)
 		}
@@ -138,16 +150,77 @@ func TestDeploySubscriptionAliasManagementGroupValid(t *testing.T) {
 	u, err = uuid.Parse(sid)
 	assert.NoErrorf(t, err, "subscription id %s is not a valid uuid", sid)
 
-	err = azureutils.IsSubscriptionInManagementGroup(t, u, v["subscription_management_group_id"].(string))
+	err = azureutils.IsSubscriptionInManagementGroup(ctx, t, u, v["subscription_management_group_id"].(string))
 	assert.NoErrorf(t, err, "subscription %s is not in management group %s", sid, v["subscription_management_group_id"].(string))
 }
 
+// TestDeploySubscriptionAliasNestedManagementGroupValid tests the deployment of a subscription alias
+// into a management group beneath a throwaway management group created for the test,
+// and that the subscription is then beneath both.
+func TestDeploySubscriptionAliasNestedManagementGroupValid(t *testing.T) {
+	t.Parallel()
+	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	parent, deleteParent, err := azureutils.NewTestManagementGroup(ctx, t, "")
+	require.NoError(t, err)
+	// Deferred first, so it runs after the Terraform destroy has removed the child management group.
+	defer func() {
+		if err := deleteParent(); err != nil {
+			t.Logf("cannot delete management group %s: %v", parent, err)
+		}
+	}()
+
+	v, err := getValidInputVariables(billingScope)
+	require.NoError(t, err)
+	v["subscription_billing_scope"] = billingScope
+	v["subscription_management_group_id"] = v["subscription_alias_name"]
+	v["subscription_management_group_association_enabled"] = true
+	v["parent_management_group_id"] = parent
+
+	testDir := filepath.Join("testdata", t.Name())
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
+	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.NoError(t, err)
+	defer test.Cleanup()
+
+	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
+	defer func() {
+		err := azureutils.CancelSubscription(ctx, t, &u)
+		if err != nil {
+			t.Logf("cannot cancel subscription: %v", err)
+		}
+	}()
+
+	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
+	test.ApplyIdempotent().ErrorIsNil(t)
+
+	sid, err := terraform.OutputE(t, test.Options, "subscription_id")
+	assert.NoError(t, err)
+	u, err = uuid.Parse(sid)
+	assert.NoErrorf(t, err, "subscription id %s is not a valid uuid", sid)
+
+	mg := v["subscription_management_group_id"].(string)
+	assert.NoError(t, azureutils.IsSubscriptionInManagementGroup(ctx, t, u, mg))
+	assert.NoError(t, azureutils.IsSubscriptionBeneathManagementGroup(ctx, t, u, parent))
+	chain, err := azureutils.ManagementGroupAncestors(ctx, u)
+	assert.NoError(t, err)
+	if assert.GreaterOrEqual(t, len(chain), 2) {
+		assert.Equal(t, []string{parent, mg}, chain[len(chain)-2:])
+	}
+}
+
 // TestDeploySubscriptionAliasManagementGroupValid tests the deployment of a subscription alias
 // with valid input variables.
 func TestDeploySubscriptionAliasManagementGroupValidAzApi(t *testing.T) {
 	t.Parallel()
 	utils.PreCheckDeployTests(t)
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	v, err := getValidInputVariables(billingScope)
 	require.NoError(t, err)
 	v["subscription_billing_scope"] = billingScope
@@ -156,6 +229,7 @@ func TestDeploySubscriptionAliasManagementGroupValidAzApi(t *testing.T) {
 	v["subscription_use_azapi"] = true
 
 	testDir := filepath.Join("testdata", t.Name())
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -167,7 +241,7 @@ func TestDeploySubscriptionAliasManagementGroupValidAzApi(t *testing.T) {
 	// update it after the apply.
 	u := uuid.MustParse("00000000-0000-0000-0000-000000000000")
 	defer func() {
-		err := azureutils.CancelSubscription(t, &u)
+		err := azureutils.CancelSubscription(ctx, t, &u)
 		if err != nil {
 			t.Logf("cannot cancel subscription: %v", err)
 		}
@@ -184,10 +258,10 @@ func TestDeploySubscriptionAliasManagementGroupValidAzApi(t *testing.T) {
 	u, err = uuid.Parse(sid)
 	assert.NoErrorf(t, err, "subscription id %s is not a valid uuid", sid)
 
-	// err = azureutils.IsSubscriptionInManagementGroup(t, u, v["subscription_management_group_id"].(string))
+	// err = azureutils.IsSubscriptionInManagementGroup(ctx, t, u, v["subscription_management_group_id"].(string))
 	// assert.NoErrorf(t, err, "subscription %s is not in management group %s", sid, v["subscription_management_group_id"].(string))
 
-	if err := azureutils.SetSubscriptionManagementGroup(u, tenantId); err != nil {
+	if err := azureutils.SetSubscriptionManagementGroup(ctx, u, tenantId); err != nil {
 		t.Logf("cannot move subscription to tenant root group: %v", err)
 	}
 }
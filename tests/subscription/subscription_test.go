@@ -7,7 +7,6 @@ import (
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
-	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -189,9 +188,10 @@ func TestSubscriptionAliasCreateInvalidBillingScope(t *testing.T) {
 	v := getMockInputVariables()
 	v["subscription_billing_scope"] = "/PRoviders/Microsoft.Billing/billingAccounts/test-billing-account"
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
 
-	assert.Contains(t, utils.SanitiseErrorMessage(err), "A valid billing scope starts with /providers/Microsoft.Billing/billingAccounts/ and is case sensitive.")
+	utils.AssertInvalidVariable(t, test.Options, "subscription_billing_scope", "A valid billing scope starts with /providers/Microsoft.Billing/billingAccounts/ and is case sensitive.")
 }
 
 // TestSubscriptionAliasCreateInvalidWorkload tests the validation function of the subscription_workload variable.
@@ -201,9 +201,10 @@ func TestSubscriptionAliasCreateInvalidWorkload(t *testing.T) {
 	v := getMockInputVariables()
 	v["subscription_workload"] = "PRoduction"
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
 
-	assert.ErrorContains(t, err, "The workload type can be either Production or DevTest and is case sensitive.")
+	utils.AssertInvalidVariable(t, test.Options, "subscription_workload", "The workload type can be either Production or DevTest and is case sensitive.")
 }
 
 // TestSubscriptionAliasCreateInvalidManagementGroupIdInvalidChars tests the validation function of the
@@ -214,9 +215,10 @@ func TestSubscriptionAliasCreateInvalidManagementGroupIdInvalidChars(t *testing.
 	v := getMockInputVariables()
 	v["subscription_management_group_id"] = "invalid/chars"
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
 
-	assert.Contains(t, utils.SanitiseErrorMessage(err), "The management group ID must be between 1 and 90 characters in length and formed of the following characters: a-z, A-Z, 0-9, -, _, (, ), and a period (.).")
+	utils.AssertInvalidVariable(t, test.Options, "subscription_management_group_id", "The management group ID must be between 1 and 90 characters in length and formed of the following characters: a-z, A-Z, 0-9, -, _, (, ), and a period (.).")
 }
 
 // TestSubscriptionAliasCreateInvalidManagementGroupIdLength tests the validation function of the
@@ -227,9 +229,10 @@ func TestSubscriptionAliasCreateInvalidManagementGroupIdLength(t *testing.T) {
 	v := getMockInputVariables()
 	v["subscription_management_group_id"] = "tooooooooooooooooooooooooooloooooooooooooooooooooonnnnnnnnnnnnnnnnnnngggggggggggggggggggggg"
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
 
-	assert.Contains(t, utils.SanitiseErrorMessage(err), "The management group ID must be between 1 and 90 characters in length and formed of the following characters: a-z, A-Z, 0-9, -, _, (, ), and a period (.).")
+	utils.AssertInvalidVariable(t, test.Options, "subscription_management_group_id", "The management group ID must be between 1 and 90 characters in length and formed of the following characters: a-z, A-Z, 0-9, -, _, (, ), and a period (.).")
 }
 
 func TestSubscriptionInvalidTagValue(t *testing.T) {
@@ -240,8 +243,9 @@ func TestSubscriptionInvalidTagValue(t *testing.T) {
 		"illegal-value": "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Vestibulum mattis velit quis nisl dictum, nec aliquet velit bibendum. Sed et ante nec arcu convallis rutrum. Nulla sed velit ac quam finibus volutpat! Duis malesuada leo nec eros laoreet, vel consectetur enim eleifend. Sed at fermentum libero. Proin sodales lectus quis est volutpat, id suscipit purus eleifend. Vivamus dignissim nulla nec dui sollicitudin, quis pharetra ipsum posuere. Pellentesque eget magna sit amet metus fermentum hendrerit ut non velit. Donec accumsan eros nec nibh porttitor, non interdum elit laoreet. Nam gravida elit ac turpis tristique, a facilisis orci suscipit. Sed eget luctus velit. Integer quis nulla nec ante tempus congue vitae id sem. Nam eget felis non risus fringilla tempor. Integer aliquam facilisis aliquam&.",
 	}
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
-	assert.Contains(t, utils.SanitiseErrorMessage(err), "Tag values must be between 0-256 characters.")
+	utils.AssertInvalidVariable(t, test.Options, "subscription_tags", "Tag values must be between 0-256 characters.")
 }
 
 func TestSubscriptionInvalidTagName(t *testing.T) {
@@ -255,8 +259,9 @@ func TestSubscriptionInvalidTagName(t *testing.T) {
 		tagname: "illegal-name",
 	}
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
-	assert.Contains(t, utils.SanitiseErrorMessage(err), "Tag name must contain neither `<>%&\\?/` nor control characters, and must be between 0-512 characters.")
+	utils.AssertInvalidVariable(t, test.Options, "subscription_tags", "Tag name must contain neither `<>%&\\?/` nor control characters, and must be between 0-512 characters.")
 }
 
 // getMockInputVariables returns a set of mock input variables that can be used and modified for testing scenarios.
@@ -0,0 +1,61 @@
+package usermanagedidentity
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
+	"github.com/Azure/terratest-terraform-fluent/check"
+	"github.com/Azure/terratest-terraform-fluent/setuptest"
+	"github.com/google/uuid"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeployUserManagedIdentityWithFederatedCredentials tests the deployment of a user managed identity
+// with GitHub, Terraform Cloud and advanced federated credentials,
+// and that the identity and its credentials in Azure match the inputs.
+func TestDeployUserManagedIdentityWithFederatedCredentials(t *testing.T) {
+	t.Parallel()
+
+	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	subId, err := uuid.Parse(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+	require.NoErrorf(t, err, "could not parse AZURE_SUBSCRIPTION_ID")
+	name, err := utils.RandomHex(4)
+	require.NoErrorf(t, err, "could not generate random hex")
+
+	in := inputs.New().
+		WithLocation("westeurope").
+		WithUmi("umi-"+name, "rg-umi-"+name).
+		WithUmiResourceGroupLock(false, "").
+		WithUmiGithubCredential("branch", inputs.NewGithubCredential("my-organization", "my-repository", "branch", "main")).
+		WithUmiGithubCredential("pr", inputs.NewGithubCredential("my-organization", "my-repository", "pull_request", "")).
+		WithUmiTfcCredential("plan", inputs.NewTfcCredential("my-organization", "my-project", "my-workspace", "plan")).
+		WithUmiAdvancedCredential("advanced", inputs.NewAdvancedCredential("advanced", "https://test.example.com", "field:value"))
+
+	v := in.UmiModuleVars(subId.String())
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
+	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.NoError(t, err)
+	defer test.Cleanup()
+
+	check.InPlan(test.PlanStruct).NumberOfResourcesEquals(6).ErrorIsNilFatal(t)
+	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
+	test.ApplyIdempotent().ErrorIsNil(t)
+
+	want := azureutils.ExpectedIdentityFromInputs(subId, in)
+	umiID, err := terraform.OutputE(t, test.Options, "umi_id")
+	require.NoError(t, err)
+	assert.Equal(t, want.ID, umiID)
+	want.PrincipalID, err = terraform.OutputE(t, test.Options, "principal_id")
+	require.NoError(t, err)
+	_, err = azureutils.VerifyUserAssignedIdentity(ctx, t, want, nil)
+	assert.NoError(t, err)
+}
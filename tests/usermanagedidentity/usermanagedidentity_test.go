@@ -134,8 +134,10 @@ func TestUserManagedIdentityWithInvalidTFCloudValues(t *testing.T) {
 		},
 	}
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
-	require.ErrorContains(t, err, "Field 'run_phase' value must be 'plan' or 'apply'.")
+	require.Error(t, err)
 	defer test.Cleanup()
+
+	utils.AssertInvalidVariable(t, test.Options, "federated_credentials_terraform_cloud", "Field 'run_phase' value must be 'plan' or 'apply'.")
 }
 
 func TestUserManagedIdentityWithInvalidGHValues(t *testing.T) {
@@ -150,8 +152,10 @@ func TestUserManagedIdentityWithInvalidGHValues(t *testing.T) {
 		},
 	}
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
-	require.ErrorContains(t, err, "Field 'value' must be specified for all entities except 'pull_request'.")
+	require.Error(t, err)
 	defer test.Cleanup()
+
+	utils.AssertInvalidVariable(t, test.Options, "federated_credentials_github", "Field 'value' must be specified for all entities except 'pull_request'.")
 }
 
 func getMockInputVariables() map[string]any {
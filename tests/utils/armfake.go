@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+// systemCertBundles are the well-known locations of the system CA bundle.
+// The first one found is combined with the fake ARM certificate so that
+// terraform init can still reach the public registry.
+var systemCertBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// armFakeEnabled returns true if the fake Azure Resource Manager is in use.
+func armFakeEnabled() bool {
+	return os.Getenv(armfake.EnvEndpoint) != ""
+}
+
+// createArmFakeProvidersFile creates an azapi provider file in the supplied directory
+// that points the provider at the fake Azure Resource Manager.
+func createArmFakeProvidersFile(dir string) error {
+	ep := strings.TrimSuffix(os.Getenv(armfake.EnvEndpoint), "/")
+	providerstf := fmt.Sprintf(`
+provider "azapi" {
+  endpoint {
+    resource_manager_endpoint       = "%[1]s/"
+    resource_manager_audience       = "%[1]s"
+    active_directory_authority_host = "%[1]s/"
+  }
+}`, ep)
+	return os.WriteFile(filepath.Join(filepath.Clean(dir), "_providers.azapi.tf"), []byte(providerstf), 0644)
+}
+
+// setArmFakeEnvVars sets the environment variables used by Terraform and the providers
+// so that they authenticate to, and trust the certificate of, the fake Azure Resource Manager.
+// It is a no-op if the fake is not in use.
+func setArmFakeEnvVars(dir string, opts *terraform.Options) error {
+	if !armFakeEnabled() {
+		return nil
+	}
+	bundle, err := createArmFakeCertBundle(dir)
+	if err != nil {
+		return err
+	}
+	if opts.EnvVars == nil {
+		opts.EnvVars = make(map[string]string)
+	}
+	env := map[string]string{
+		"SSL_CERT_FILE":                       bundle,
+		"ARM_TENANT_ID":                       armfake.DefaultTenantID,
+		"ARM_CLIENT_ID":                       armfake.DefaultPrincipalID,
+		"ARM_CLIENT_SECRET":                   "armfake",
+		"ARM_USE_OIDC":                        "false",
+		"ARM_USE_CLI":                         "false",
+		"ARM_USE_MSI":                         "false",
+		"ARM_SKIP_PROVIDER_REGISTRATION":      "true",
+		"ARM_RESOURCE_PROVIDER_REGISTRATIONS": "none",
+	}
+	if v := os.Getenv("AZURE_SUBSCRIPTION_ID"); v != "" {
+		env["ARM_SUBSCRIPTION_ID"] = v
+	}
+	for k, v := range env {
+		opts.EnvVars[k] = v
+	}
+	return nil
+}
+
+// createArmFakeCertBundle writes a CA bundle containing the system roots and the
+// fake ARM certificate to the supplied directory and returns its path.
+func createArmFakeCertBundle(dir string) (string, error) {
+	fake, err := os.ReadFile(os.Getenv(armfake.EnvCACert))
+	if err != nil {
+		return "", fmt.Errorf("cannot read fake ARM certificate: %v", err)
+	}
+	var bundle []byte
+	for _, f := range systemCertBundles {
+		if b, err := os.ReadFile(f); err == nil {
+			bundle = append(b, '\n')
+			break
+		}
+	}
+	bundle = append(bundle, fake...)
+	path := filepath.Join(filepath.Clean(dir), ".armfake-ca.pem")
+	if err := os.WriteFile(path, bundle, 0600); err != nil {
+		return "", fmt.Errorf("cannot write CA bundle: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+)
+
+// azureRmCloudAttributes returns the azurerm provider attributes that select the Azure cloud,
+// which are empty for the public cloud.
+// A custom cloud is configured from the metadata host, named as in the metadata.
+func azureRmCloudAttributes(env *azureutils.Environment) string {
+	switch {
+	case env.Builtin && env.Name == "public":
+		return ""
+	case env.Builtin:
+		return fmt.Sprintf("  environment = %q\n", env.Name)
+	}
+	return fmt.Sprintf("  environment   = %q\n  metadata_host = %q\n", env.Name, env.MetadataHost)
+}
+
+// createCloudProvidersFile creates an azapi provider file in the supplied directory
+// that points the provider at the Azure cloud selected by azureutils.ResolveEnvironment.
+// No file is created for the public cloud.
+func createCloudProvidersFile(dir string, env *azureutils.Environment) error {
+	var providerstf string
+	switch {
+	case env.Builtin && env.Name == "public":
+		return nil
+	case env.Builtin:
+		providerstf = fmt.Sprintf(`
+provider "azapi" {
+  environment = %q
+}`, env.Name)
+	default:
+		rm := env.Cloud.Services[cloud.ResourceManager]
+		providerstf = fmt.Sprintf(`
+provider "azapi" {
+  endpoint {
+    resource_manager_endpoint       = %q
+    resource_manager_audience       = %q
+    active_directory_authority_host = %q
+  }
+}`, rm.Endpoint, strings.TrimSuffix(rm.Audience, "/.default"), env.Cloud.ActiveDirectoryAuthorityHost)
+	}
+	return os.WriteFile(filepath.Join(filepath.Clean(dir), "_providers.azapi.tf"), []byte(providerstf), 0644)
+}
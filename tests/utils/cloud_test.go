@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAzureRmCloudAttributes(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, azureRmCloudAttributes(&azureutils.Environment{Name: "public", Builtin: true}))
+	assert.Equal(t, "  environment = \"china\"\n", azureRmCloudAttributes(&azureutils.Environment{Name: "china", Builtin: true}))
+	assert.Equal(t, "  environment   = \"Custom\"\n  metadata_host = \"management.custom.example\"\n",
+		azureRmCloudAttributes(&azureutils.Environment{Name: "Custom", MetadataHost: "management.custom.example"}))
+}
+
+func TestCreateCloudProvidersFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, createCloudProvidersFile(dir, &azureutils.Environment{Name: "public", Builtin: true}))
+	assert.NoFileExists(t, filepath.Join(dir, "_providers.azapi.tf"))
+
+	env := &azureutils.Environment{
+		Name:         "Custom",
+		MetadataHost: "management.custom.example",
+		Cloud: cloud.Configuration{
+			ActiveDirectoryAuthorityHost: "https://login.custom.example/",
+			Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {
+					Audience: "https://management.core.custom.example/",
+					Endpoint: "https://management.custom.example/",
+				},
+			},
+		},
+	}
+	require.NoError(t, createCloudProvidersFile(dir, env))
+	b, err := os.ReadFile(filepath.Join(dir, "_providers.azapi.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `resource_manager_endpoint       = "https://management.custom.example/"`)
+	assert.Contains(t, string(b), `resource_manager_audience       = "https://management.core.custom.example/"`)
+	assert.Contains(t, string(b), `active_directory_authority_host = "https://login.custom.example/"`)
+}
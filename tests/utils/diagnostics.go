@@ -0,0 +1,257 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	terratesting "github.com/gruntwork-io/terratest/modules/testing"
+)
+
+const (
+	// SeverityError is the severity of a Terraform error diagnostic.
+	SeverityError = "error"
+
+	// SeverityWarning is the severity of a Terraform warning diagnostic.
+	SeverityWarning = "warning"
+
+	// summaryInvalidVariable is the summary Terraform uses when a variable fails a validation rule.
+	summaryInvalidVariable = "Invalid value for variable"
+)
+
+var (
+	// validationRuleRegex matches the sentence Terraform appends to the detail of a variable validation failure,
+	// capturing the location of the validation rule.
+	validationRuleRegex = regexp.MustCompile(`\s*This was checked by the validation rule at ([^\s]+)\.\s*$`)
+
+	// cliVariableRegex matches the synthetic file name Terraform uses for variable values supplied with -var.
+	cliVariableRegex = regexp.MustCompile(`^<value for (var\.[^>]+)>$`)
+)
+
+// Diagnostic is an error or warning reported by Terraform in the machine readable output of `plan -json`.
+type Diagnostic struct {
+	Severity string           `json:"severity"`
+	Summary  string           `json:"summary"`
+	Detail   string           `json:"detail"`
+	Address  string           `json:"address,omitempty"` // The address of the resource instance or variable, e.g. var.subscription_workload.
+	Range    *DiagnosticRange `json:"range,omitempty"`   // The source range of the diagnostic, if any.
+
+	// ValidationRule is the location of the validation rule that failed, e.g. variables.tf:12,3-13,
+	// and is only set for variable validation failures.
+	ValidationRule string `json:"validation_rule,omitempty"`
+
+	snippet *diagnosticSnippet
+}
+
+// DiagnosticRange is a source range of a diagnostic.
+type DiagnosticRange struct {
+	Filename string        `json:"filename"`
+	Start    DiagnosticPos `json:"start"`
+	End      DiagnosticPos `json:"end"`
+}
+
+// DiagnosticPos is a position in a source file.
+type DiagnosticPos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// String returns the range in the same format that Terraform uses, e.g. variables.tf:12,3-13.
+func (r DiagnosticRange) String() string {
+	if r.Start.Line == r.End.Line {
+		return fmt.Sprintf("%s:%d,%d-%d", r.Filename, r.Start.Line, r.Start.Column, r.End.Column)
+	}
+	return fmt.Sprintf("%s:%d,%d-%d,%d", r.Filename, r.Start.Line, r.Start.Column, r.End.Line, r.End.Column)
+}
+
+// Message returns the detail of the diagnostic without the location of the validation rule
+// that Terraform appends to variable validation failures. For these this is the error_message of the rule.
+func (d Diagnostic) Message() string {
+	return strings.TrimSpace(validationRuleRegex.ReplaceAllString(d.Detail, ""))
+}
+
+// String returns a single line representation of the diagnostic, for use in test failure messages.
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s: %s", d.Severity, d.Summary)
+	if d.Address != "" {
+		s += fmt.Sprintf(" (%s)", d.Address)
+	}
+	if m := d.Message(); m != "" {
+		s += ": " + strings.Join(strings.Fields(m), " ")
+	}
+	return s
+}
+
+// Diagnostics is a list of Terraform diagnostics.
+type Diagnostics []Diagnostic
+
+// Errors returns the diagnostics with error severity.
+func (ds Diagnostics) Errors() Diagnostics {
+	out := make(Diagnostics, 0, len(ds))
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// InvalidVariable returns the diagnostics reporting that the value of the named variable failed validation.
+// The name is that of the variable, without the `var.` prefix.
+func (ds Diagnostics) InvalidVariable(name string) Diagnostics {
+	out := make(Diagnostics, 0, len(ds))
+	for _, d := range ds {
+		if d.Summary == summaryInvalidVariable && d.Address == "var."+name {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Messages returns the message of each diagnostic, see Diagnostic.Message.
+func (ds Diagnostics) Messages() []string {
+	out := make([]string, len(ds))
+	for i, d := range ds {
+		out[i] = d.Message()
+	}
+	return out
+}
+
+// String returns the diagnostics one per line, for use in test failure messages.
+func (ds Diagnostics) String() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diagnosticSnippet is the part of the snippet of a diagnostic used to find the variable it relates to.
+type diagnosticSnippet struct {
+	Values []struct {
+		Traversal string `json:"traversal"`
+	} `json:"values"`
+}
+
+// jsonDiagnostic is a diagnostic as it appears in the machine readable output.
+type jsonDiagnostic struct {
+	Diagnostic
+	Snippet *diagnosticSnippet `json:"snippet,omitempty"`
+}
+
+// jsonMessage is a line of the machine readable output of Terraform.
+type jsonMessage struct {
+	Type       string          `json:"type"`
+	Diagnostic *jsonDiagnostic `json:"diagnostic,omitempty"`
+}
+
+// ParseDiagnostics reads the machine readable output of a Terraform command run with -json,
+// e.g. `terraform plan -json`, and returns the diagnostics it contains.
+// Lines that are not JSON, or are not diagnostics, are ignored.
+func ParseDiagnostics(r io.Reader) (Diagnostics, error) {
+	diags := make(Diagnostics, 0)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var msg jsonMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			continue
+		}
+		if msg.Type != "diagnostic" || msg.Diagnostic == nil {
+			continue
+		}
+		d := msg.Diagnostic.Diagnostic
+		d.snippet = msg.Diagnostic.Snippet
+		diags = append(diags, resolveDiagnostic(d))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read Terraform output: %v", err)
+	}
+	return diags, nil
+}
+
+// PlanDiagnostics runs `terraform plan -json` with the supplied options and returns the diagnostics.
+// The working directory must already be initialised, e.g. use the Options of the setuptest.Response
+// returned by a failed InitPlanShowWithPrepFunc.
+// A non-zero exit code from Terraform is not an error, as this is expected when the plan has error diagnostics.
+func PlanDiagnostics(t terratesting.TestingT, opts *terraform.Options) (Diagnostics, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("cannot get plan diagnostics, Terraform options are nil")
+	}
+	o := *opts
+	o.PlanFilePath = ""
+	o.MaxRetries = 0
+	out, _ := terraform.RunTerraformCommandAndGetStdoutE(t, &o, terraform.FormatArgs(&o, "plan", "-json", "-input=false")...)
+	diags, err := ParseDiagnostics(strings.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
+	if len(diags) == 0 && strings.TrimSpace(out) == "" {
+		return nil, fmt.Errorf("cannot get plan diagnostics, Terraform produced no output")
+	}
+	return diags, nil
+}
+
+// resolveDiagnostic sets the address and validation rule of variable validation failures,
+// which Terraform does not report as separate fields.
+func resolveDiagnostic(d Diagnostic) Diagnostic {
+	if m := validationRuleRegex.FindStringSubmatch(d.Detail); m != nil {
+		d.ValidationRule = m[1]
+	}
+	if d.Address != "" || d.Summary != summaryInvalidVariable {
+		return d
+	}
+	if d.Range != nil {
+		if m := cliVariableRegex.FindStringSubmatch(d.Range.Filename); m != nil {
+			d.Address = m[1]
+			return d
+		}
+	}
+	// The snippet lists the values of the references in the validation condition, the first variable is the one validated.
+	if d.snippet != nil {
+		for _, v := range d.snippet.Values {
+			if strings.HasPrefix(v.Traversal, "var.") {
+				d.Address = "var." + strings.FieldsFunc(v.Traversal[len("var."):], isTraversalSeparator)[0]
+				return d
+			}
+		}
+	}
+	return d
+}
+
+// isTraversalSeparator reports whether r separates the steps of a traversal, e.g. var.a.b or var.a["b"].
+func isTraversalSeparator(r rune) bool {
+	return r == '.' || r == '['
+}
+
+// AssertInvalidVariable runs `terraform plan -json` and fails the test unless the named variable
+// failed a validation rule with the supplied error message.
+// Use it with the Options of the setuptest.Response returned by a failed InitPlanShowWithPrepFunc, e.g.:
+//
+//	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+//	require.Error(t, err)
+//	defer test.Cleanup()
+//	utils.AssertInvalidVariable(t, test.Options, "subscription_workload", "The workload type can be either Production or DevTest and is case sensitive.")
+func AssertInvalidVariable(t *testing.T, opts *terraform.Options, name, message string) {
+	t.Helper()
+	diags, err := PlanDiagnostics(t, opts)
+	if err != nil {
+		t.Fatalf("cannot get plan diagnostics: %v", err)
+	}
+	for _, m := range diags.InvalidVariable(name).Messages() {
+		if m == message {
+			return
+		}
+	}
+	t.Errorf("variable %q did not fail validation with message %q, diagnostics:\n%s", name, message, diags.String())
+}
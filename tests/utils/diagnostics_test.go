@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// planJSONOutput is the output of `terraform plan -json` with two variable validation failures and a warning.
+const planJSONOutput = `{"@level":"info","@message":"Terraform 1.7.5","@module":"terraform.ui","terraform":"1.7.5","type":"version","ui":"1.2"}
+{"@level":"error","@message":"Error: Invalid value for variable","@module":"terraform.ui","diagnostic":{"severity":"error","summary":"Invalid value for variable","detail":"The workload type can be either Production or DevTest and is case sensitive.\n\nThis was checked by the validation rule at variables.tf:92,3-13.","range":{"filename":"<value for var.subscription_workload>","start":{"line":1,"column":1,"byte":0},"end":{"line":1,"column":13,"byte":12}},"snippet":{"context":null,"code":"\"PRoduction\"","start_line":1,"highlight_start_offset":0,"highlight_end_offset":12,"values":[]}},"type":"diagnostic"}
+{"@level":"error","@message":"Error: Invalid value for variable","@module":"terraform.ui","diagnostic":{"severity":"error","summary":"Invalid value for variable","detail":"Tag values must be between 0-256 characters.\n\nThis was checked by the validation rule at variables.tf:146,3-13.","range":{"filename":"variables.tf","start":{"line":147,"column":17,"byte":4012},"end":{"line":149,"column":6,"byte":4130}},"snippet":{"context":"variable \"subscription_tags\"","code":"    condition = alltrue([","start_line":147,"highlight_start_offset":16,"highlight_end_offset":30,"values":[{"traversal":"var.subscription_tags","statement":"is map of string with 1 element"}]}},"type":"diagnostic"}
+{"@level":"warn","@message":"Warning: Deprecated attribute","@module":"terraform.ui","diagnostic":{"severity":"warning","summary":"Deprecated attribute","detail":"The attribute is deprecated.","address":"azapi_resource.rg[\"primary-rg\"]"},"type":"diagnostic"}
+not json
+`
+
+func TestParseDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	diags, err := ParseDiagnostics(strings.NewReader(planJSONOutput))
+	require.NoError(t, err)
+	require.Len(t, diags, 3)
+	assert.Len(t, diags.Errors(), 2)
+
+	workload := diags.InvalidVariable("subscription_workload")
+	require.Len(t, workload, 1)
+	assert.Equal(t, SeverityError, workload[0].Severity)
+	assert.Equal(t, "var.subscription_workload", workload[0].Address)
+	assert.Equal(t, "variables.tf:92,3-13", workload[0].ValidationRule)
+	assert.Equal(t, []string{"The workload type can be either Production or DevTest and is case sensitive."}, workload.Messages())
+
+	tags := diags.InvalidVariable("subscription_tags")
+	require.Len(t, tags, 1)
+	assert.Equal(t, "var.subscription_tags", tags[0].Address)
+	assert.Equal(t, "variables.tf:147,17-149,6", tags[0].Range.String())
+	assert.Equal(t, "Tag values must be between 0-256 characters.", tags[0].Message())
+
+	assert.Equal(t, SeverityWarning, diags[2].Severity)
+	assert.Equal(t, `azapi_resource.rg["primary-rg"]`, diags[2].Address)
+	assert.Empty(t, diags[2].ValidationRule)
+	assert.Empty(t, diags.InvalidVariable("subscription_billing_scope"))
+}
+
+func TestDiagnosticString(t *testing.T) {
+	t.Parallel()
+
+	d := Diagnostic{
+		Severity: SeverityError,
+		Summary:  "Invalid value for variable",
+		Detail:   "Must begin with a subscription scope,\r\ne.g. /subscriptions/0.\n\nThis was checked by the validation rule at variables.tf:18,3-13.",
+		Address:  "var.role_assignment_scope",
+	}
+	assert.Equal(t, "error: Invalid value for variable (var.role_assignment_scope): Must begin with a subscription scope, e.g. /subscriptions/0.", d.String())
+}
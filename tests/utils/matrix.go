@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// EnvProviderMatrix is the environment variable containing the path to the provider matrix file.
+// When set, tests using RunProviderMatrix are run once for each provider version combination in the file.
+const EnvProviderMatrix = "PROVIDER_MATRIX"
+
+// ProviderMatrix is the provider matrix file, listing the provider version combinations to test.
+//
+//	{
+//	  "combinations": [
+//	    { "azapi": "1.12.1", "azurerm": "3.100.0" },
+//	    { "azapi": "latest", "azurerm": "latest" }
+//	  ]
+//	}
+type ProviderMatrix struct {
+	Combinations []ProviderVersions `json:"combinations"`
+}
+
+// LoadProviderMatrix reads the provider matrix file.
+func LoadProviderMatrix(path string) (*ProviderMatrix, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read provider matrix: %v", err)
+	}
+	m := new(ProviderMatrix)
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("cannot parse provider matrix %s: %v", path, err)
+	}
+	if len(m.Combinations) == 0 {
+		return nil, fmt.Errorf("provider matrix %s has no combinations", path)
+	}
+	seen := make(map[string]bool, len(m.Combinations))
+	for _, pv := range m.Combinations {
+		if seen[pv.Name()] {
+			return nil, fmt.Errorf("provider matrix %s has duplicate combination %s", path, pv.Name())
+		}
+		seen[pv.Name()] = true
+	}
+	return m, nil
+}
+
+// RunProviderMatrix runs the test function with the provider versions to test.
+// The test function should use the prep funcs of the supplied ProviderVersions, e.g.:
+//
+//	func TestX(t *testing.T) {
+//		t.Parallel()
+//		utils.RunProviderMatrix(t, func(t *testing.T, pv utils.ProviderVersions) {
+//			test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, pv.AzureRmAndRequiredProviders())
+//			...
+//		})
+//	}
+//
+// If the PROVIDER_MATRIX environment variable is not set, the test function is run once with the versions
+// from ProviderVersionsFromEnv.
+// Otherwise it is run as a parallel subtest, named by ProviderVersions.Name, for each combination in the matrix file.
+func RunProviderMatrix(t *testing.T, f func(t *testing.T, pv ProviderVersions)) {
+	t.Helper()
+	path := os.Getenv(EnvProviderMatrix)
+	if path == "" {
+		f(t, ProviderVersionsFromEnv())
+		return
+	}
+	m, err := LoadProviderMatrix(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pv := range m.Combinations {
+		pv := pv
+		t.Run(pv.Name(), func(t *testing.T) {
+			t.Parallel()
+			f(t, pv)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProviderMatrix(t *testing.T) {
+	t.Parallel()
+
+	m, err := LoadProviderMatrix("../provider-matrix.json")
+	require.NoError(t, err)
+	assert.NotEmpty(t, m.Combinations)
+
+	dir := t.TempDir()
+	dup := filepath.Join(dir, "dup.json")
+	require.NoError(t, os.WriteFile(dup, []byte(`{"combinations":[{"azapi":"latest"},{"azapi":"","azurerm":"latest"}]}`), 0644))
+	_, err = LoadProviderMatrix(dup)
+	assert.ErrorContains(t, err, "duplicate combination azapi=latest,azurerm=latest")
+
+	empty := filepath.Join(dir, "empty.json")
+	require.NoError(t, os.WriteFile(empty, []byte(`{"combinations":[]}`), 0644))
+	_, err = LoadProviderMatrix(empty)
+	assert.ErrorContains(t, err, "has no combinations")
+}
+
+func TestRunProviderMatrix(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "matrix.json")
+	require.NoError(t, os.WriteFile(fn, []byte(`{"combinations":[{"azapi":"1.12.1","azurerm":"3.100.0"},{"azapi":"latest","azurerm":"latest"}]}`), 0644))
+	t.Setenv(EnvProviderMatrix, fn)
+
+	var mu sync.Mutex
+	names := make([]string, 0)
+	t.Run("matrix", func(t *testing.T) {
+		RunProviderMatrix(t, func(t *testing.T, pv ProviderVersions) {
+			mu.Lock()
+			defer mu.Unlock()
+			names = append(names, t.Name())
+		})
+	})
+	sort.Strings(names)
+	assert.Equal(t, []string{
+		"TestRunProviderMatrix/matrix/azapi=1.12.1,azurerm=3.100.0",
+		"TestRunProviderMatrix/matrix/azapi=latest,azurerm=latest",
+	}, names)
+}
+
+func TestRequiredProvidersData(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, RequiredProvidersData{AzAPIVersion: "= 1.12.1", AzureRMVersion: "~> 3.7"}, ProviderVersions{AzAPI: "1.12.1", AzureRM: "latest"}.requiredProvidersData())
+	assert.Equal(t, RequiredProvidersData{AzAPIVersion: "~> 1.4", AzureRMVersion: "= 3.100.0"}, ProviderVersions{AzureRM: "3.100.0"}.requiredProvidersData())
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+const (
+	// EnvProviderMirror is the environment variable containing the path to a provider filesystem mirror,
+	// populated by `go run ./cmd/providermirror`.
+	// When set, Terraform installs the providers from the mirror rather than the registry.
+	EnvProviderMirror = "TF_PROVIDER_MIRROR"
+
+	// cliConfigContent is the Terraform CLI configuration that installs every provider from the filesystem mirror.
+	// There is no direct installation method, so a provider missing from the mirror fails terraform init
+	// rather than being downloaded.
+	cliConfigContent = `provider_installation {
+  filesystem_mirror {
+    path = %q
+  }
+}
+`
+)
+
+// providerMirrorEnabled returns true if a provider filesystem mirror is in use.
+func providerMirrorEnabled() bool {
+	return os.Getenv(EnvProviderMirror) != ""
+}
+
+// setProviderMirror writes a Terraform CLI configuration file to the supplied directory
+// that installs the providers from the filesystem mirror, and sets TF_CLI_CONFIG_FILE to use it.
+// It is a no-op if the mirror is not in use.
+func setProviderMirror(dir string, opts *terraform.Options) error {
+	if !providerMirrorEnabled() {
+		return nil
+	}
+	mirror, err := filepath.Abs(os.Getenv(EnvProviderMirror))
+	if err != nil {
+		return fmt.Errorf("cannot resolve provider mirror path: %v", err)
+	}
+	if fi, err := os.Stat(mirror); err != nil || !fi.IsDir() {
+		return fmt.Errorf("provider mirror %s is not a directory, populate it with `go run ./cmd/providermirror`", mirror)
+	}
+	path := filepath.Join(filepath.Clean(dir), ".terraformrc")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(cliConfigContent, filepath.ToSlash(mirror))), 0644); err != nil {
+		return fmt.Errorf("cannot write Terraform CLI configuration: %v", err)
+	}
+	if opts.EnvVars == nil {
+		opts.EnvVars = make(map[string]string)
+	}
+	opts.EnvVars["TF_CLI_CONFIG_FILE"] = path
+	return nil
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetProviderMirror(t *testing.T) {
+	mirror := t.TempDir()
+	t.Setenv(EnvProviderMirror, mirror)
+
+	dir := t.TempDir()
+	opts := &terraform.Options{}
+	require.NoError(t, setProviderMirror(dir, opts))
+
+	fn := filepath.Join(dir, ".terraformrc")
+	assert.Equal(t, fn, opts.EnvVars["TF_CLI_CONFIG_FILE"])
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "filesystem_mirror")
+	assert.Contains(t, string(b), `path = "`+filepath.ToSlash(mirror)+`"`)
+	assert.NotContains(t, string(b), "direct")
+
+	t.Setenv(EnvProviderMirror, filepath.Join(mirror, "missing"))
+	assert.ErrorContains(t, setProviderMirror(dir, opts), "is not a directory")
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	// EnvRunID is the environment variable that identifies the test run in the provenance tags.
+	// If it is not set, the GitHub Actions run id and attempt are used, or else an id random to the test binary.
+	EnvRunID = "TEST_RUN_ID"
+
+	// provenanceLifetime is how long the resources created by a test are kept before they expire, unless the test
+	// has a later deadline. It matches the default minimum age of the subscriptions cancelled by the sweeper.
+	provenanceLifetime = 6 * time.Hour
+
+	// provenanceGrace is added to the deadline of the test for the expiry time, leaving it time to clean up.
+	provenanceGrace = time.Hour
+)
+
+// tagVariables are the module variables, and attributes of the elements of map of object variables,
+// that hold the tags of a resource.
+var tagVariables = []string{"tags", "subscription_tags", "resource_group_tags", "umi_tags", "umi_resource_group_tags"}
+
+// runID is the id of the test run, see EnvRunID.
+var runID = sync.OnceValue(func() string {
+	if id := os.Getenv(EnvRunID); id != "" {
+		return id
+	}
+	if id := os.Getenv("GITHUB_RUN_ID"); id != "" {
+		if attempt := os.Getenv("GITHUB_RUN_ATTEMPT"); attempt != "" {
+			return id + "-" + attempt
+		}
+		return id
+	}
+	r, err := RandomHex(4)
+	if err != nil {
+		return "local"
+	}
+	return "local-" + r
+})
+
+// Provenance returns the provenance of the resources created by the test: its name, the run id, see EnvRunID,
+// the commit from GITHUB_SHA, the current time and the expiry time, six hours later or an hour after the deadline of the test.
+func Provenance(t *testing.T) azureutils.Provenance {
+	commit := os.Getenv("GITHUB_SHA")
+	if commit == "" {
+		commit = "unknown"
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	expires := now.Add(provenanceLifetime)
+	if d, ok := t.Deadline(); ok && d.Add(provenanceGrace).After(expires) {
+		expires = d.Add(provenanceGrace)
+	}
+	return azureutils.Provenance{
+		Test:    t.Name(),
+		RunID:   runID(),
+		Commit:  commit,
+		Created: now,
+		Expires: expires,
+	}
+}
+
+// WithProvenanceTags adds the provenance tags of the test, see Provenance, to the input variables that hold tags:
+// tags, subscription_tags, resource_group_tags, umi_tags and umi_resource_group_tags,
+// and those attributes of the elements of map variables such as virtual_networks.
+// The directories are those passed to setuptest.Dirs: the variables are those the test harness in testDir declares,
+// or the module in moduleDir if testDir is empty, with the types declared by the module if the harness passes them through as any.
+// Tags already in the variables are kept, and the maps holding them are copied rather than changed.
+// Deployment tests call it before passing the variables to setuptest, so that leftover resources can be traced and swept,
+// see azureutils.ListSubscriptionsByTag.
+func WithProvenanceTags(t *testing.T, moduleDir, testDir string, v map[string]any) error {
+	types, err := inputs.ModuleVariables(moduleDir)
+	if err != nil {
+		return err
+	}
+	declared := types
+	if testDir != "" {
+		if declared, err = inputs.DeclaredVariables(filepath.Join(moduleDir, testDir)); err != nil {
+			return err
+		}
+	}
+
+	tags := Provenance(t).Tags()
+	for name, ty := range declared {
+		if mty, ok := types[name]; ok && ty == cty.DynamicPseudoType {
+			ty = mty
+		}
+		switch {
+		case slices.Contains(tagVariables, name) && isTagsType(ty):
+			if v[name], err = mergeTags(v[name], tags); err != nil {
+				return fmt.Errorf("cannot add provenance tags to %s: %w", name, err)
+			}
+		case ty.IsMapType() && ty.ElementType().IsObjectType():
+			for _, attr := range tagVariables {
+				if !ty.ElementType().HasAttribute(attr) || !isTagsType(ty.ElementType().AttributeType(attr)) {
+					continue
+				}
+				if v[name], err = mergeElementTags(v[name], attr, tags); err != nil {
+					return fmt.Errorf("cannot add provenance tags to %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isTagsType returns true if the type constraint is a map of strings.
+func isTagsType(ty cty.Type) bool {
+	return ty.IsMapType() && ty.ElementType() == cty.String
+}
+
+// mergeTags returns a copy of the tags variable with the tags added, unless already set.
+// The variable is copied so that maps shared with the caller, e.g. between tests, are not changed.
+func mergeTags(value any, tags map[string]string) (any, error) {
+	switch m := value.(type) {
+	case nil:
+		merged := make(map[string]any, len(tags))
+		for k, v := range tags {
+			merged[k] = v
+		}
+		return merged, nil
+	case map[string]any:
+		merged := maps.Clone(m)
+		for k, v := range tags {
+			if _, ok := merged[k]; !ok {
+				merged[k] = v
+			}
+		}
+		return merged, nil
+	case map[string]string:
+		merged := maps.Clone(m)
+		for k, v := range tags {
+			if _, ok := merged[k]; !ok {
+				merged[k] = v
+			}
+		}
+		return merged, nil
+	default:
+		return nil, fmt.Errorf("unexpected type %T", value)
+	}
+}
+
+// mergeElementTags returns a copy of the map of objects variable with the tags added to the attribute of each element,
+// unless already set. The elements are copied too, so the variable of the caller is not changed.
+func mergeElementTags(value any, attr string, tags map[string]string) (any, error) {
+	merge := func(e map[string]any) (map[string]any, error) {
+		merged, err := mergeTags(e[attr], tags)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", attr, err)
+		}
+		e = maps.Clone(e)
+		e[attr] = merged
+		return e, nil
+	}
+	switch m := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		out := make(map[string]any, len(m))
+		for k, e := range m {
+			em, ok := e.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("unexpected element type %T", e)
+			}
+			merged, err := merge(em)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = merged
+		}
+		return out, nil
+	case map[string]map[string]any:
+		out := make(map[string]map[string]any, len(m))
+		for k, e := range m {
+			merged, err := merge(e)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = merged
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unexpected type %T", value)
+	}
+}
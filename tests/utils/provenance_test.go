@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	t.Setenv("GITHUB_SHA", "abc")
+	p := Provenance(t)
+	assert.Equal(t, t.Name(), p.Test)
+	assert.NotEmpty(t, p.RunID)
+	assert.Equal(t, "abc", p.Commit)
+	assert.False(t, p.Expired(time.Now()))
+	assert.GreaterOrEqual(t, p.Expires.Sub(p.Created), provenanceLifetime)
+}
+
+func TestWithProvenanceTags(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Module", func(t *testing.T) {
+		t.Parallel()
+		orig := map[string]any{"env": "test", azureutils.TagCommit: "kept"}
+		v := map[string]any{
+			"resource_group_name": "rg",
+			"tags":                orig,
+		}
+		require.NoError(t, WithProvenanceTags(t, "../../modules/resourcegroup", "", v))
+		assert.Len(t, orig, 2, "the tags of the caller are not changed")
+		tags := v["tags"].(map[string]any)
+		assert.Equal(t, "test", tags["env"])
+		assert.Equal(t, "kept", tags[azureutils.TagCommit], "tags already set are kept")
+		assert.Equal(t, t.Name(), tags[azureutils.TagTest])
+		assert.Contains(t, tags, azureutils.TagExpires)
+	})
+
+	t.Run("MapOfObjects", func(t *testing.T) {
+		t.Parallel()
+		primary := map[string]any{"name": "vnet"}
+		v := map[string]any{
+			"virtual_networks": map[string]any{
+				"primary": primary,
+			},
+		}
+		require.NoError(t, WithProvenanceTags(t, "../../modules/virtualnetwork", "", v))
+		assert.Len(t, primary, 1, "the objects of the caller are not changed")
+		vnet := v["virtual_networks"].(map[string]any)["primary"].(map[string]any)
+		assert.Equal(t, t.Name(), vnet["tags"].(map[string]any)[azureutils.TagTest])
+		assert.Equal(t, t.Name(), vnet["resource_group_tags"].(map[string]any)[azureutils.TagTest])
+		assert.NotContains(t, v, "tags", "only the variables the module declares are set")
+	})
+
+	t.Run("Harness", func(t *testing.T) {
+		t.Parallel()
+		v := map[string]any{
+			"virtual_networks": map[string]any{
+				"primary": map[string]any{"name": "vnet"},
+			},
+		}
+		require.NoError(t, WithProvenanceTags(t, "../..", "testdata/TestDeployIntegrationHubAndSpoke", v))
+		assert.Equal(t, t.Name(), v["subscription_tags"].(map[string]any)[azureutils.TagTest])
+		vnet := v["virtual_networks"].(map[string]any)["primary"].(map[string]any)
+		assert.Equal(t, t.Name(), vnet["tags"].(map[string]any)[azureutils.TagTest], "the module types are used for variables of type any")
+		assert.NotContains(t, v, "umi_tags", "only the variables the harness declares are set")
+	})
+
+	t.Run("UnexpectedType", func(t *testing.T) {
+		t.Parallel()
+		v := map[string]any{"tags": "env=test"}
+		assert.ErrorContains(t, WithProvenanceTags(t, "../../modules/resourcegroup", "", v), "cannot add provenance tags to tags")
+	})
+}
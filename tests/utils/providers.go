@@ -5,13 +5,16 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/armfake"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
 )
 
 // RequiredProvidersData is the data struct for the Terraform required providers block.
-// It should ordinarily be generated using utils.NewRequiredProvidersData().
+// It should ordinarily be generated from a ProviderVersions.
 type RequiredProvidersData struct {
 	AzAPIVersion   string
 	AzureRMVersion string
@@ -38,16 +41,95 @@ terraform {
 //
 // - a required providers file in the given temporary directory (with version constraints from env vars)
 // - a azurerm providers file in the given temporary directory
+//
+// If the fake Azure Resource Manager is in use, the providers are configured to use it.
+// If a provider mirror is in use, Terraform is configured to install the providers from it, see EnvProviderMirror.
 var AzureRmAndRequiredProviders setuptest.PrepFunc = func(resp setuptest.Response) error {
-	if err := createAzureRmProvidersFile(resp.TmpDir); err != nil {
-		return err
-	}
-	return generateRequiredProvidersFile(newRequiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf"))
+	return ProviderVersionsFromEnv().AzureRmAndRequiredProviders()(resp)
 }
 
 // RequiredProviders is a setuptest.SetupTestPrepFunc that will create a required providers file in the given temporary directory.
+// If a provider mirror is in use, Terraform is configured to install the providers from it, see EnvProviderMirror.
 var RequiredProviders setuptest.PrepFunc = func(resp setuptest.Response) error {
-	return generateRequiredProvidersFile(newRequiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf"))
+	return ProviderVersionsFromEnv().RequiredProviders()(resp)
+}
+
+// ProviderVersions is a combination of provider versions to test the module with.
+// An empty or "latest" version uses the default version constraint.
+type ProviderVersions struct {
+	AzAPI   string `json:"azapi"`
+	AzureRM string `json:"azurerm"`
+}
+
+// ProviderVersionsFromEnv returns the provider versions set in the "AZAPI_VERSION" and "AZURERM_VERSION" environment variables.
+func ProviderVersionsFromEnv() ProviderVersions {
+	return ProviderVersions{
+		AzAPI:   os.Getenv("AZAPI_VERSION"),
+		AzureRM: os.Getenv("AZURERM_VERSION"),
+	}
+}
+
+// Name returns the name of the combination, e.g. azapi=1.12.1,azurerm=3.100.0, used to name subtests.
+func (pv ProviderVersions) Name() string {
+	return fmt.Sprintf("azapi=%s,azurerm=%s", versionOrLatest(pv.AzAPI), versionOrLatest(pv.AzureRM))
+}
+
+// AzureRmAndRequiredProviders returns a setuptest.PrepFunc that behaves as utils.AzureRmAndRequiredProviders,
+// using these provider versions.
+func (pv ProviderVersions) AzureRmAndRequiredProviders() setuptest.PrepFunc {
+	return func(resp setuptest.Response) error {
+		if err := createAzureRmProvidersFile(resp.TmpDir); err != nil {
+			return err
+		}
+		if err := createAzApiProvidersFile(resp.TmpDir); err != nil {
+			return err
+		}
+		if err := setArmFakeEnvVars(resp.TmpDir, resp.Options); err != nil {
+			return err
+		}
+		if err := setProviderMirror(resp.TmpDir, resp.Options); err != nil {
+			return err
+		}
+		return generateRequiredProvidersFile(pv.requiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf"))
+	}
+}
+
+// RequiredProviders returns a setuptest.PrepFunc that behaves as utils.RequiredProviders,
+// using these provider versions.
+func (pv ProviderVersions) RequiredProviders() setuptest.PrepFunc {
+	return func(resp setuptest.Response) error {
+		if err := setArmFakeEnvVars(resp.TmpDir, resp.Options); err != nil {
+			return err
+		}
+		if err := setProviderMirror(resp.TmpDir, resp.Options); err != nil {
+			return err
+		}
+		return generateRequiredProvidersFile(pv.requiredProvidersData(), filepath.Clean(resp.TmpDir+"/terraform.tf"))
+	}
+}
+
+// requiredProvidersData returns the required providers data for these versions.
+// A specific version is pinned, otherwise the default version constraint is used.
+func (pv ProviderVersions) requiredProvidersData() RequiredProvidersData {
+	rpd := RequiredProvidersData{
+		AzAPIVersion:   "~> 1.4",
+		AzureRMVersion: "~> 3.7",
+	}
+	if val := versionOrLatest(pv.AzAPI); val != "latest" {
+		rpd.AzAPIVersion = "= " + val
+	}
+	if val := versionOrLatest(pv.AzureRM); val != "latest" {
+		rpd.AzureRMVersion = "= " + val
+	}
+	return rpd
+}
+
+// versionOrLatest returns "latest" if the version is not set.
+func versionOrLatest(v string) string {
+	if v == "" {
+		return "latest"
+	}
+	return v
 }
 
 func generateRequiredProviders(data RequiredProvidersData, w io.Writer) error {
@@ -66,40 +148,49 @@ func generateRequiredProvidersFile(data RequiredProvidersData, path string) erro
 	return generateRequiredProviders(data, f)
 }
 
-// newRequiredProvidersData generated a new version of the required providers data struct.
-// It will use environment variables "AZAPI_VERSION" and "AZURERM_VERSION" to generate the data.
-// If the environment variables are not set or the value is "latest", it will use the default values.
-func newRequiredProvidersData() RequiredProvidersData {
-	var rpd RequiredProvidersData
-	azapiver := "~> 1.4"
-	azurermver := "~> 3.7"
-
-	if val := os.Getenv("AZAPI_VERSION"); val != "" && val != "latest" {
-		azapiver = "= " + val
-	}
-	if val := os.Getenv("AZURERM_VERSION"); val != "" && val != "latest" {
-		azurermver = "= " + val
-	}
-	rpd.AzAPIVersion = azapiver
-	rpd.AzureRMVersion = azurermver
-	return rpd
-}
-
 // createAzureRmProvidersFile creates an azurerm terraform providers file in the supplied directory.
+// The provider uses the fake Azure Resource Manager if it is in use,
+// otherwise the Azure cloud selected by azureutils.ResolveEnvironment.
 func createAzureRmProvidersFile(dir string) error {
+	var providerstf string
+	if armFakeEnabled() {
+		providerstf = fmt.Sprintf(`
+provider "azurerm" {
+  features {}
+  metadata_host = "%s"
+}`, strings.TrimPrefix(os.Getenv(armfake.EnvEndpoint), "https://"))
+	} else {
+		env, err := azureutils.ResolveEnvironment()
+		if err != nil {
+			return err
+		}
+		providerstf = fmt.Sprintf(`
+provider "azurerm" {
+  features {}
+%s}`, azureRmCloudAttributes(env))
+	}
 	dir = filepath.Clean(dir)
 	f, err := os.Create(filepath.Join(dir, "_providers.azurerm.tf"))
 	if err != nil {
 		return fmt.Errorf("error creating providers.tf: %v", err)
 	}
 	defer f.Close()
-	providerstf := `
-provider "azurerm" {
-  features {}
-}`
 	_, err = f.WriteString(providerstf)
 	if err != nil {
 		return fmt.Errorf("error writing providers.tf: %v", err)
 	}
 	return nil
 }
+
+// createAzApiProvidersFile creates an azapi terraform providers file in the supplied directory,
+// if the fake Azure Resource Manager or a cloud other than the public cloud is in use.
+func createAzApiProvidersFile(dir string) error {
+	if armFakeEnabled() {
+		return createArmFakeProvidersFile(dir)
+	}
+	env, err := azureutils.ResolveEnvironment()
+	if err != nil {
+		return err
+	}
+	return createCloudProvidersFile(dir, env)
+}
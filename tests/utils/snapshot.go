@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+)
+
+const (
+	// snapshotDir is the directory, relative to the test package, that holds the golden plan snapshots.
+	snapshotDir = "testdata/snapshots"
+
+	// redactedValue replaces the values generated by RandomHex in plan snapshots.
+	redactedValue = "<redacted>"
+)
+
+// UpdateFlag is the name of the boolean flag that regenerates the golden plan snapshots rather than comparing against them.
+// It is not registered by this package, so that it is not added to every binary that imports it:
+// a test package that calls AssertPlanSnapshot registers it in a _test.go file, e.g.
+//
+//	var _ = flag.Bool(utils.UpdateFlag, false, "update the golden plan snapshot files in testdata/snapshots")
+const UpdateFlag = "update"
+
+// randomHexValues holds the values returned by RandomHex, so that they can be redacted from plan snapshots.
+var randomHexValues sync.Map
+
+// AssertPlanSnapshot compares the normalised plan to the golden file testdata/snapshots/<test name>.json
+// in the test package directory, failing the test with a structural diff if they differ.
+//
+// The plan is normalised by taking the actions and planned values of each resource change,
+// removing values that are unknown until apply, decoding JSON encoded strings such as azapi bodies,
+// and redacting values generated by RandomHex and any additional supplied values.
+//
+// Run the test with -update to write the golden file, see UpdateFlag.
+// If the golden file does not exist the test fails, unless run with -update.
+func AssertPlanSnapshot(t *testing.T, plan *terraform.PlanStruct, redact ...string) {
+	t.Helper()
+	got, err := NormalisePlan(plan, redact...)
+	if err != nil {
+		t.Fatalf("cannot normalise plan: %v", err)
+	}
+	fn := filepath.Join(snapshotDir, strings.ReplaceAll(t.Name(), "/", "_")+".json")
+
+	if updateSnapshots() {
+		if err := writeSnapshot(fn, got); err != nil {
+			t.Fatalf("cannot write plan snapshot: %v", err)
+		}
+		t.Logf("wrote plan snapshot %s", fn)
+		return
+	}
+
+	b, err := os.ReadFile(fn)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		t.Fatalf("plan snapshot %s does not exist, run the test with -update and commit the file", fn)
+		return
+	case err != nil:
+		t.Fatalf("cannot read plan snapshot: %v", err)
+	}
+
+	var want any
+	if err := json.Unmarshal(b, &want); err != nil {
+		t.Fatalf("cannot parse plan snapshot %s: %v", fn, err)
+	}
+	if diffs := diffValues("", want, got); len(diffs) > 0 {
+		t.Errorf("plan does not match snapshot %s, run the test with -update if the change is expected:\n  %s",
+			fn, strings.Join(diffs, "\n  "))
+	}
+}
+
+// updateSnapshots returns true if the test binary was run with -update, see UpdateFlag.
+func updateSnapshots() bool {
+	f := flag.Lookup(UpdateFlag)
+	if f == nil {
+		return false
+	}
+	g, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	update, _ := g.Get().(bool)
+	return update
+}
+
+// NormalisePlan returns a deterministic representation of the plan, keyed by resource address,
+// suitable for comparison with a golden file.
+// See AssertPlanSnapshot for details of the normalisation.
+func NormalisePlan(plan *terraform.PlanStruct, redact ...string) (any, error) {
+	out := make(map[string]any, len(plan.ResourceChangesMap))
+	for addr, rc := range plan.ResourceChangesMap {
+		if rc == nil || rc.Change == nil {
+			continue
+		}
+		actions := make([]any, 0, len(rc.Change.Actions))
+		for _, a := range rc.Change.Actions {
+			actions = append(actions, string(a))
+		}
+		out[addr] = map[string]any{
+			"actions": actions,
+			"after":   stripUnknown(rc.Change.After, rc.Change.AfterUnknown),
+		}
+	}
+
+	// Round trip through JSON so that the types match those of a decoded golden file.
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	var normalised any
+	if err := json.Unmarshal(b, &normalised); err != nil {
+		return nil, err
+	}
+
+	redactions := make([]string, 0, len(redact))
+	randomHexValues.Range(func(k, _ any) bool {
+		redactions = append(redactions, k.(string))
+		return true
+	})
+	redactions = append(redactions, redact...)
+	return normaliseValue(normalised, redactions), nil
+}
+
+// writeSnapshot writes the normalised plan to the golden file.
+func writeSnapshot(fn string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, append(b, '\n'), 0644)
+}
+
+// stripUnknown removes the parts of the planned value that are marked as unknown in the after_unknown value.
+func stripUnknown(after, unknown any) any {
+	switch u := unknown.(type) {
+	case bool:
+		if u {
+			return nil
+		}
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok {
+			return after
+		}
+		out := make(map[string]any, len(a))
+		for k, v := range a {
+			if b, ok := u[k].(bool); ok && b {
+				continue
+			}
+			out[k] = stripUnknown(v, u[k])
+		}
+		return out
+	case []any:
+		a, ok := after.([]any)
+		if !ok {
+			return after
+		}
+		out := make([]any, 0, len(a))
+		for i, v := range a {
+			if i < len(u) {
+				if b, ok := u[i].(bool); ok && b {
+					continue
+				}
+				out = append(out, stripUnknown(v, u[i]))
+				continue
+			}
+			out = append(out, v)
+		}
+		return out
+	}
+	return after
+}
+
+// normaliseValue decodes JSON encoded strings and redacts the supplied values from strings and map keys.
+func normaliseValue(v any, redact []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, e := range val {
+			out[redactString(k, redact)] = normaliseValue(e, redact)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, e := range val {
+			out[i] = normaliseValue(e, redact)
+		}
+		return out
+	case string:
+		trimmed := strings.TrimSpace(val)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			var decoded any
+			if err := json.Unmarshal([]byte(trimmed), &decoded); err == nil {
+				return normaliseValue(decoded, redact)
+			}
+		}
+		return redactString(val, redact)
+	}
+	return v
+}
+
+// redactString replaces each of the supplied values in s.
+func redactString(s string, redact []string) string {
+	for _, r := range redact {
+		if r == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, r, redactedValue)
+	}
+	return s
+}
+
+// diffValues returns a human readable list of the differences between two decoded JSON values.
+func diffValues(path string, want, got any) []string {
+	label := path
+	if label == "" {
+		label = "(root)"
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want %s, got %s", label, describe(want), describe(got))}
+		}
+		keys := make([]string, 0, len(w)+len(g))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		for k := range g {
+			if _, ok := w[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		diffs := make([]string, 0)
+		for _, k := range keys {
+			p := joinPath(path, k)
+			wv, wok := w[k]
+			gv, gok := g[k]
+			switch {
+			case !gok:
+				diffs = append(diffs, fmt.Sprintf("%s: removed, was %s", p, describe(wv)))
+			case !wok:
+				diffs = append(diffs, fmt.Sprintf("%s: added, now %s", p, describe(gv)))
+			default:
+				diffs = append(diffs, diffValues(p, wv, gv)...)
+			}
+		}
+		return diffs
+
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: want %s, got %s", label, describe(want), describe(got))}
+		}
+		diffs := make([]string, 0)
+		for i := 0; i < len(w) || i < len(g); i++ {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(g):
+				diffs = append(diffs, fmt.Sprintf("%s: removed, was %s", p, describe(w[i])))
+			case i >= len(w):
+				diffs = append(diffs, fmt.Sprintf("%s: added, now %s", p, describe(g[i])))
+			default:
+				diffs = append(diffs, diffValues(p, w[i], g[i])...)
+			}
+		}
+		return diffs
+	}
+
+	if !reflect.DeepEqual(want, got) {
+		return []string{fmt.Sprintf("%s: want %s, got %s", label, describe(want), describe(got))}
+	}
+	return nil
+}
+
+// joinPath appends the map key to the path, quoting keys that are not simple identifiers.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	if strings.ContainsAny(key, ".[]\" ") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	return path + "." + key
+}
+
+// describe returns a short JSON representation of the value for use in a diff.
+func describe(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	const max = 120
+	if len(b) > max {
+		return string(b[:max]) + "..."
+	}
+	return string(b)
+}
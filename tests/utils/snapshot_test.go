@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testPlan(name string) *terraform.PlanStruct {
+	return &terraform.PlanStruct{
+		ResourceChangesMap: map[string]*tfjson.ResourceChange{
+			`azapi_resource.rg["` + name + `"]`: {
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionCreate},
+					After: map[string]any{
+						"name":      name,
+						"parent_id": "/subscriptions/00000000-0000-0000-0000-000000000000",
+						"body":      `{"properties":{"b":2,"a":1},"location":"northeurope"}`,
+						"tags":      []any{"x", nil, "z"},
+					},
+					AfterUnknown: map[string]any{
+						"id":   true,
+						"tags": []any{false, true, false},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNormalisePlan(t *testing.T) {
+	t.Parallel()
+
+	r, err := RandomHex(4)
+	require.NoError(t, err)
+	got, err := NormalisePlan(testPlan("testdeploy-"+r), "00000000-0000-0000-0000-000000000000")
+	require.NoError(t, err)
+
+	want := map[string]any{
+		`azapi_resource.rg["testdeploy-<redacted>"]`: map[string]any{
+			"actions": []any{"create"},
+			"after": map[string]any{
+				"name":      "testdeploy-<redacted>",
+				"parent_id": "/subscriptions/<redacted>",
+				"body": map[string]any{
+					"location":   "northeurope",
+					"properties": map[string]any{"a": float64(1), "b": float64(2)},
+				},
+				"tags": []any{"x", "z"},
+			},
+		},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestDiffValues(t *testing.T) {
+	t.Parallel()
+
+	var want, got any
+	require.NoError(t, json.Unmarshal([]byte(`{"a.b":{"x":1,"list":[1,2]},"c":true}`), &want))
+	require.NoError(t, json.Unmarshal([]byte(`{"a.b":{"x":2,"list":[1],"y":"new"}}`), &got))
+
+	assert.Equal(t, []string{
+		`a.b.list[1]: removed, was 2`,
+		`a.b.x: want 1, got 2`,
+		`a.b.y: added, now "new"`,
+		`c: removed, was true`,
+	}, diffValues("", want, got))
+	assert.Empty(t, diffValues("", want, want))
+}
+
+func TestWriteSnapshotRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	got, err := NormalisePlan(testPlan("rg"))
+	require.NoError(t, err)
+	fn := filepath.Join(t.TempDir(), "snapshots", "TestX.json")
+	require.NoError(t, writeSnapshot(fn, got))
+
+	b, err := os.ReadFile(fn)
+	require.NoError(t, err)
+	var want any
+	require.NoError(t, json.Unmarshal(b, &want))
+	assert.Empty(t, diffValues("", want, got))
+}
@@ -6,19 +6,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"testing"
 
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
 	"github.com/gruntwork-io/terratest/modules/logger"
 )
 
-// SanitiseErrorMessage replaces the newline characters in an error.Error() output with a single space to allow us to check for the entire error message.
-// We need to do this because Terraform adds newline characters depending on the width of the console window.
-// TODO: Test on Windows if we get \r\n instead of just \n.
-func SanitiseErrorMessage(err error) string {
-	return strings.Replace(err.Error(), "\n", " ", -1)
-}
-
 // GetLogger returns a logger that can be used for testing.
 // The default logger will discard the Terraform output.
 // Set TERRATEST_LOGGER to a non empty value to enable verbose logging.
@@ -50,17 +43,36 @@ func PreCheckDeployTests(t *testing.T) {
 			t.FailNow()
 		}
 	}
+
+	// Fail early if the credential used by azureutils is misconfigured, and log which one is used.
+	cs, err := azureutils.SelectCredentialSource()
+	if err != nil {
+		t.Logf("invalid Azure credential configuration: %v", err)
+		t.FailNow()
+	}
+	t.Log(cs)
+
+	// Report how often the requests made by azureutils were throttled, which grows with the number of tests run in parallel.
+	// The counts are for all the tests using the default factory so far, not only this one.
+	t.Cleanup(func() {
+		if stats, err := azureutils.Throttling(); err == nil && stats.Total().Throttled > 0 {
+			t.Logf("Azure Resource Manager throttling: %s", stats)
+		}
+	})
 }
 
 // RandomHex generates a random hex string of the given byte length.
 // Uses crypto/rand for generating the random bytes not math/rand
 // as we kept getting the same results from the math/rand generator.
+// The generated values are redacted from plan snapshots, see AssertPlanSnapshot.
 func RandomHex(n int) (string, error) {
 	bytes := make([]byte, n)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", err
 	}
-	return hex.EncodeToString(bytes), nil
+	r := hex.EncodeToString(bytes)
+	randomHexValues.Store(r, struct{}{})
+	return r, nil
 }
 
 // GetTestDir returns the directory of the test file.
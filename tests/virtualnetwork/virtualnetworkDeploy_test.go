@@ -1,12 +1,15 @@
 package virtualnetwork
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/azureutils"
+	"github.com/Azure/terraform-azurerm-lz-vending/tests/inputs"
 	"github.com/Azure/terraform-azurerm-lz-vending/tests/utils"
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
@@ -22,9 +25,11 @@ func TestDeployVirtualNetworkValid(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
 
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -56,13 +61,15 @@ func TestDeployVirtualNetworkValidCustomDns(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
-	primaryvnet["dns_servers"] = []string{"192.168.0.250", "192.168.0.251"}
-	secondaryvnet["dns_servers"] = []string{"192.168.1.250", "192.168.1.251"}
-
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	primaryvnet := in.VirtualNetworks["primary"]
+	secondaryvnet := in.VirtualNetworks["secondary"]
+	primaryvnet.WithDnsServers("192.168.0.250", "192.168.0.251")
+	secondaryvnet.WithDnsServers("192.168.1.250", "192.168.1.251")
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -94,16 +101,22 @@ func TestDeployVirtualNetworkValidVnetPeering(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
-	primaryvnet["hub_peering_enabled"] = true
-	secondaryvnet["hub_peering_enabled"] = true
-	primaryvnet["hub_peering_use_remote_gateways"] = false
-	secondaryvnet["hub_peering_use_remote_gateways"] = false
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	testDir := "testdata/" + t.Name()
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	primaryvnet := in.VirtualNetworks["primary"]
+	secondaryvnet := in.VirtualNetworks["secondary"]
+	primaryvnet.HubPeeringEnabled = to.Ptr(true)
+	secondaryvnet.HubPeeringEnabled = to.Ptr(true)
+	primaryvnet.WithHubPeeringUseRemoteGateways(false)
+	secondaryvnet.WithHubPeeringUseRemoteGateways(false)
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -127,6 +140,9 @@ func TestDeployVirtualNetworkValidVnetPeering(t *testing.T) {
 	// defer terraform destroy with retry
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check Azure reports the peerings as converged with the flags we set
+	verifyHubPeerings(ctx, t, test, in)
 }
 
 // TestDeployVirtualNetworkValidUniDirectionalVnetPeering tests the deployment of a virtual network
@@ -135,18 +151,24 @@ func TestDeployVirtualNetworkValidUniDirectionalVnetPeering(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
-	primaryvnet["hub_peering_enabled"] = true
-	primaryvnet["hub_peering_direction"] = "fromhub"
-	secondaryvnet["hub_peering_enabled"] = true
-	secondaryvnet["hub_peering_direction"] = "tohub"
-	primaryvnet["hub_peering_use_remote_gateways"] = false
-	secondaryvnet["hub_peering_use_remote_gateways"] = false
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	testDir := "testdata/" + t.Name()
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	primaryvnet := in.VirtualNetworks["primary"]
+	secondaryvnet := in.VirtualNetworks["secondary"]
+	primaryvnet.HubPeeringEnabled = to.Ptr(true)
+	primaryvnet.WithHubPeeringDirection("fromhub")
+	secondaryvnet.HubPeeringEnabled = to.Ptr(true)
+	secondaryvnet.WithHubPeeringDirection("tohub")
+	primaryvnet.WithHubPeeringUseRemoteGateways(false)
+	secondaryvnet.WithHubPeeringUseRemoteGateways(false)
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -168,6 +190,9 @@ func TestDeployVirtualNetworkValidUniDirectionalVnetPeering(t *testing.T) {
 	// defer terraform destroy with retry
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check Azure reports the peerings as converged with the flags we set
+	verifyHubPeerings(ctx, t, test, in)
 }
 
 // TestDeployVirtualNetworkValidVhubConnection tests the deployment of a virtual network
@@ -176,14 +201,20 @@ func TestDeployVirtualNetworkValidVhubConnection(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
-	primaryvnet["vwan_connection_enabled"] = true
-	secondaryvnet["vwan_connection_enabled"] = true
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	testDir := "testdata/" + t.Name()
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	primaryvnet := in.VirtualNetworks["primary"]
+	secondaryvnet := in.VirtualNetworks["secondary"]
+	primaryvnet.VwanConnectionEnabled = to.Ptr(true)
+	secondaryvnet.VwanConnectionEnabled = to.Ptr(true)
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -209,6 +240,9 @@ func TestDeployVirtualNetworkValidVhubConnection(t *testing.T) {
 	}
 	defer test.DestroyRetry(rty) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check Azure applied the routing configuration we set
+	verifyHubConnections(ctx, t, test, in)
 }
 
 // TestDeployVirtualNetworkValidVhubConnectionAndRoutingIntent tests the deployment of a virtual network
@@ -217,20 +251,22 @@ func TestDeployVirtualNetworkValidVhubConnectionAndRoutingIntent(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
-	testDir := "testdata/" + t.Name()
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
-	primaryvnet["vwan_connection_enabled"] = true
-	secondaryvnet["vwan_connection_enabled"] = true
-	primaryvnet["vwan_security_configuration"] = map[string]any{
-		"routing_intent_enabled": true,
-	}
-	secondaryvnet["vwan_security_configuration"] = map[string]any{
-		"routing_intent_enabled": true,
-	}
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	testDir := "testdata/" + t.Name()
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	primaryvnet := in.VirtualNetworks["primary"]
+	secondaryvnet := in.VirtualNetworks["secondary"]
+	primaryvnet.VwanConnectionEnabled = to.Ptr(true)
+	secondaryvnet.VwanConnectionEnabled = to.Ptr(true)
+	primaryvnet.VwanSecurityConfiguration = &inputs.VwanSecurityConfiguration{RoutingIntentEnabled: to.Ptr(true)}
+	secondaryvnet.VwanSecurityConfiguration = &inputs.VwanSecurityConfiguration{RoutingIntentEnabled: to.Ptr(true)}
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).Init(t)
 	require.NoError(t, utils.AzureRmAndRequiredProviders(test))
 
@@ -248,6 +284,9 @@ func TestDeployVirtualNetworkValidVhubConnectionAndRoutingIntent(t *testing.T) {
 	}
 	defer test.DestroyRetry(rtyDestroy) //nolint:errcheck
 	test.ApplyIdempotentRetry(rtyApply).ErrorIsNil(t)
+
+	// check Azure has no routing configuration on the connections, as routing intent manages it
+	verifyHubConnections(ctx, t, test, in)
 }
 
 // TestDeployVirtualNetworkSubnetIdempotency tests that we can make changes
@@ -257,10 +296,16 @@ func TestDeployVirtualNetworkSubnetIdempotency(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
+
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
 	testDir := "testdata/" + t.Name()
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
 
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, testDir, v))
 	test, err := setuptest.Dirs(moduleDir, testDir).WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -277,7 +322,7 @@ func TestDeployVirtualNetworkSubnetIdempotency(t *testing.T) {
 	_, err = terraform.ApplyAndIdempotentE(t, test.Options)
 	assert.NoError(t, err)
 	name := primaryvnet["name"].(string)
-	subnets, err := azureutils.ListSubnets(name, name, uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID")))
+	subnets, err := azureutils.ListSubnets(ctx, name, name, uuid.MustParse(os.Getenv("AZURE_SUBSCRIPTION_ID")))
 	require.NoErrorf(t, err, "failed to list subnets")
 	assert.Lenf(t, subnets, 1, "expected 1 subnet, got %d", len(subnets))
 }
@@ -288,13 +333,19 @@ func TestDeployVirtualNetworkValidMeshPeering(t *testing.T) {
 	t.Parallel()
 
 	utils.PreCheckDeployTests(t)
-	v, err := getValidInputVariables()
-	require.NoErrorf(t, err, "could not generate valid input variables, %s", err)
-	primaryvnet := v["virtual_networks"].(map[string]map[string]any)["primary"]
-	secondaryvnet := v["virtual_networks"].(map[string]map[string]any)["secondary"]
-	primaryvnet["mesh_peering_enabled"] = true
-	secondaryvnet["mesh_peering_enabled"] = true
 
+	ctx, cancel := azureutils.TestContext(t)
+	defer cancel()
+
+	in, err := getValidInputs()
+	require.NoErrorf(t, err, "could not generate valid inputs, %s", err)
+	primaryvnet := in.VirtualNetworks["primary"]
+	secondaryvnet := in.VirtualNetworks["secondary"]
+	primaryvnet.MeshPeeringEnabled = to.Ptr(true)
+	secondaryvnet.MeshPeeringEnabled = to.Ptr(true)
+	v := in.VirtualNetworkModuleVars(os.Getenv("AZURE_SUBSCRIPTION_ID"))
+
+	require.NoError(t, utils.WithProvenanceTags(t, moduleDir, "", v))
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
 	require.NoError(t, err)
 	defer test.Cleanup()
@@ -316,9 +367,64 @@ func TestDeployVirtualNetworkValidMeshPeering(t *testing.T) {
 	// defer terraform destroy with retry
 	defer test.DestroyRetry(setuptest.DefaultRetry) //nolint:errcheck
 	test.ApplyIdempotent().ErrorIsNil(t)
+
+	// check Azure reports the peerings as converged with the flags we set
+	verifyMeshPeerings(ctx, t, test, in)
+}
+
+// verifyHubPeerings checks the hub peerings in Azure match the inputs,
+// using the hub virtual network created by the test data.
+func verifyHubPeerings(ctx context.Context, t *testing.T, test setuptest.Response, in *inputs.Inputs) {
+	hub, err := terraform.OutputE(t, test.Options, "hub_virtual_network_resource_id")
+	require.NoError(t, err)
+	ids, err := terraform.OutputMapE(t, test.Options, "virtual_network_resource_ids")
+	require.NoError(t, err)
+
+	vnets := copyVirtualNetworks(in, func(v *inputs.VirtualNetwork) { v.HubNetworkResourceID = &hub })
+	peerings, err := azureutils.HubPeerings(vnets, ids)
+	require.NoError(t, err)
+	assert.NoError(t, azureutils.VerifyPeerings(ctx, t, peerings, nil))
+}
+
+// verifyMeshPeerings checks the mesh peerings between the virtual networks in Azure match the inputs.
+func verifyMeshPeerings(ctx context.Context, t *testing.T, test setuptest.Response, in *inputs.Inputs) {
+	ids, err := terraform.OutputMapE(t, test.Options, "virtual_network_resource_ids")
+	require.NoError(t, err)
+
+	peerings, err := azureutils.MeshPeerings(in.VirtualNetworks, ids)
+	require.NoError(t, err)
+	require.Len(t, peerings, 2)
+	assert.NoError(t, azureutils.VerifyPeerings(ctx, t, peerings, nil))
+}
+
+// verifyHubConnections checks the virtual hub connections in Azure match the inputs,
+// using the virtual hub created by the test data.
+func verifyHubConnections(ctx context.Context, t *testing.T, test setuptest.Response, in *inputs.Inputs) {
+	hub, err := terraform.OutputE(t, test.Options, "virtual_hub_resource_id")
+	require.NoError(t, err)
+	ids, err := terraform.OutputMapE(t, test.Options, "virtual_network_resource_ids")
+	require.NoError(t, err)
+
+	vnets := copyVirtualNetworks(in, func(v *inputs.VirtualNetwork) { v.VwanHubResourceID = &hub })
+	conns, err := azureutils.HubConnections(vnets, ids)
+	require.NoError(t, err)
+	assert.NoError(t, azureutils.VerifyHubConnections(ctx, t, conns, nil))
+}
+
+// copyVirtualNetworks returns a copy of the virtual networks of the inputs with set applied to each,
+// as the test data does for the resources it creates.
+func copyVirtualNetworks(in *inputs.Inputs, set func(*inputs.VirtualNetwork)) map[string]*inputs.VirtualNetwork {
+	vnets := make(map[string]*inputs.VirtualNetwork, len(in.VirtualNetworks))
+	for k, v := range in.VirtualNetworks {
+		c := *v
+		set(&c)
+		vnets[k] = &c
+	}
+	return vnets
 }
 
-func getValidInputVariables() (map[string]any, error) {
+// getValidInputs returns inputs with two virtual networks in different resource groups and locations.
+func getValidInputs() (*inputs.Inputs, error) {
 	r, err := utils.RandomHex(4)
 	if err != nil {
 		return nil, fmt.Errorf("cannot generate random hex, %s", err)
@@ -326,23 +432,11 @@ func getValidInputVariables() (map[string]any, error) {
 	name := fmt.Sprintf("testdeploy-%s", r)
 	name2 := name + "-2"
 
-	return map[string]any{
-		"subscription_id": os.Getenv("AZURE_SUBSCRIPTION_ID"),
-		"virtual_networks": map[string]map[string]any{
-			"primary": {
-				"name":                        name,
-				"address_space":               []string{"192.168.0.0/24"},
-				"location":                    "westeurope",
-				"resource_group_name":         name,
-				"resource_group_lock_enabled": false,
-			},
-			"secondary": {
-				"name":                        name2,
-				"address_space":               []string{"192.168.1.0/24"},
-				"location":                    "northeurope",
-				"resource_group_name":         name2,
-				"resource_group_lock_enabled": false,
-			},
-		},
-	}, nil
+	return inputs.New().
+		WithVirtualNetwork("primary", inputs.NewVirtualNetwork(name, name, "192.168.0.0/24").
+			WithLocation("westeurope").
+			WithResourceGroupLock(false, "")).
+		WithVirtualNetwork("secondary", inputs.NewVirtualNetwork(name2, name2, "192.168.1.0/24").
+			WithLocation("northeurope").
+			WithResourceGroupLock(false, "")), nil
 }
@@ -8,7 +8,6 @@ import (
 	"github.com/Azure/terratest-terraform-fluent/check"
 	"github.com/Azure/terratest-terraform-fluent/setuptest"
 	"github.com/gruntwork-io/terratest/modules/terraform"
-	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -715,6 +714,7 @@ func TestVirtualNetworkCreateValidWithVhubSecureInternetAndPrivateTraffic(t *tes
 		Query("properties.routingConfiguration.propagatedRouteTables.ids.#").
 		HasValue(1).
 		ErrorIsNil(t)
+
 }
 
 // TestVirtualNetworkCreateValidWithVhubRoutingIntentEnabled tests that routingConfiguration is null when
@@ -771,8 +771,9 @@ func TestVirtualNetworkCreateInvalidHubNetResId(t *testing.T) {
 	primaryvnet["hub_network_resource_id"] = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroup/testrg/providers/Microsoft.Network/virtualNetworks/tes.-tvnet2"
 
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
-	assert.ErrorContains(t, err, "Hub network resource id must be an Azure virtual network resource id")
+	utils.AssertInvalidVariable(t, test.Options, "virtual_networks", "Hub network resource id must be an Azure virtual network resource id, e.g. /subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet.")
 }
 
 // TestVirtualNetworkCreateInvalidVhubResId tests the regex of the
@@ -786,8 +787,9 @@ func TestVirtualNetworkCreateInvalidVhubResId(t *testing.T) {
 	primaryvnet["vwan_hub_resource_id"] = "/subscription/00000000-0000-0000-0000-000000000000/resourceGroups/test_rg/providers/Microsoft.Network/virtualHubs/te.st-hub"
 
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
-	assert.ErrorContains(t, err, "vWAN hub resource id must be an Azure vWAN hub network resource id")
+	utils.AssertInvalidVariable(t, test.Options, "virtual_networks", "The vWAN hub resource id must be an Azure vWAN hub network resource id, e.g. /subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/my-rg/providers/Microsoft.Network/virtualHubs/my-vhub.")
 }
 
 // TestVirtualNetworkCreateZeroLengthAddressSpace tests the length of address_space > 0
@@ -799,8 +801,9 @@ func TestVirtualNetworkCreateZeroLengthAddressSpace(t *testing.T) {
 	primaryvnet["address_space"] = []string{}
 
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
-	assert.ErrorContains(t, err, "At least 1 address space must be specified")
+	utils.AssertInvalidVariable(t, test.Options, "virtual_networks", "At least 1 address space must be specified.")
 }
 
 // TestVirtualNetworkCreateInvalidAddressSpace tests a valid CIDR address space is used
@@ -812,8 +815,9 @@ func TestVirtualNetworkCreateInvalidAddressSpace(t *testing.T) {
 	primaryvnet["address_space"] = []string{"10.37.242/35"}
 
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
-	assert.ErrorContains(t, err, "Address space entries must be specified in CIDR notation")
+	utils.AssertInvalidVariable(t, test.Options, "virtual_networks", "Address space entries must be specified in CIDR notation, e.g. 192.168.0.0/24.")
 }
 
 // TestVirtualNetworkCreateInvalidResourceGroupCreation tests that resource group naming is unique
@@ -827,8 +831,9 @@ func TestVirtualNetworkCreateInvalidResourceGroupCreation(t *testing.T) {
 	primaryvnet["resource_group_name"] = "secondary-rg"
 
 	test, err := setuptest.Dirs(moduleDir, "").WithVars(v).InitPlanShowWithPrepFunc(t, utils.AzureRmAndRequiredProviders)
+	require.Error(t, err)
 	defer test.Cleanup()
-	assert.Containsf(t, utils.SanitiseErrorMessage(err), "Resource group names with creation enabled must be unique. Virtual networks deployed into the same resource group must have only one enabled for resource group creation.", "Expected error message not found")
+	utils.AssertInvalidVariable(t, test.Options, "virtual_networks", "Resource group names with creation enabled must be unique. Virtual networks deployed into the same resource group must have only one enabled for resource group creation.")
 }
 
 func TestVirtualNetworkDdosProtection(t *testing.T) {